@@ -0,0 +1,76 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthInterval is how often Redis is checked, to set the health status.
+const healthInterval = 5 * time.Second
+
+// serviceName is the name of the Simon Says service, for health checks.
+const serviceName = "simonsays.SimonSays"
+
+// watchHealth sets the serving status of the server and the Simon Says service from
+// whether Redis can be reached, checking every healthInterval. When done is closed,
+// the status is set to NOT_SERVING, and the returned channel is closed.
+func watchHealth(h *health.Server, simon *simonsays.SimonSays, done <-chan struct{}) <-chan struct{} {
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		t := time.NewTicker(healthInterval)
+		defer t.Stop()
+
+		status := healthpb.HealthCheckResponse_UNKNOWN
+		for {
+			next := healthpb.HealthCheckResponse_SERVING
+			if err := simon.Ping(); err != nil {
+				next = healthpb.HealthCheckResponse_NOT_SERVING
+				log.Printf("[Warn][Health] Could not reach Redis. %v", err)
+			}
+
+			if next != status {
+				log.Printf("[Info][Health] Status is now %v", next)
+				status = next
+				setHealth(h, status)
+			}
+
+			select {
+			case <-t.C:
+			case <-done:
+				log.Printf("[Info][Health] Shutting down. Status is now %v", healthpb.HealthCheckResponse_NOT_SERVING)
+				setHealth(h, healthpb.HealthCheckResponse_NOT_SERVING)
+				return
+			}
+		}
+	}()
+
+	return stopped
+}
+
+// setHealth sets the serving status of the server as a whole, and the Simon Says service.
+func setHealth(h *health.Server, status healthpb.HealthCheckResponse_ServingStatus) {
+	h.SetServingStatus("", status)
+	h.SetServingStatus(serviceName, status)
+}
@@ -20,20 +20,72 @@ package main
 
 import (
 	"log"
+	"math"
 	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/garyburd/redigo/redis"
 	"github.com/grpc-simonsays/simonsays-server/simonsays"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/auth"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
 	port         = "PORT"
 	redisAddress = "REDIS_ADDRESS"
+	turnTimeout  = "TURN_TIMEOUT"
+	pressTimeout = "PRESS_TIMEOUT"
+	roomExpiry   = "ROOM_EXPIRY"
+	brokerType   = "BROKER"
+	gracePeriod  = "RESUME_GRACE_PERIOD"
+	drainTimeout = "DRAIN_TIMEOUT"
+	duplicates   = "DUPLICATE_SESSIONS"
+	metricsPort  = "METRICS_PORT"
+	logLevel     = "LOG_LEVEL"
+	logFormat    = "LOG_FORMAT"
+
+	// the server's certificate and key, to serve over TLS, and the CAs
+	// client certificates must be signed by, for mutual TLS.
+	tlsCert     = "TLS_CERT_FILE"
+	tlsKey      = "TLS_KEY_FILE"
+	tlsClientCA = "TLS_CLIENT_CA_FILE"
+
+	// the password to AUTH with, and whether to connect over TLS, to Redis.
+	redisPassword = "REDIS_PASSWORD"
+	redisTLS      = "REDIS_TLS"
+	redisTLSCA    = "REDIS_TLS_CA_FILE"
+
+	// the key bearer tokens are signed with, and whether players can still
+	// join without one. Without a key, players are who they say they are.
+	authKey      = "AUTH_KEY"
+	authOptional = "AUTH_OPTIONAL"
+
+	// how many joins and presses each player, and each address, can make a second,
+	// and how many they can make in a burst. Unlimited if the rate isn't set.
+	// If RATE_LIMITER is "redis", the limits are shared between servers.
+	joinRate    = "JOIN_RATE"
+	joinBurst   = "JOIN_BURST"
+	pressRate   = "PRESS_RATE"
+	pressBurst  = "PRESS_BURST"
+	rateLimiter = "RATE_LIMITER"
 )
 
+// gameMethod is the stream players join games on, which needs a token.
+const gameMethod = "/simonsays.SimonSays/Game"
+
 // Create a Server instance and fire it up!
 func main() {
+	configureLogger()
+
 	port := os.Getenv(port)
 	// default for port
 	if port == "" {
@@ -46,16 +98,211 @@ func main() {
 	}
 	defer lis.Close()
 
-	s := grpc.NewServer()
+	done := make(chan struct{})
+
+	// with the memory broker, players are only matched with others on this server,
+	// and can only resume their games on it, but Redis is still needed for the rest.
+	var broker simonsays.Broker
+	if os.Getenv(brokerType) == "memory" {
+		log.Printf("[Info][Server] Using in memory broker")
+		broker = simonsays.NewMemoryBroker()
+	}
 
-	simon, err := simonsays.NewSimonSays(os.Getenv(redisAddress))
+	redisOpts := redisOptions()
+	simon, err := simonsays.NewSimonSays(os.Getenv(redisAddress), broker, redisOpts...)
 	if err != nil {
 		log.Fatalf("[Error][Server] Could not connect to redis: %v.", err)
 	}
 	defer simon.Close()
 
+	simon.TurnTimeout = mustDuration(turnTimeout)
+	simon.PressTimeout = mustDuration(pressTimeout)
+	if d := mustDuration(roomExpiry); d > 0 {
+		simon.RoomExpiry = d
+	}
+	if d := mustDuration(gracePeriod); d > 0 {
+		simon.GracePeriod = d
+	}
+	if v := os.Getenv(duplicates); v != "" {
+		p, err := simonsays.ParseDuplicatePolicy(v)
+		if err != nil {
+			log.Fatalf("[Error][Server] Could not parse %v. %v", duplicates, err)
+		}
+		simon.DuplicateSessions = p
+	}
+
+	shared := os.Getenv(rateLimiter) == "redis"
+	simon.JoinLimiter = limiter("Joins", joinRate, joinBurst, shared, redisOpts)
+	simon.PressLimiter = limiter("Presses", pressRate, pressBurst, shared, redisOpts)
+
+	var opts []grpc.ServerOption
+	if creds := serverCredentials(done); creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	// players are authenticated before their joins are counted.
+	var interceptors []grpc.StreamServerInterceptor
+	if a := authenticator(); a != nil {
+		interceptors = append(interceptors, a.StreamInterceptor(gameMethod))
+	}
+	interceptors = append(interceptors, simon.JoinInterceptor())
+	opts = append(opts, grpc.StreamInterceptor(chainStreamInterceptors(interceptors...)))
+
+	s := grpc.NewServer(opts...)
 	simonsays.RegisterSimonSaysServer(s, simon)
 
+	// let load balancers and tools like grpcurl know what we are up to.
+	h := health.NewServer()
+	healthpb.RegisterHealthServer(s, h)
+	reflection.Register(s)
+
+	serveMetrics(simon, os.Getenv(metricsPort))
+
+	stopped := watchHealth(h, simon, done)
+
+	drain := simonsays.DefaultDrainTimeout
+	if d := mustDuration(drainTimeout); d > 0 {
+		drain = d
+	}
+
+	// on the way down, let the health checks know, give the running games a
+	// chance to finish, then stop serving.
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+		log.Printf("[Info][Server] Received %v. Shutting down.", <-sig)
+
+		close(done)
+		<-stopped
+		simon.Shutdown(drain)
+		s.GracefulStop()
+	}()
+
 	log.Printf("[Info][Server] Starting server on port %v", port)
 	log.Printf("[Info][Server] The server has been stopped: %v", s.Serve(lis))
 }
+
+// serverCredentials returns the TLS credentials for the server, if TLS_CERT_FILE and TLS_KEY_FILE
+// are set, or nil to serve in plaintext. If TLS_CLIENT_CA_FILE is set too, clients must have a
+// certificate signed by one of its CAs. The files are reloaded when they change, until done is closed.
+func serverCredentials(done <-chan struct{}) credentials.TransportCredentials {
+	cert, key := os.Getenv(tlsCert), os.Getenv(tlsKey)
+	if cert == "" && key == "" {
+		log.Printf("[Warn][Server] %v and %v are not set. Serving in plaintext.", tlsCert, tlsKey)
+		return nil
+	}
+
+	r, err := newCertReloader(cert, key, os.Getenv(tlsClientCA))
+	if err != nil {
+		log.Fatalf("[Error][Server] Could not load the TLS certificates. %v", err)
+	}
+	go r.watch(done)
+
+	if r.caFile != "" {
+		log.Printf("[Info][Server] Serving over mutual TLS.")
+	} else {
+		log.Printf("[Info][Server] Serving over TLS.")
+	}
+
+	return r
+}
+
+// authenticator returns what checks the bearer tokens of players joining a game,
+// signed with AUTH_KEY, or nil if it isn't set. If AUTH_OPTIONAL is "true", players
+// without a token can still join, as whoever they say they are.
+func authenticator() *auth.Authenticator {
+	key := os.Getenv(authKey)
+	if key == "" {
+		log.Printf("[Warn][Server] %v is not set. Players are not authenticated.", authKey)
+		return nil
+	}
+
+	a := auth.NewAuthenticator([]byte(key))
+	a.Optional = os.Getenv(authOptional) == "true"
+	if a.Optional {
+		log.Printf("[Info][Server] Authenticating players that have a token.")
+	} else {
+		log.Printf("[Info][Server] Authenticating players.")
+	}
+
+	return a
+}
+
+// limiter returns the Limiter with the rate and burst in the given environment variables,
+// or nil if the rate isn't set. The burst defaults to the rate, rounded up. If shared,
+// the limiter is kept in Redis, with the given options to connect to it.
+func limiter(name, rateEnv, burstEnv string, shared bool, options []redis.DialOption) simonsays.Limiter {
+	v := os.Getenv(rateEnv)
+	if v == "" {
+		return nil
+	}
+
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate <= 0 {
+		log.Fatalf("[Error][Server] Could not parse %v. It should be a positive number. %v", rateEnv, v)
+	}
+
+	burst := int(math.Ceil(rate))
+	if v := os.Getenv(burstEnv); v != "" {
+		burst, err = strconv.Atoi(v)
+		if err != nil || burst < 1 {
+			log.Fatalf("[Error][Server] Could not parse %v. It should be a positive whole number. %v", burstEnv, v)
+		}
+	}
+
+	log.Printf("[Info][Server] Limiting %v to %v a second, in bursts of %v. Shared: %v", name, rate, burst, shared)
+	if shared {
+		return simonsays.NewRedisLimiter(os.Getenv(redisAddress), name, rate, burst, options...)
+	}
+	return simonsays.NewMemoryLimiter(rate, burst)
+}
+
+// redisOptions returns the options to connect to Redis with, to AUTH with REDIS_PASSWORD,
+// and connect over TLS if REDIS_TLS is "true", trusting the CAs in REDIS_TLS_CA_FILE.
+func redisOptions() []redis.DialOption {
+	var options []redis.DialOption
+
+	if p := os.Getenv(redisPassword); p != "" {
+		options = append(options, redis.DialPassword(p))
+	}
+
+	if os.Getenv(redisTLS) == "true" {
+		c, err := redisTLSConfig(os.Getenv(redisTLSCA))
+		if err != nil {
+			log.Fatalf("[Error][Redis] Could not load the TLS CAs. %v", err)
+		}
+		options = append(options, simonsays.RedisTLS(c))
+	}
+
+	return options
+}
+
+// configureLogger sets the lowest level that is logged from LOG_LEVEL, and
+// logs JSON lines rather than text if LOG_FORMAT is "json".
+func configureLogger() {
+	if v := os.Getenv(logLevel); v != "" {
+		l, err := logger.ParseLevel(v)
+		if err != nil {
+			log.Fatalf("[Error][Server] Could not parse %v. %v", logLevel, err)
+		}
+		logger.SetLevel(l)
+	}
+
+	logger.SetJSON(os.Getenv(logFormat) == "json")
+}
+
+// mustDuration parses the duration in the given environment variable.
+// Defaults to zero if it is not set.
+func mustDuration(env string) time.Duration {
+	v := os.Getenv(env)
+	if v == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("[Error][Server] Could not parse %v. %v", env, err)
+	}
+
+	return d
+}
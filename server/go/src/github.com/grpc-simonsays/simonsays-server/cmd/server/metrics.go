@@ -0,0 +1,47 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/metrics"
+)
+
+// defaultMetricsPort is the port metrics are served on, if METRICS_PORT isn't set.
+const defaultMetricsPort = "9090"
+
+// serveMetrics serves the metrics for Prometheus to scrape at /metrics,
+// on the given port, in the background.
+func serveMetrics(simon *simonsays.SimonSays, port string) {
+	if port == "" {
+		port = defaultMetricsPort
+	}
+
+	if err := simon.RegisterMetrics(metrics.Default); err != nil {
+		log.Fatalf("[Error][Metrics] Could not register metrics. %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default)
+
+	go func() {
+		log.Printf("[Info][Metrics] Serving metrics on port %v", port)
+		log.Printf("[Error][Metrics] Metrics server has stopped. %v", http.ListenAndServe(":"+port, mux))
+	}()
+}
@@ -0,0 +1,189 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+)
+
+// certReloadInterval is how often the certificate files are checked for changes.
+const certReloadInterval = 30 * time.Second
+
+// certReloader is TLS credentials for the gRPC listener, with the server certificate, and
+// the client CAs for mutual TLS, from files on disk. They are loaded again when the files
+// change, so certificates can be rotated without restarting the server. Each handshake
+// uses the certificates that were last loaded.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	// if set, clients must have a certificate signed by one of these CAs.
+	caFile string
+
+	mu    sync.RWMutex
+	creds credentials.TransportCredentials
+	// when each file was last changed, when it was last loaded.
+	modTimes map[string]time.Time
+}
+
+// newCertReloader loads the certificate, key and client CAs. The CA file is optional.
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the files again if any of them have changed since they were last loaded.
+// Returns if they were loaded. If they can't be loaded, the ones already loaded are kept.
+func (r *certReloader) reload() (bool, error) {
+	modTimes := map[string]time.Time{}
+	changed := false
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, err
+		}
+		modTimes[f] = info.ModTime()
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return false, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("No certificates found in %v", r.caFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.creds = credentials.NewTLS(config)
+	r.modTimes = modTimes
+
+	return true, nil
+}
+
+// watch checks the files for changes every certReloadInterval, until done is closed.
+func (r *certReloader) watch(done <-chan struct{}) {
+	t := time.NewTicker(certReloadInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			loaded, err := r.reload()
+			if err != nil {
+				log.Printf("[Warn][TLS] Could not reload the certificates. Keeping the old ones. %v", err)
+			} else if loaded {
+				log.Printf("[Info][TLS] Reloaded the certificates.")
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// current returns the credentials that were last loaded.
+func (r *certReloader) current() credentials.TransportCredentials {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.creds
+}
+
+// ClientHandshake does the client side of the handshake, with the current credentials.
+func (r *certReloader) ClientHandshake(ctx context.Context, addr string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return r.current().ClientHandshake(ctx, addr, rawConn)
+}
+
+// ServerHandshake does the server side of the handshake, with the current credentials.
+func (r *certReloader) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return r.current().ServerHandshake(rawConn)
+}
+
+// Info returns the protocol info of the current credentials.
+func (r *certReloader) Info() credentials.ProtocolInfo {
+	return r.current().Info()
+}
+
+// Clone returns the same reloader, since it follows the same files.
+func (r *certReloader) Clone() credentials.TransportCredentials {
+	return r
+}
+
+// OverrideServerName overrides the server name of the current credentials.
+func (r *certReloader) OverrideServerName(name string) error {
+	return r.current().OverrideServerName(name)
+}
+
+// redisTLSConfig returns the TLS config for connecting to Redis, trusting
+// the CAs in the file as well as the system's, if it is set.
+func redisTLSConfig(caFile string) (*tls.Config, error) {
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return c, nil
+	}
+
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cas, err := x509.SystemCertPool()
+	if err != nil {
+		cas = x509.NewCertPool()
+	}
+	if !cas.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates found in %v", caFile)
+	}
+	c.RootCAs = cas
+
+	return c, nil
+}
@@ -0,0 +1,58 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+/*
+Issues bearer tokens for players, signed with the same AUTH_KEY as the
+server, for local development.
+
+	AUTH_KEY=secret token -player "Player One" -ttl 24h
+
+Send it to the server in the "authorization" metadata as "Bearer <token>".
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/auth"
+)
+
+// authKey is the key tokens are signed with.
+const authKey = "AUTH_KEY"
+
+func main() {
+	player := flag.String("player", "", "The id of the player the token is for.")
+	ttl := flag.Duration("ttl", 24*time.Hour, "How long the token is good for. Zero never expires.")
+	flag.Parse()
+
+	key := os.Getenv(authKey)
+	if key == "" {
+		log.Fatalf("[Error][Token] %v is not set.", authKey)
+	}
+	if *player == "" {
+		log.Fatalf("[Error][Token] -player is not set.")
+	}
+
+	token, err := auth.NewAuthenticator([]byte(key)).Issue(*player, *ttl)
+	if err != nil {
+		log.Fatalf("[Error][Token] Could not issue the token. %v", err)
+	}
+
+	fmt.Println(token)
+}
@@ -0,0 +1,211 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+// Package auth works out who a player is from a bearer token, so they
+// can't play as someone else. Tokens are JWTs signed with HMAC-SHA256,
+// sent in the "authorization" metadata as "Bearer <token>".
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// authorizationKey is the metadata key the token is sent in.
+const authorizationKey = "authorization"
+
+// bearerPrefix comes before the token in the metadata value.
+const bearerPrefix = "bearer "
+
+var (
+	// ErrNoToken is returned when the request doesn't have a token.
+	ErrNoToken = errors.New("Request has no bearer token")
+	// ErrInvalidToken is returned when a token can't be read, or wasn't signed with the key.
+	ErrInvalidToken = errors.New("Token is not valid")
+	// ErrExpiredToken is returned when a token has expired.
+	ErrExpiredToken = errors.New("Token has expired")
+)
+
+// header is the JWT header of every token.
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// claims are the JWT claims of a token.
+type claims struct {
+	// the player id.
+	Subject string `json:"sub"`
+	// when the token was issued, and when it expires, in seconds since the Unix epoch.
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// Authenticator issues and verifies tokens signed with a key.
+type Authenticator struct {
+	key []byte
+	// Optional lets requests without a token through, without a subject.
+	// Tokens that are sent are still verified.
+	Optional bool
+	// now is the current time. Replaced in tests.
+	now func() time.Time
+}
+
+// NewAuthenticator creates an Authenticator that signs tokens with the key.
+func NewAuthenticator(key []byte) *Authenticator {
+	return &Authenticator{key: key, now: time.Now}
+}
+
+// sign returns the signature of the header and claims.
+func (a *Authenticator) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Issue returns a token for the subject, that expires after ttl. A ttl of zero never expires.
+func (a *Authenticator) Issue(subject string, ttl time.Duration) (string, error) {
+	now := a.now()
+	c := claims{Subject: subject, IssuedAt: now.Unix()}
+	if ttl > 0 {
+		c.ExpiresAt = now.Add(ttl).Unix()
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + a.sign(unsigned), nil
+}
+
+// Verify checks the token was signed with the key and hasn't expired,
+// and returns its subject.
+func (a *Authenticator) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return "", ErrInvalidToken
+	}
+
+	if !hmac.Equal([]byte(a.sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return "", ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Subject == "" {
+		return "", ErrInvalidToken
+	}
+
+	if c.ExpiresAt != 0 && a.now().Unix() >= c.ExpiresAt {
+		return "", ErrExpiredToken
+	}
+
+	return c.Subject, nil
+}
+
+// subjectKey is the context key for the verified subject.
+type subjectKey struct{}
+
+// NewContext returns a context with the verified subject.
+func NewContext(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// FromContext returns the verified subject, if the request had a token.
+func FromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(string)
+	return subject, ok
+}
+
+// tokenFromContext returns the bearer token in the request's metadata.
+func tokenFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return "", ErrNoToken
+	}
+
+	for _, v := range md[authorizationKey] {
+		if len(v) > len(bearerPrefix) && strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+			return v[len(bearerPrefix):], nil
+		}
+	}
+
+	return "", ErrNoToken
+}
+
+// authenticate verifies the token in the request, and returns a context with its subject.
+func (a *Authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	token, err := tokenFromContext(ctx)
+	if err == ErrNoToken && a.Optional {
+		return ctx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := a.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewContext(ctx, subject), nil
+}
+
+// authStream is a stream with the context of the verified subject.
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context with the verified subject.
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamInterceptor returns an interceptor that verifies the token of the streams
+// of the given methods, like "/simonsays.SimonSays/Game", and ends them as
+// UNAUTHENTICATED if it isn't valid. Other streams are let through as they are.
+func (a *Authenticator) StreamInterceptor(methods ...string) grpc.StreamServerInterceptor {
+	protected := map[string]bool{}
+	for _, m := range methods {
+		protected[m] = true
+	}
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !protected[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		ctx, err := a.authenticate(ss.Context())
+		if err != nil {
+			return grpc.Errorf(codes.Unauthenticated, "%v", err)
+		}
+
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,154 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestTokens tests issuing and verifying tokens.
+func TestTokens(t *testing.T) {
+	Convey("Given an authenticator", t, func() {
+		a := NewAuthenticator([]byte("secret"))
+
+		Convey("A token it issued should verify as its subject", func() {
+			token, err := a.Issue("Player One", time.Hour)
+			So(err, ShouldBeNil)
+
+			subject, err := a.Verify(token)
+			So(err, ShouldBeNil)
+			So(subject, ShouldEqual, "Player One")
+		})
+
+		Convey("A token signed with another key should not verify", func() {
+			token, err := NewAuthenticator([]byte("other")).Issue("Player One", time.Hour)
+			So(err, ShouldBeNil)
+
+			_, err = a.Verify(token)
+			So(err, ShouldEqual, ErrInvalidToken)
+		})
+
+		Convey("A token that has been changed should not verify", func() {
+			token, err := a.Issue("Player One", time.Hour)
+			So(err, ShouldBeNil)
+			other, err := a.Issue("Player Two", time.Hour)
+			So(err, ShouldBeNil)
+
+			// the claims of one, with the signature of the other.
+			_, err = a.Verify(other[:len(other)-43] + token[len(token)-43:])
+			So(err, ShouldEqual, ErrInvalidToken)
+		})
+
+		Convey("Things that aren't tokens should not verify", func() {
+			for _, token := range []string{"", "a.b", "a.b.c", "a.b.c.d", header + ".!!." + a.sign(header+".!!")} {
+				_, err := a.Verify(token)
+				So(err, ShouldEqual, ErrInvalidToken)
+			}
+		})
+
+		Convey("A token that has expired should not verify", func() {
+			token, err := a.Issue("Player One", time.Minute)
+			So(err, ShouldBeNil)
+
+			a.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+			_, err = a.Verify(token)
+			So(err, ShouldEqual, ErrExpiredToken)
+		})
+
+		Convey("A token without a ttl should never expire", func() {
+			token, err := a.Issue("Player One", 0)
+			So(err, ShouldBeNil)
+
+			a.now = func() time.Time { return time.Now().Add(24 * 365 * time.Hour) }
+			subject, err := a.Verify(token)
+			So(err, ShouldBeNil)
+			So(subject, ShouldEqual, "Player One")
+		})
+	})
+}
+
+// mockServerStream is a stream with just a context.
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (m *mockServerStream) Context() context.Context { return m.ctx }
+
+// TestStreamInterceptor tests authenticating streams.
+func TestStreamInterceptor(t *testing.T) {
+	Convey("Given an interceptor for the Game stream", t, func() {
+		a := NewAuthenticator([]byte("secret"))
+		intercept := a.StreamInterceptor("/simonsays.SimonSays/Game")
+		game := &grpc.StreamServerInfo{FullMethod: "/simonsays.SimonSays/Game"}
+
+		var subject string
+		var authenticated bool
+		handler := func(srv interface{}, ss grpc.ServerStream) error {
+			subject, authenticated = FromContext(ss.Context())
+			return nil
+		}
+
+		withToken := func(value string) grpc.ServerStream {
+			md := metadata.MD{authorizationKey: []string{value}}
+			return &mockServerStream{ctx: metadata.NewContext(context.TODO(), md)}
+		}
+
+		Convey("A stream with a valid token should have its subject", func() {
+			token, err := a.Issue("Player One", time.Hour)
+			So(err, ShouldBeNil)
+
+			So(intercept(nil, withToken("Bearer "+token), game, handler), ShouldBeNil)
+			So(authenticated, ShouldBeTrue)
+			So(subject, ShouldEqual, "Player One")
+		})
+
+		Convey("A stream with an invalid token should be unauthenticated", func() {
+			err := intercept(nil, withToken("Bearer nope"), game, handler)
+			So(grpc.Code(err), ShouldEqual, codes.Unauthenticated)
+			So(authenticated, ShouldBeFalse)
+		})
+
+		Convey("A stream without a token", func() {
+			stream := &mockServerStream{ctx: context.TODO()}
+
+			Convey("Should be unauthenticated", func() {
+				err := intercept(nil, stream, game, handler)
+				So(grpc.Code(err), ShouldEqual, codes.Unauthenticated)
+				So(authenticated, ShouldBeFalse)
+			})
+
+			Convey("Should be let through without a subject, if tokens are optional", func() {
+				a.Optional = true
+				So(intercept(nil, stream, game, handler), ShouldBeNil)
+				So(authenticated, ShouldBeFalse)
+			})
+
+			Convey("Should be let through for other methods", func() {
+				other := &grpc.StreamServerInfo{FullMethod: "/simonsays.SimonSays/Spectate"}
+				So(intercept(nil, stream, other, handler), ShouldBeNil)
+				So(authenticated, ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,45 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import "golang.org/x/net/context"
+
+// Broker passes messages between the players of a game,
+// who may well be connected to different servers.
+// Each game has its own topic, named after the game's id.
+type Broker interface {
+	// Publish sends the data to everyone subscribed to the topic.
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Subscribe subscribes to the topic. Nothing published after this returns is missed.
+	Subscribe(ctx context.Context, topic string) (Subscription, error)
+	// Watch subscribes to the topic like Subscribe, but without
+	// being counted by Subscribers.
+	Watch(ctx context.Context, topic string) (Subscription, error)
+	// Subscribers returns how many subscribers the topic has.
+	Subscribers(ctx context.Context, topic string) (int, error)
+	// Close closes all resources.
+	Close() error
+}
+
+// Subscription is a subscription to a Broker topic.
+type Subscription interface {
+	// Messages is the channel that messages published to the topic come through.
+	// It is closed once the subscription ends, or the context it was
+	// created with is done.
+	Messages() <-chan []byte
+	// Unsubscribe ends the subscription.
+	Unsubscribe() error
+}
@@ -0,0 +1,130 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"io"
+	"net"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// reasonCodes are the gRPC status codes a game ends with, for each reason.
+var reasonCodes = map[Error_Reason]codes.Code{
+	Error_UNKNOWN:           codes.Internal,
+	Error_INVALID_REQUEST:   codes.InvalidArgument,
+	Error_UNAVAILABLE:       codes.Unavailable,
+	Error_TIMEOUT:           codes.DeadlineExceeded,
+	Error_ABORTED:           codes.Aborted,
+	Error_NOT_FOUND:         codes.NotFound,
+	Error_SHUTDOWN:          codes.Unavailable,
+	Error_PERMISSION_DENIED: codes.PermissionDenied,
+	Error_DUPLICATE_SESSION: codes.AlreadyExists,
+	Error_REPLACED:          codes.Aborted,
+	Error_RATE_LIMITED:      codes.ResourceExhausted,
+}
+
+// invalidRequestError is returned when a player breaks the protocol.
+type invalidRequestError string
+
+// Error returns the string representation of an invalidRequestError.
+func (e invalidRequestError) Error() string {
+	return string(e)
+}
+
+// timeoutError is returned when something doesn't happen in time.
+type timeoutError string
+
+// Error returns the string representation of a timeoutError.
+func (e timeoutError) Error() string {
+	return string(e)
+}
+
+// errorReason works out the reason a game ended with the error.
+func errorReason(err error) Error_Reason {
+	switch err {
+	case ErrRoomNotFound, ErrGameNotFound, ErrSessionNotFound:
+		return Error_NOT_FOUND
+	case ErrRoomExpired, context.DeadlineExceeded:
+		return Error_TIMEOUT
+	case ErrRoomFull, errGameGone, io.EOF:
+		return Error_ABORTED
+	case redis.ErrPoolExhausted:
+		return Error_UNAVAILABLE
+	case ErrShuttingDown:
+		return Error_SHUTDOWN
+	case ErrWrongPlayer:
+		return Error_PERMISSION_DENIED
+	case ErrDuplicateSession:
+		return Error_DUPLICATE_SESSION
+	case ErrSessionReplaced:
+		return Error_REPLACED
+	case ErrRateLimited:
+		return Error_RATE_LIMITED
+	}
+
+	switch e := err.(type) {
+	case relayedError:
+		return e.reason
+	case invalidRequestError:
+		return Error_INVALID_REQUEST
+	case timeoutError:
+		return Error_TIMEOUT
+	case net.Error:
+		return Error_UNAVAILABLE
+	}
+
+	return Error_UNKNOWN
+}
+
+// endStream lets the client know why the stream is ending early with an
+// ERROR Response, and returns the error as a gRPC status. Errors that already
+// have a status, like the client cancelling, are returned as they are,
+// since the stream has already gone.
+func endStream(stream responseStream, err error) error {
+	lc := "EndStream"
+	ctx := stream.Context()
+
+	if err == nil {
+		return nil
+	}
+
+	if err == context.Canceled {
+		return grpc.Errorf(codes.Canceled, "%v", err)
+	}
+
+	if grpc.Code(err) != codes.Unknown {
+		return err
+	}
+
+	reason := errorReason(err)
+	res := &Response{Event: &Response_Error{Error: &Error{Reason: reason, Message: err.Error()}}}
+	if err := sendResponse(stream, res); err != nil {
+		logger.Error(ctx, lc, "Could not let the client know about the error. %v", err)
+	}
+
+	return grpc.Errorf(reasonCodes[reason], "%v", err)
+}
+
+// statusError returns the error as a gRPC status, with the code for
+// the reason it happened, for the RPCs that aren't streams.
+func statusError(err error) error {
+	return grpc.Errorf(reasonCodes[errorReason(err)], "%v", err)
+}
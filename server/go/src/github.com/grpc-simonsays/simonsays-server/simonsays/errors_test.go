@@ -0,0 +1,115 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestErrorReason tests working out why a game ended.
+func TestErrorReason(t *testing.T) {
+	Convey("Errors should have the right reason", t, func() {
+		So(errorReason(errors.New("Something broke")), ShouldEqual, Error_UNKNOWN)
+		So(errorReason(invalidRequestError("Bad request")), ShouldEqual, Error_INVALID_REQUEST)
+		So(errorReason(&net.OpError{Op: "dial", Err: errors.New("Connection refused")}), ShouldEqual, Error_UNAVAILABLE)
+		So(errorReason(timeoutError("Too slow")), ShouldEqual, Error_TIMEOUT)
+		So(errorReason(ErrRoomExpired), ShouldEqual, Error_TIMEOUT)
+		So(errorReason(ErrRoomFull), ShouldEqual, Error_ABORTED)
+		So(errorReason(errGameGone), ShouldEqual, Error_ABORTED)
+		So(errorReason(io.EOF), ShouldEqual, Error_ABORTED)
+		So(errorReason(ErrRoomNotFound), ShouldEqual, Error_NOT_FOUND)
+		So(errorReason(ErrSessionNotFound), ShouldEqual, Error_NOT_FOUND)
+		So(errorReason(ErrWrongPlayer), ShouldEqual, Error_PERMISSION_DENIED)
+		So(errorReason(ErrDuplicateSession), ShouldEqual, Error_DUPLICATE_SESSION)
+		So(errorReason(ErrSessionReplaced), ShouldEqual, Error_REPLACED)
+		So(errorReason(ErrRateLimited), ShouldEqual, Error_RATE_LIMITED)
+	})
+}
+
+// TestEndStream tests ending a stream with an error.
+func TestEndStream(t *testing.T) {
+	Convey("When a stream ends", t, func() {
+		stream := newMockStream()
+
+		Convey("Without an error, nothing should be sent", func() {
+			So(endStream(stream, nil), ShouldBeNil)
+			So(stream.sendChan, ShouldBeEmpty)
+		})
+
+		Convey("With an error, the client should be told why, and get the status code", func() {
+			err := endStream(stream, invalidRequestError("Bad request"))
+			So(grpc.Code(err), ShouldEqual, codes.InvalidArgument)
+			So(grpc.ErrorDesc(err), ShouldEqual, "Bad request")
+
+			res, err := stream.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetError().Reason, ShouldEqual, Error_INVALID_REQUEST)
+			So(res.GetError().Message, ShouldEqual, "Bad request")
+		})
+
+		Convey("With an error that already has a status, it should be passed on as is", func() {
+			err := grpc.Errorf(codes.Unavailable, "Gone")
+			So(endStream(stream, err), ShouldEqual, err)
+			So(stream.sendChan, ShouldBeEmpty)
+		})
+
+		Convey("When the client cancelled, nothing should be sent", func() {
+			err := endStream(stream, context.Canceled)
+			So(grpc.Code(err), ShouldEqual, codes.Canceled)
+			So(stream.sendChan, ShouldBeEmpty)
+		})
+	})
+}
+
+// TestGameInvalidRequest tests a game that is started without joining.
+func TestGameInvalidRequest(t *testing.T) {
+	Convey("When a player starts a game by pressing a colour", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+
+		stream := newMockStream()
+		err := stream.PushRecv(&Request{Event: &Request_Press{Press: Color_RED}})
+		So(err, ShouldBeNil)
+
+		Convey("They should be told it's an invalid request", func() {
+			So(grpc.Code(game.Game(stream)), ShouldEqual, codes.InvalidArgument)
+			So(stream, shouldError, Error_INVALID_REQUEST)
+		})
+	})
+}
+
+// shouldError asserts that the next Response is an ERROR with the given reason.
+func shouldError(stream interface{}, args ...interface{}) string {
+	res, err := stream.(*mockStream).PullSend()
+	if err != nil {
+		return fmt.Sprintf("Error should be nil. %v", err)
+	}
+
+	if res.GetError() == nil || res.GetError().Reason != args[0].(Error_Reason) {
+		return fmt.Sprintf("Response %v != Error %v", res, args[0])
+	}
+
+	return ""
+}
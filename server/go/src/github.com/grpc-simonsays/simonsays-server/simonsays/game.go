@@ -16,23 +16,66 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Game represents a Game that an individual player is playing.
 // It keeps track of internal Game state for that player.
 // Only use exported methods are guaranteed to be concurrently safe.
 type Game struct {
-	ID             string
+	// the sequence number of the last event seen on the game's topic.
+	// Accessed atomically, so it is kept first for alignment.
+	sequence int64
+	ID       string
+	Size     int
+	// the rating of the player that started the game, that others are matched against.
+	Rating int
+	// if this player started the game, rather than joining it.
+	Host bool
+	// the code of the private room the game is in, if it is in one.
+	Room string
+	// the server the game can only be found on, if players are only
+	// matched with others on the same server.
+	Scope          string
+	players        []string
 	currentPresses []Color
 	validPresses   []Color
 	myTurn         bool
-	mu             sync.RWMutex
+	echo           bool
+	// how long a player has for their whole turn, and between each press.
+	// Zero means there is no limit.
+	turnTimeout  time.Duration
+	pressTimeout time.Duration
+	turnDeadline time.Time
+	lastPress    time.Time
+	// if set, the sequence is played back at this pace at the start of each turn.
+	replayPace time.Duration
+	// the sequence being played back before the player's turn starts,
+	// and how many of its colours have been played back so far.
+	playback []Color
+	played   int
+	// the turn the game is on. The round counts up with each turn,
+	// and current is the player whose turn it is, who has to match
+	// the turn's sequence.
+	round        int
+	current      string
+	turnSequence []Color
+	turnStart    time.Time
+	// if this player has lost the game.
+	lost bool
+	mu   sync.RWMutex
 }
 
+const (
+	// minPlayers is the smallest number of players a Game can have.
+	minPlayers = 2
+	// maxPlayers is the largest number of players a Game can have.
+	maxPlayers = 8
+)
+
 // ErrColorPressedOutOfTurn is returned when a colour is pressed outside
 // of the Game player's turn.
 var ErrColorPressedOutOfTurn = errors.New("Color pressed outside of player turn")
@@ -41,6 +84,8 @@ var ErrColorPressedOutOfTurn = errors.New("Color pressed outside of player turn"
 func NewGame(id string) *Game {
 	return &Game{
 		ID:     id,
+		Size:   minPlayers,
+		Rating: initialRating,
 		myTurn: false,
 	}
 }
@@ -54,6 +99,133 @@ func (g *Game) StartTurn(p []Color) {
 	g.validPresses = p
 	g.currentPresses = nil
 	g.myTurn = true
+	g.echo = false
+	g.startClock()
+}
+
+// StartEchoTurn starts the player's turn, where they only need to
+// repeat the sequence of Colors, and not add one of their own.
+func (g *Game) StartEchoTurn(p []Color) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.validPresses = p
+	g.currentPresses = nil
+	g.myTurn = true
+	g.echo = true
+	g.startClock()
+}
+
+// SetTimeouts sets how long the player has for each turn, and between
+// each press in a turn. Zero means there is no limit.
+func (g *Game) SetTimeouts(turn, press time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.turnTimeout = turn
+	g.pressTimeout = press
+}
+
+// SetReplay sets the pace to play back the sequence at the start of each turn.
+// Zero means the sequence is not played back.
+func (g *Game) SetReplay(pace time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.replayPace = pace
+}
+
+// ReplayPace returns the pace to play back the sequence at the start of each turn.
+// Returns zero if the sequence should not be played back.
+func (g *Game) ReplayPace() time.Duration {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.replayPace
+}
+
+// QueuePlayback queues the sequence to be played back, one Color at a time,
+// before the player's turn starts.
+func (g *Game) QueuePlayback(p []Color) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.playback = p
+	g.played = 0
+}
+
+// NextPlayback returns the next Color of the sequence being played back. Once it is
+// the last one, the whole sequence is returned with it, and the playback is over.
+// Returns false if nothing is being played back.
+func (g *Game) NextPlayback() (Color, []Color, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.played >= len(g.playback) {
+		return 0, nil, false
+	}
+
+	c := g.playback[g.played]
+	g.played++
+
+	if g.played < len(g.playback) {
+		return c, nil, true
+	}
+
+	p := g.playback
+	g.playback = nil
+	g.played = 0
+	return c, p, true
+}
+
+// startClock starts the deadlines for this turn.
+func (g *Game) startClock() {
+	now := time.Now()
+	g.turnDeadline = now.Add(g.turnTimeout)
+	g.lastPress = now
+}
+
+// deadline is an unlocked version of Deadline.
+func (g *Game) deadline() time.Time {
+	var d time.Time
+
+	if g.turnTimeout > 0 {
+		d = g.turnDeadline
+	}
+
+	if g.pressTimeout > 0 {
+		if p := g.lastPress.Add(g.pressTimeout); d.IsZero() || p.Before(d) {
+			d = p
+		}
+	}
+
+	return d
+}
+
+// Deadline returns the time by which the player has to press their next colour.
+// Returns the zero time if there are no deadlines for this game.
+func (g *Game) Deadline() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.deadline()
+}
+
+// ExpireTurn ends the player's turn if it is past its deadline.
+// Returns true if the turn was ended.
+func (g *Game) ExpireTurn(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.myTurn {
+		return false
+	}
+
+	d := g.deadline()
+	if d.IsZero() || !now.After(d) {
+		return false
+	}
+
+	g.myTurn = false
+	return true
 }
 
 // pressColor is an unlocked version of PressColor.
@@ -63,8 +235,15 @@ func (g *Game) pressColor(c Color) error {
 	}
 
 	g.currentPresses = append(g.currentPresses, c)
+	g.lastPress = time.Now()
+
+	// unless echoing, the player adds one more colour to the sequence.
+	n := len(g.validPresses)
+	if !g.echo {
+		n++
+	}
 
-	if len(g.currentPresses)-1 == len(g.validPresses) || !g.match() {
+	if len(g.currentPresses) == n || !g.match() {
 		g.myTurn = false
 	}
 
@@ -76,30 +255,160 @@ func (g *Game) pressColor(c Color) error {
 // Returns a ErrColorPressedOutOfTurn if it not this player's turn
 // Will set IsMyTurn() to false when the number of currentPresses is
 // one more than the current validPresses (what colours the last player pressed) value,
-// or colours don't match up to the previous turn's colour sequence.
+// (or the same, for an echo turn), or colours don't match up to the previous turn's colour sequence.
 func (g *Game) PressColor(c Color) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	return g.pressColor(c)
 }
 
-// encodePresses is the unlocked version of EncodePresses.
-func (g *Game) encodePresses() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	err := gob.NewEncoder(buf).Encode(g.currentPresses)
-	if err != nil {
-		return nil, err
+// SetPlayers sets the order in which players take their turns.
+func (g *Game) SetPlayers(p []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.players = p
+}
+
+// Players returns the ids of the players still in the Game, in turn order.
+func (g *Game) Players() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return append([]string(nil), g.players...)
+}
+
+// NextPlayer returns the id of the player whose turn comes after
+// the given player. Returns an empty string if the player is not in the Game.
+func (g *Game) NextPlayer(id string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for i, p := range g.players {
+		if p == id {
+			return g.players[(i+1)%len(g.players)]
+		}
+	}
+
+	return ""
+}
+
+// RemovePlayer takes a player out of the turn order, for
+// when they have been eliminated.
+func (g *Game) RemovePlayer(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i, p := range g.players {
+		if p == id {
+			g.players = append(g.players[:i:i], g.players[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetLost marks that this player has lost the game.
+func (g *Game) SetLost() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lost = true
+}
+
+// Lost returns if this player has lost the game.
+func (g *Game) Lost() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.lost
+}
+
+// ValidPresses returns the sequence of Colors the
+// player needed to match this turn.
+func (g *Game) ValidPresses() []Color {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]Color{}, g.validPresses...)
+}
+
+// NextTurn moves the game on to the turn of the current player, who has to
+// match the sequence of Colors. Returns the round the game is now on.
+func (g *Game) NextTurn(current string, p []Color) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.round++
+	g.current = current
+	g.turnSequence = p
+	g.turnStart = time.Now()
+
+	return g.round
+}
+
+// Turn returns the round the game is on, the player whose turn it is,
+// and how many Colors they have to press this turn.
+func (g *Game) Turn() (int, string, int) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	length := len(g.turnSequence) + 1
+	if g.myTurn && g.echo {
+		length--
 	}
 
-	return buf.Bytes(), nil
+	return g.round, g.current, length
 }
 
-// EncodePresses encodes the current set of Color
-// presses into an []bytes for transmission.
-func (g *Game) EncodePresses() ([]byte, error) {
+// TurnDeadline returns the time by which the player whose turn it is has to press
+// their first colour, as far as the other players can tell.
+// Returns the zero time if there are no deadlines for this game.
+func (g *Game) TurnDeadline() time.Time {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
-	return g.encodePresses()
+
+	var d time.Time
+
+	if g.turnTimeout > 0 {
+		d = g.turnStart.Add(g.turnTimeout)
+	}
+
+	if g.pressTimeout > 0 {
+		if p := g.turnStart.Add(g.pressTimeout); d.IsZero() || p.Before(d) {
+			d = p
+		}
+	}
+
+	return d
+}
+
+// TurnSequence returns the sequence of Colors the player
+// whose turn it is has to match.
+func (g *Game) TurnSequence() []Color {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]Color{}, g.turnSequence...)
+}
+
+// Presses returns the current set of Color presses.
+func (g *Game) Presses() []Color {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]Color{}, g.currentPresses...)
+}
+
+// NextSequence returns the sequence number for the next
+// event published to the game's topic.
+func (g *Game) NextSequence() int64 {
+	return atomic.AddInt64(&g.sequence, 1)
+}
+
+// ObserveSequence keeps track of the sequence number of an event
+// received from the game's topic, so ours carry on from it.
+func (g *Game) ObserveSequence(n int64) {
+	for {
+		last := atomic.LoadInt64(&g.sequence)
+		if n <= last || atomic.CompareAndSwapInt64(&g.sequence, last, n) {
+			return
+		}
+	}
 }
 
 // isMyTurn is a unlocked version if IsMyTurn.
@@ -16,9 +16,8 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -126,8 +125,8 @@ func TestGame(t *testing.T) {
 	})
 }
 
-// TestEncodePresses make sure we can encode this for passing around.
-func TestEncodePresses(t *testing.T) {
+// TestPresses make sure we can get the presses for passing around.
+func TestPresses(t *testing.T) {
 	Convey("When you have a game", t, func() {
 		game := NewGame("hello world")
 		colors := []Color{Color_GREEN, Color_BLUE}
@@ -139,17 +138,199 @@ func TestEncodePresses(t *testing.T) {
 				So(err, ShouldBeNil)
 			}
 
-			Convey("The encoded presses should match the actual colors", func() {
-				b, err := game.EncodePresses()
-				So(err, ShouldBeNil)
-				buf := bytes.NewBuffer(b)
+			Convey("The presses should match the actual colors", func() {
+				So(game.Presses(), ShouldResemble, colors)
+				So(game.ValidPresses(), ShouldResemble, colors)
+			})
+		})
+	})
+}
+
+// TestSequence tests out numbering the events of a game.
+func TestSequence(t *testing.T) {
+	Convey("When you have a game", t, func() {
+		game := NewGame("hello world")
+
+		Convey("Each event we send should be numbered one after the other", func() {
+			So(game.NextSequence(), ShouldEqual, 1)
+			So(game.NextSequence(), ShouldEqual, 2)
+
+			Convey("And carry on from the events we receive", func() {
+				game.ObserveSequence(5)
+				So(game.NextSequence(), ShouldEqual, 6)
+
+				game.ObserveSequence(3)
+				So(game.NextSequence(), ShouldEqual, 7)
+			})
+		})
+	})
+}
+
+// TestTurnOrder tests out the order in which players take their turns.
+func TestTurnOrder(t *testing.T) {
+	Convey("When you have a game with three players", t, func() {
+		game := NewGame("hello world")
+		game.SetPlayers([]string{"Player One", "Player Two", "Player Three"})
+
+		Convey("Turns go round in order", func() {
+			So(game.NextPlayer("Player One"), ShouldEqual, "Player Two")
+			So(game.NextPlayer("Player Two"), ShouldEqual, "Player Three")
+			So(game.NextPlayer("Player Three"), ShouldEqual, "Player One")
+			So(game.NextPlayer("Player Four"), ShouldBeEmpty)
+		})
 
-				result := new([]Color)
-				err = gob.NewDecoder(buf).Decode(result)
+		Convey("And a player is eliminated", func() {
+			game.RemovePlayer("Player Two")
+			So(game.Players(), ShouldResemble, []string{"Player One", "Player Three"})
+			So(game.NextPlayer("Player One"), ShouldEqual, "Player Three")
+			So(game.NextPlayer("Player Three"), ShouldEqual, "Player One")
+		})
+	})
+}
+
+// TestEchoTurn tests out a turn where the player only repeats the sequence.
+func TestEchoTurn(t *testing.T) {
+	Convey("When you have a game", t, func() {
+		game := NewGame("hello world")
+		colors := []Color{Color_GREEN, Color_BLUE}
+		game.StartEchoTurn(colors)
+		So(game.IsMyTurn(), ShouldBeTrue)
+
+		Convey("The turn ends once the sequence has been repeated", func() {
+			err := game.PressColor(colors[0])
+			So(err, ShouldBeNil)
+			So(game.IsMyTurn(), ShouldBeTrue)
+
+			err = game.PressColor(colors[1])
+			So(err, ShouldBeNil)
+			So(game.IsMyTurn(), ShouldBeFalse)
+			So(game.Match(), ShouldBeTrue)
+		})
+
+		Convey("The turn ends when the wrong colour is pressed", func() {
+			err := game.PressColor(Color_RED)
+			So(err, ShouldBeNil)
+			So(game.IsMyTurn(), ShouldBeFalse)
+			So(game.Match(), ShouldBeFalse)
+		})
+
+		Convey("A normal turn afterwards still needs an extra colour", func() {
+			game.StartTurn(colors)
+			for _, c := range colors {
+				err := game.PressColor(c)
 				So(err, ShouldBeNil)
+			}
+			So(game.IsMyTurn(), ShouldBeTrue)
+		})
+	})
+}
 
-				So(result, ShouldResemble, &colors)
+// TestDeadlines tests out expiring a turn when the player runs out of time.
+func TestDeadlines(t *testing.T) {
+	Convey("When you have a game without timeouts", t, func() {
+		game := NewGame("hello world")
+		game.StartTurn([]Color{Color_GREEN})
+
+		Convey("There is no deadline, and the turn never expires", func() {
+			So(game.Deadline().IsZero(), ShouldBeTrue)
+			So(game.ExpireTurn(time.Now().Add(time.Hour)), ShouldBeFalse)
+			So(game.IsMyTurn(), ShouldBeTrue)
+		})
+	})
+
+	Convey("When you have a game with a turn timeout", t, func() {
+		game := NewGame("hello world")
+		game.SetTimeouts(time.Minute, 0)
+		start := time.Now()
+		game.StartTurn([]Color{Color_GREEN})
+
+		Convey("The deadline is the end of the turn", func() {
+			So(game.Deadline(), ShouldHappenWithin, time.Second, start.Add(time.Minute))
+		})
+
+		Convey("The turn doesn't expire before the deadline", func() {
+			So(game.ExpireTurn(start.Add(30*time.Second)), ShouldBeFalse)
+			So(game.IsMyTurn(), ShouldBeTrue)
+		})
+
+		Convey("The turn expires after the deadline", func() {
+			So(game.ExpireTurn(start.Add(2*time.Minute)), ShouldBeTrue)
+			So(game.IsMyTurn(), ShouldBeFalse)
+
+			Convey("But only once", func() {
+				So(game.ExpireTurn(start.Add(2*time.Minute)), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("When you have a game with a press timeout", t, func() {
+		game := NewGame("hello world")
+		game.SetTimeouts(time.Minute, time.Second)
+		game.StartTurn([]Color{Color_GREEN, Color_BLUE})
+
+		Convey("The deadline is the next press", func() {
+			So(game.Deadline(), ShouldHappenWithin, time.Second, time.Now().Add(time.Second))
+		})
+
+		Convey("Pressing a colour moves the deadline", func() {
+			before := game.Deadline()
+			time.Sleep(10 * time.Millisecond)
+			err := game.PressColor(Color_GREEN)
+			So(err, ShouldBeNil)
+			So(game.Deadline(), ShouldHappenAfter, before)
+		})
+
+		Convey("The turn expires if you wait too long between presses", func() {
+			So(game.ExpireTurn(time.Now().Add(2*time.Second)), ShouldBeTrue)
+			So(game.IsMyTurn(), ShouldBeFalse)
+		})
+	})
+}
+
+// TestTurns tests out keeping track of the turn the game is on.
+func TestTurns(t *testing.T) {
+	Convey("When you have a game that has just begun", t, func() {
+		game := NewGame("hello world")
+		game.SetPlayers([]string{"Player One", "Player Two"})
+
+		Convey("The first turn is round 1, with one colour to press", func() {
+			So(game.NextTurn("Player One", nil), ShouldEqual, 1)
+
+			round, current, length := game.Turn()
+			So(round, ShouldEqual, 1)
+			So(current, ShouldEqual, "Player One")
+			So(length, ShouldEqual, 1)
+
+			Convey("And each turn after is the next round, with the sequence to match and one more", func() {
+				So(game.NextTurn("Player Two", []Color{Color_GREEN}), ShouldEqual, 2)
+
+				round, current, length := game.Turn()
+				So(round, ShouldEqual, 2)
+				So(current, ShouldEqual, "Player Two")
+				So(length, ShouldEqual, 2)
+				So(game.TurnSequence(), ShouldResemble, []Color{Color_GREEN})
 			})
 		})
+
+		Convey("On an echo turn, only the sequence has to be pressed", func() {
+			seq := []Color{Color_GREEN, Color_RED}
+			game.NextTurn("Player One", seq)
+			game.StartEchoTurn(seq)
+
+			_, _, length := game.Turn()
+			So(length, ShouldEqual, 2)
+		})
+
+		Convey("Without timeouts, there is no turn deadline", func() {
+			game.NextTurn("Player Two", nil)
+			So(game.TurnDeadline().IsZero(), ShouldBeTrue)
+		})
+
+		Convey("With timeouts, the turn deadline is the first press of the turn", func() {
+			game.SetTimeouts(time.Minute, time.Second)
+			start := time.Now()
+			game.NextTurn("Player Two", nil)
+			So(game.TurnDeadline(), ShouldHappenWithin, 100*time.Millisecond, start.Add(time.Second))
+		})
 	})
 }
@@ -16,40 +16,48 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
 
-	"github.com/garyburd/redigo/redis"
 	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
 )
 
 const (
 	// begin the game
-	beginMessage = "BEGIN"
+	beginMessage = Event_BEGIN
 	// stop a turn
-	stopTurnMessage = "STOP_TURN"
+	stopTurnMessage = Event_STOP_TURN
 	// light up a colour
-	lightUpMessage = "LIGHTUP"
+	lightUpMessage = Event_LIGHTUP
 	// This player has lost
-	lostMessage = "LOST_MESSAGE"
+	lostMessage = Event_LOST
+	// This player's stream has dropped
+	reconnectingMessage = Event_RECONNECTING
+	// This player has resumed the game
+	reconnectedMessage = Event_RECONNECTED
 )
 
-// Handler handles a Message that comes through redis pub/sub.
-type handler func(redis.Conn, *Game, *Request_Player, SimonSays_GameServer, *message) error
+// errNoPlayers is returned when a game begins without a turn order to play in.
+var errNoPlayers = errors.New("Game began without any players")
+
+// Handler handles a Message that comes through the broker.
+type handler func(Broker, *Game, *Request_Player, SimonSays_GameServer, *message) error
 
 // map of handlers for each message type
-var handlers = map[string]handler{
+var handlers = map[Event_Type]handler{
 	beginMessage:    beginHandler,
 	stopTurnMessage: stopTurnHandler,
 	lightUpMessage:  lightUpHandler,
 	lostMessage:     lostHandler,
+
+	reconnectingMessage: reconnectHandler,
+	reconnectedMessage:  reconnectHandler,
 }
 
 // handle Processing pub/sub events and does things with them
 // Think "controller".
-func handle(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+func handle(broker Broker, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
 	lc := "Handler"
 	ctx := stream.Context()
 	logger.Info(ctx, lc, "Handling Message: %#v", msg)
@@ -57,17 +65,36 @@ func handle(con redis.Conn, game *Game, player *Request_Player, stream SimonSays
 
 	if !ok {
 		logger.Error(ctx, lc, "Could not find a handler for this event. %#v", msg)
-		return handlerNotFoundError("msg.Type")
+		return handlerNotFoundError(msg.Type.String())
 	}
 
-	return fn(con, game, player, stream, msg)
+	return fn(broker, game, player, stream, msg)
 }
 
-// beginHandler Streams BEGIN to client once we are good to go.
-func beginHandler(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+// beginHandler Streams BEGIN to client once we are good to go,
+// and sets up the order of play.
+func beginHandler(broker Broker, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
 	lc := "beginHandler"
 	ctx := stream.Context()
-	res := &Response{Event: &Response_Turn{Turn: Response_BEGIN}}
+
+	// there is nobody to start the first round, so the game can't be played.
+	if len(msg.Players) == 0 {
+		logger.Error(ctx, lc, "Game began without a turn order. %#v", msg)
+		return errNoPlayers
+	}
+
+	logger.Info(ctx, lc, "Turn order is: %v", msg.Players)
+	game.SetPlayers(msg.Players)
+
+	// the first player in the order is about to start the first round.
+	res := &Response{
+		Event:     &Response_Turn{Turn: Response_BEGIN},
+		Game:      game.ID,
+		Round:     1,
+		Length:    1,
+		Current:   msg.Players[0],
+		Opponents: opponents(game, player),
+	}
 
 	err := sendResponse(stream, res)
 
@@ -76,52 +103,126 @@ func beginHandler(con redis.Conn, game *Game, player *Request_Player, stream Sim
 		return err
 	}
 
-	// if not the player that BEGAN (so first player to join), then START your turn
+	// if the player that BEGAN (so last player to join), then stop your turn,
+	// which will START the turn of the first player in the order.
 	if msg.Player == player.Id {
 		logger.Info(ctx, lc, "Publishing end turn %v", stopTurnMessage)
-		return publish(ctx, con, game, message{Player: player.Id, Type: stopTurnMessage, Data: msg.Data})
+		return publish(ctx, broker, game, message{Player: player.Id, Type: stopTurnMessage, Colors: game.Presses()})
 	}
 
 	logger.Info(ctx, lc, "Not doing anything with Begin. It's not my job.")
 	return nil
 }
 
-// stopTurnHandler My turn has finished, so, tell the other player
-// to START_TURN, and me to END_TURN.
-func stopTurnHandler(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+// stopTurnHandler A turn has finished, so, tell the next player
+// to START_TURN, and the player that finished to END_TURN.
+func stopTurnHandler(broker Broker, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
 	lc := "stopTurnHandler"
 	ctx := stream.Context()
 
+	next := game.NextPlayer(msg.Player)
+	game.NextTurn(next, msg.Colors)
+
 	// if I'm the player that sent out the message, let the client know
 	if player.Id == msg.Player {
-		return sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_STOP_TURN}})
+		return sendResponse(stream, turnResponse(game, player, Response_STOP_TURN))
 	}
 
-	// otherwise, it's time for the other player to start
+	// if I'm not next, then there is nothing to do.
+	if next != player.Id {
+		logger.Info(ctx, lc, "Not my turn next. Ignoring.")
+		return nil
+	}
 
-	buf := bytes.NewBuffer(msg.Data)
-	c := []Color{}
-	err := gob.NewDecoder(buf).Decode(&c)
+	// otherwise, it's time for me to start
+	return startTurn(game, player, stream, msg)
+}
 
-	if err != nil {
-		logger.Error(ctx, lc, "Error decoding message colors. %#v. %v", msg, err)
+// startTurn starts this player's turn, with the sequence of Colors held in the message.
+// If the player wants the sequence played back, it is queued up instead, and the turn
+// starts once playNext has played it all back.
+func startTurn(game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+	lc := "startTurn"
+	ctx := stream.Context()
+
+	c := msg.Colors
+
+	// play back the sequence first, if the player wants it.
+	if game.ReplayPace() > 0 && len(c) > 0 {
+		logger.Info(ctx, lc, "Playing back sequence: %v", c)
+		game.QueuePlayback(c)
+		return nil
+	}
+
+	return beginTurn(game, player, stream, c)
+}
+
+// playNext plays back the next Color of the sequence queued up by startTurn, if there
+// is one. Once the whole sequence has been played back, the player's turn starts.
+func playNext(game *Game, player *Request_Player, stream SimonSays_GameServer) error {
+	c, seq, ok := game.NextPlayback()
+	if !ok {
+		return nil
+	}
+
+	if err := sendResponse(stream, &Response{Event: &Response_Lightup{Lightup: c}, Playback: true}); err != nil {
 		return err
 	}
 
-	logger.Info(ctx, lc, "Starting turn with colors: %v", c)
+	if seq == nil {
+		return nil
+	}
+	return beginTurn(game, player, stream, seq)
+}
+
+// beginTurn starts the player's turn, with the sequence of Colors to match.
+func beginTurn(game *Game, player *Request_Player, stream SimonSays_GameServer, c []Color) error {
+	logger.Info(stream.Context(), "startTurn", "Starting turn with colors: %v", c)
 	game.StartTurn(c)
-	return sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_START_TURN}})
+	return sendResponse(stream, turnResponse(game, player, Response_START_TURN))
+}
+
+// turnResponse is the turn event for the player, with the details of the turn
+// the game is on. If there is a deadline, it's the one for the player's next
+// press on their own turn, or for the first press of someone else's turn.
+func turnResponse(game *Game, player *Request_Player, state Response_State) *Response {
+	round, current, length := game.Turn()
+	res := &Response{
+		Event:     &Response_Turn{Turn: state},
+		Round:     int32(round),
+		Length:    int32(length),
+		Current:   current,
+		Opponents: opponents(game, player),
+	}
+
+	d := game.TurnDeadline()
+	if current == player.Id {
+		d = game.Deadline()
+	}
+	if !d.IsZero() {
+		res.Deadline = millis(d)
+	}
+
+	return res
+}
+
+// opponents are the other players still in the game, in turn order.
+func opponents(game *Game, player *Request_Player) []string {
+	var o []string
+	for _, p := range game.Players() {
+		if p != player.Id {
+			o = append(o, p)
+		}
+	}
+	return o
 }
 
 // lightUpHandler handles LIGHTUP events, letting everyone know to lightup
 // their colours.
-func lightUpHandler(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+func lightUpHandler(broker Broker, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
 	lc := "lightUpHandler"
 	ctx := stream.Context()
-	c := new(Color)
-	buf := bytes.NewBuffer(msg.Data)
-
-	err := gob.NewDecoder(buf).Decode(c)
+	c, err := lightUpColor(msg)
 
 	if err != nil {
 		logger.Error(ctx, lc, "Could not convert colour. %#v. %v", msg, err)
@@ -130,35 +231,86 @@ func lightUpHandler(con redis.Conn, game *Game, player *Request_Player, stream S
 
 	logger.Info(ctx, lc, "Sending stream response to Light Up %v", c)
 
-	return sendResponse(stream, &Response{Event: &Response_Lightup{Lightup: *c}})
+	return sendResponse(stream, &Response{Event: &Response_Lightup{Lightup: c}})
 }
 
-// what happens when the game is lost. Returns io.EOF to show that the game should be shut down.
-func lostHandler(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+// lightUpColor is the colour a LIGHTUP message is for.
+func lightUpColor(msg *message) (Color, error) {
+	if len(msg.Colors) != 1 {
+		return 0, fmt.Errorf("Light up should have one colour, had %v", len(msg.Colors))
+	}
+	return msg.Colors[0], nil
+}
+
+// what happens when a player has lost. The player that lost is eliminated,
+// and the last player standing wins. Returns io.EOF to show that the game should
+// be shut down for this player.
+func lostHandler(broker Broker, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
 	lc := "lostHandler"
 	ctx := stream.Context()
 
 	logger.Info(ctx, lc, "Received Lost Event: %#v", msg)
 
-	var err error
-
 	// if I lost...
-	turn := Response_WIN
 	if msg.Player == player.Id {
-		turn = Response_LOSE
+		game.SetLost()
+		err := sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_LOSE}})
+		if err != nil {
+			return err
+		}
+		return io.EOF
 	}
 
-	err = sendResponse(stream, &Response{Event: &Response_Turn{Turn: turn}})
-	if err != nil {
-		return err
+	_, current, _ := game.Turn()
+	next := game.NextPlayer(msg.Player)
+	game.RemovePlayer(msg.Player)
+
+	// if I'm the last one standing, I win!
+	if len(game.Players()) == 1 {
+		gamesFinishedTotal.Inc(gameMode(game))
+		winsTotal.Inc(gameRole(game))
+		err := sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_WIN}})
+		if err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	logger.Info(ctx, lc, "Player %v eliminated. %v players remain.", msg.Player, len(game.Players()))
+
+	// a player can leave when it isn't their turn, in which case the turn carries on.
+	if msg.Player != current {
+		return nil
+	}
+
+	game.NextTurn(next, msg.Colors)
+
+	// if I was after the player that lost, it's my turn to take on their sequence.
+	if next == player.Id {
+		return startTurn(game, player, stream, msg)
+	}
+
+	return nil
+}
+
+// reconnectHandler lets the client know another player's stream
+// has dropped, or that they have resumed the game.
+func reconnectHandler(broker Broker, game *Game, player *Request_Player, stream SimonSays_GameServer, msg *message) error {
+	if msg.Player == player.Id {
+		return nil
+	}
+
+	state := Response_RECONNECTING
+	if msg.Type == reconnectedMessage {
+		state = Response_RECONNECTED
 	}
 
-	return io.EOF
+	return sendResponse(stream, &Response{Event: &Response_Turn{Turn: state}, Player: msg.Player})
 }
 
 type handlerNotFoundError string
 
 // Error returns the string representation of a handlerNotFoundError.
 func (h handlerNotFoundError) Error() string {
-	return fmt.Sprintf("Could not find handler for Data event: %s", h)
+	return fmt.Sprintf("Could not find handler for Data event: %s", string(h))
 }
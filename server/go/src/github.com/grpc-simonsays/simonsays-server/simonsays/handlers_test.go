@@ -16,31 +16,30 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
+	"io"
 	"testing"
 	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
 )
 
 // TestBeginHandler test the begin handler.
 func TestBeginHandler(t *testing.T) {
 	stream := newMockStream()
-	server := mustSimonSays()
-	defer server.Close()
+	broker := NewMemoryBroker()
 
 	Convey("When you have a begin event", t, func() {
 		player := &Request_Player{Id: "Player One"}
-		data := []byte("This is my data")
-		msg := &message{Type: beginMessage, Data: data}
+		players := []string{"Player Two", "Player One"}
+		msg := &message{Type: beginMessage, Players: players}
 		game := NewGame("game one")
 
-		c, err := subscribe(stream.Context(), server.pool.Get(), game)
+		c, _, err := subscribe(stream.Context(), broker, game)
 		So(err, ShouldBeNil)
 
 		Convey("And it's not your player sending the event", func() {
-			err = beginHandler(server.pool.Get(), game, player, stream, msg)
+			err = beginHandler(broker, game, player, stream, msg)
 			So(err, ShouldBeNil)
 
 			res, err := stream.PullSend()
@@ -49,6 +48,10 @@ func TestBeginHandler(t *testing.T) {
 			turn, ok := res.Event.(*Response_Turn)
 			So(ok, ShouldBeTrue)
 			So(turn.Turn, ShouldEqual, Response_BEGIN)
+			So(res.Round, ShouldEqual, 1)
+			So(res.Length, ShouldEqual, 1)
+			So(res.Current, ShouldEqual, "Player Two")
+			So(res.Opponents, ShouldResemble, []string{"Player Two"})
 
 			select {
 			case <-c:
@@ -58,8 +61,8 @@ func TestBeginHandler(t *testing.T) {
 		})
 
 		Convey("and the player is sending the event", func() {
-			msg := &message{Type: beginMessage, Player: player.Id, Data: data}
-			err = beginHandler(server.pool.Get(), game, player, stream, msg)
+			msg := &message{Type: beginMessage, Player: player.Id, Players: players}
+			err = beginHandler(broker, game, player, stream, msg)
 			So(err, ShouldBeNil)
 
 			res, err := stream.PullSend()
@@ -73,34 +76,36 @@ func TestBeginHandler(t *testing.T) {
 			case msg := <-c:
 				So(msg.Player, ShouldEqual, "Player One")
 				So(msg.Type, ShouldEqual, stopTurnMessage)
-				So(msg.Data, ShouldResemble, data)
+				So(msg.Colors, ShouldBeEmpty)
 			case <-time.After(2 * time.Second):
 				So(true, ShouldBeNil)
 			}
 		})
 
+		Convey("And it has no players", func() {
+			msg := &message{Type: beginMessage, Player: player.Id}
+			err = beginHandler(broker, game, player, stream, msg)
+			So(err, ShouldEqual, errNoPlayers)
+			So(game.Players(), ShouldBeEmpty)
+		})
 	})
 }
 
 // testStopTurnHandler test the begin handler.
 func TestStopTurnHandler(t *testing.T) {
 	stream := newMockStream()
-	server := mustSimonSays()
-	defer server.Close()
+	broker := NewMemoryBroker()
 
 	Convey("When you have a stop turn event", t, func() {
 		player := &Request_Player{Id: "Player One"}
 		cols := []Color{Color_GREEN, Color_BLUE}
-		buf := new(bytes.Buffer)
-		err := gob.NewEncoder(buf).Encode(cols)
-		So(err, ShouldBeNil)
-
-		msg := &message{Type: stopTurnMessage, Data: buf.Bytes()}
+		msg := &message{Type: stopTurnMessage, Player: "Player Two", Colors: cols}
 		game := NewGame("game one")
+		game.SetPlayers([]string{"Player Two", "Player One", "Player Three"})
 		So(game.IsMyTurn(), ShouldBeFalse)
 
-		Convey("And it's not your player sending the event", func() {
-			err := stopTurnHandler(server.pool.Get(), game, player, stream, msg)
+		Convey("And it's the player before you sending the event", func() {
+			err := stopTurnHandler(broker, game, player, stream, msg)
 			So(err, ShouldBeNil)
 
 			res, err := stream.PullSend()
@@ -110,11 +115,67 @@ func TestStopTurnHandler(t *testing.T) {
 			So(ok, ShouldBeTrue)
 			So(turn.Turn, ShouldEqual, Response_START_TURN)
 			So(game.IsMyTurn(), ShouldBeTrue)
+			So(res.Round, ShouldEqual, 1)
+			So(res.Length, ShouldEqual, 3)
+			So(res.Current, ShouldEqual, player.Id)
+			So(res.Opponents, ShouldResemble, []string{"Player Two", "Player Three"})
+		})
+
+		Convey("And you want the sequence played back", func() {
+			game.SetReplay(time.Millisecond)
+			err := stopTurnHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+
+			// it's played back a colour at a time, before the turn starts.
+			So(game.IsMyTurn(), ShouldBeFalse)
+			for _, c := range cols {
+				So(playNext(game, player, stream), ShouldBeNil)
+				res, err := stream.PullSend()
+				So(err, ShouldBeNil)
+				So(res.GetLightup(), ShouldEqual, c)
+				So(res.Playback, ShouldBeTrue)
+			}
+
+			So(stream, shouldState, Response_START_TURN)
+			So(game.IsMyTurn(), ShouldBeTrue)
+
+			// and there is nothing left to play back.
+			So(playNext(game, player, stream), ShouldBeNil)
+			select {
+			case res := <-stream.sendChan:
+				So(res, ShouldBeNil)
+			default:
+			}
+		})
+
+		Convey("And the stream drops while the sequence is played back", func() {
+			stream := newMockStream()
+			game.SetReplay(time.Minute)
+			err := stopTurnHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+
+			stream.Disconnect()
+			So(playNext(game, player, stream), ShouldEqual, context.Canceled)
+			So(game.IsMyTurn(), ShouldBeFalse)
+		})
+
+		Convey("And it's not the player before you sending the event", func() {
+			msg := &message{Type: stopTurnMessage, Player: "Player Three", Colors: cols}
+			err := stopTurnHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(game.IsMyTurn(), ShouldBeFalse)
+
+			select {
+			case res := <-stream.sendChan:
+				So(res, ShouldBeNil)
+			default:
+				// do nothing - there should be no response.
+			}
 		})
 
 		Convey("and the player is sending the event", func() {
-			msg := &message{Type: stopTurnMessage, Player: player.Id, Data: buf.Bytes()}
-			err := stopTurnHandler(server.pool.Get(), game, player, stream, msg)
+			msg := &message{Type: stopTurnMessage, Player: player.Id, Colors: cols}
+			err := stopTurnHandler(broker, game, player, stream, msg)
 			So(err, ShouldBeNil)
 
 			res, err := stream.PullSend()
@@ -124,6 +185,21 @@ func TestStopTurnHandler(t *testing.T) {
 			So(ok, ShouldBeTrue)
 			So(turn.Turn, ShouldEqual, Response_STOP_TURN)
 			So(game.IsMyTurn(), ShouldBeFalse)
+			So(res.Current, ShouldEqual, "Player Three")
+			So(res.Length, ShouldEqual, 3)
+			So(res.Deadline, ShouldEqual, 0)
+		})
+
+		Convey("and there are turn deadlines", func() {
+			game.SetTimeouts(time.Minute, 0)
+			msg := &message{Type: stopTurnMessage, Player: player.Id, Colors: cols}
+			err := stopTurnHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+
+			res, err := stream.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_STOP_TURN)
+			So(res.Deadline, ShouldEqual, game.TurnDeadline().UnixNano()/int64(time.Millisecond))
 		})
 
 	})
@@ -132,20 +208,15 @@ func TestStopTurnHandler(t *testing.T) {
 // TestLightUpHandler test the lightup handler.
 func TestLightUpHandler(t *testing.T) {
 	stream := newMockStream()
-	server := mustSimonSays()
-	defer server.Close()
+	broker := NewMemoryBroker()
 
 	Convey("When you have a Lightup event", t, func() {
 		game := NewGame("game one")
 		player := &Request_Player{Id: "Player One"}
-		buf := new(bytes.Buffer)
-		err := gob.NewEncoder(buf).Encode(Color_GREEN)
-		So(err, ShouldBeNil)
-
-		msg := &message{Type: lightUpMessage, Data: buf.Bytes()}
+		msg := &message{Type: lightUpMessage, Colors: []Color{Color_GREEN}}
 
 		Convey("we should recieve a Lightup gRPC message", func() {
-			err := lightUpHandler(server.pool.Get(), game, player, stream, msg)
+			err := lightUpHandler(broker, game, player, stream, msg)
 			So(err, ShouldBeNil)
 
 			res, err := stream.PullSend()
@@ -154,3 +225,90 @@ func TestLightUpHandler(t *testing.T) {
 		})
 	})
 }
+
+// TestLostHandler test the lost handler.
+func TestLostHandler(t *testing.T) {
+	stream := newMockStream()
+	broker := NewMemoryBroker()
+
+	Convey("When you have a lost event", t, func() {
+		player := &Request_Player{Id: "Player One"}
+		cols := []Color{Color_GREEN, Color_BLUE}
+		game := NewGame("game one")
+		game.SetPlayers([]string{"Player One", "Player Two", "Player Three"})
+
+		Convey("And it's your player that lost", func() {
+			msg := &message{Type: lostMessage, Player: player.Id, Colors: cols}
+			err := lostHandler(broker, game, player, stream, msg)
+			So(err, ShouldEqual, io.EOF)
+			So(stream, shouldState, Response_LOSE)
+		})
+
+		Convey("And it's the player before you that lost", func() {
+			game.NextTurn("Player Three", cols)
+			msg := &message{Type: lostMessage, Player: "Player Three", Colors: cols}
+			err := lostHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(stream, shouldState, Response_START_TURN)
+			So(game.IsMyTurn(), ShouldBeTrue)
+			So(game.Players(), ShouldResemble, []string{"Player One", "Player Two"})
+
+			Convey("And then the last other player loses", func() {
+				msg := &message{Type: lostMessage, Player: "Player Two", Colors: cols}
+				err := lostHandler(broker, game, player, stream, msg)
+				So(err, ShouldEqual, io.EOF)
+				So(stream, shouldState, Response_WIN)
+			})
+		})
+
+		Convey("And the player before you left when it wasn't their turn", func() {
+			game.NextTurn("Player Two", cols)
+			msg := &message{Type: lostMessage, Player: "Player Three", Colors: cols}
+			err := lostHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(game.IsMyTurn(), ShouldBeFalse)
+			So(game.Players(), ShouldResemble, []string{"Player One", "Player Two"})
+			So(stream.sendChan, ShouldBeEmpty)
+		})
+
+		Convey("And it's another player that lost", func() {
+			msg := &message{Type: lostMessage, Player: "Player Two", Colors: cols}
+			err := lostHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(game.IsMyTurn(), ShouldBeFalse)
+			So(game.Players(), ShouldResemble, []string{"Player One", "Player Three"})
+		})
+	})
+}
+
+// TestReconnectHandler test the reconnect handler.
+func TestReconnectHandler(t *testing.T) {
+	stream := newMockStream()
+	broker := NewMemoryBroker()
+
+	Convey("When another player's stream has dropped", t, func() {
+		player := &Request_Player{Id: "Player One"}
+		game := NewGame("game one")
+		msg := &message{Type: reconnectingMessage, Player: "Player Two"}
+
+		Convey("we should be told they are reconnecting", func() {
+			err := reconnectHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(stream, shouldPlayerState, Response_RECONNECTING, "Player Two")
+		})
+
+		Convey("and then told when they are back", func() {
+			msg := &message{Type: reconnectedMessage, Player: "Player Two"}
+			err := reconnectHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(stream, shouldPlayerState, Response_RECONNECTED, "Player Two")
+		})
+
+		Convey("but not about our own stream", func() {
+			msg := &message{Type: reconnectingMessage, Player: player.Id}
+			err := reconnectHandler(broker, game, player, stream, msg)
+			So(err, ShouldBeNil)
+			So(stream.sendChan, ShouldBeEmpty)
+		})
+	})
+}
@@ -17,7 +17,7 @@ package simonsays
 
 // mustSimonSays creates a new simon says. Panics otherwise.
 func mustSimonSays() *SimonSays {
-	game, err := NewSimonSays("")
+	game, err := NewSimonSays("", nil)
 
 	if err != nil {
 		panic(err)
@@ -0,0 +1,296 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// how long the history of a game, and a player's list of games, are kept for.
+const historyExpiry = 30 * 24 * 60 * 60
+
+const (
+	// maxRecentGames is how many games are kept in a player's list of games.
+	maxRecentGames = 100
+	// defaultListedGames is how many games are listed, if the request doesn't say.
+	defaultListedGames = 10
+)
+
+// History records the events of every game, so they can be looked at once it's over.
+type History interface {
+	// Record adds the event to the end of the game's history. The game is
+	// added to each player's list of games when it begins.
+	Record(ctx context.Context, game string, e *Event) error
+	// Events returns the events of the game, in the order they were recorded.
+	// Returns ErrGameNotFound if there is no history for the game.
+	Events(ctx context.Context, game string) ([]*Event, error)
+	// Games returns the ids of the player's most recent games, newest first.
+	Games(ctx context.Context, player string, limit int) ([]string, error)
+}
+
+// redisHistory is a History kept in Redis.
+type redisHistory struct {
+	pool *redis.Pool
+}
+
+// newRedisHistory creates a History kept in Redis.
+func newRedisHistory(pool *redis.Pool) History {
+	return &redisHistory{pool: pool}
+}
+
+// historyKey is the list of encoded events of a game.
+func historyKey(game string) string {
+	return "History:" + game
+}
+
+// playerGamesKey is the sorted set of the games a player has played, scored by when they began.
+func playerGamesKey(player string) string {
+	return "Games:" + player
+}
+
+// Record pushes the event onto the game's history.
+func (h *redisHistory) Record(ctx context.Context, game string, e *Event) error {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	con := h.pool.Get()
+	defer con.Close()
+
+	key := historyKey(game)
+	con.Send("MULTI")
+	con.Send("RPUSH", key, data)
+	con.Send("EXPIRE", key, historyExpiry)
+
+	if e.Type == beginMessage {
+		for _, p := range e.Players {
+			key := playerGamesKey(p)
+			con.Send("ZADD", key, e.Time, game)
+			con.Send("ZREMRANGEBYRANK", key, 0, -(maxRecentGames + 1))
+			con.Send("EXPIRE", key, historyExpiry)
+		}
+	}
+
+	_, err = con.Do("EXEC")
+	return err
+}
+
+// Events reads back the game's history.
+func (h *redisHistory) Events(ctx context.Context, game string) ([]*Event, error) {
+	con := h.pool.Get()
+	defer con.Close()
+
+	data, err := redis.ByteSlices(con.Do("LRANGE", historyKey(game), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, ErrGameNotFound
+	}
+
+	events := make([]*Event, 0, len(data))
+	for _, d := range data {
+		e := new(Event)
+		if err := proto.Unmarshal(d, e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// Games reads the most recent of the player's games.
+func (h *redisHistory) Games(ctx context.Context, player string, limit int) ([]string, error) {
+	con := h.pool.Get()
+	defer con.Close()
+
+	return redis.Strings(con.Do("ZREVRANGE", playerGamesKey(player), 0, limit-1))
+}
+
+// recordingBroker is a Broker that records every event
+// published to a game's topic in the history.
+type recordingBroker struct {
+	Broker
+	history History
+}
+
+// Publish publishes the data to the topic, then records it. The game carries on
+// if it can't be recorded, so that is only logged.
+func (b *recordingBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	if err := b.Broker.Publish(ctx, topic, data); err != nil {
+		return err
+	}
+
+	e := new(Event)
+	if err := proto.Unmarshal(data, e); err != nil {
+		logger.Error(ctx, "RecordingBroker", "Could not decode event to record it. %v", err)
+		return nil
+	}
+
+	if err := b.history.Record(ctx, topic, e); err != nil {
+		logger.Error(ctx, "RecordingBroker", "Could not record event %v. %v", e, err)
+	}
+
+	return nil
+}
+
+// record records a message in the history of a game that isn't published,
+// like a SOLO game. Errors are logged, since the game carries on either way.
+func (s *SimonSays) record(ctx context.Context, game *Game, msg message) {
+	msg.Time = time.Now()
+	if err := s.history.Record(ctx, game.ID, msg.event()); err != nil {
+		logger.Error(ctx, "Record", "Could not record message %#v. %v", msg, err)
+	}
+}
+
+// buildRecord works out what happened in the game from its events.
+// Events are followed the way the players see them: the turn passes to the
+// next player on STOP_TURN, and on LOST if it was the losing player's turn.
+func buildRecord(id string, events []*Event) *GameRecord {
+	rec := &GameRecord{Id: id}
+	game := NewGame(id)
+	var turn *GameRecord_Turn
+
+	startTurn := func(player string, at int64) {
+		turn = &GameRecord_Turn{Round: int32(len(rec.Turns) + 1), Player: player, Start: at}
+		rec.Turns = append(rec.Turns, turn)
+	}
+
+	for _, e := range events {
+		switch e.Type {
+		case beginMessage:
+			rec.Players = e.Players
+			rec.Start = e.Time
+			game.SetPlayers(e.Players)
+
+		case lightUpMessage:
+			if turn != nil {
+				turn.Presses = append(turn.Presses, e.Colors...)
+			}
+
+		case stopTurnMessage:
+			if turn != nil {
+				turn.End = e.Time
+			}
+			if len(e.Colors) > 0 {
+				rec.Sequence = e.Colors
+			}
+			startTurn(game.NextPlayer(e.Player), e.Time)
+
+		case lostMessage:
+			elim := &Elimination{Player: e.Player, Reason: e.Reason, Time: e.Time}
+			current := turn != nil && turn.Player == e.Player
+			if turn != nil {
+				elim.Round = turn.Round
+			}
+			rec.Eliminations = append(rec.Eliminations, elim)
+
+			next := game.NextPlayer(e.Player)
+			game.RemovePlayer(e.Player)
+			if current {
+				turn.End = e.Time
+				turn = nil
+			}
+
+			if players := game.Players(); len(players) <= 1 {
+				rec.End = e.Time
+				if len(players) == 1 {
+					rec.Winner = players[0]
+				}
+				turn = nil
+				continue
+			}
+
+			if current {
+				startTurn(next, e.Time)
+			}
+		}
+	}
+
+	return rec
+}
+
+// ListGames function is an implementation of the gRPC ListGames Service.
+// Returns the player's most recent games, without their turns.
+func (s *SimonSays) ListGames(ctx context.Context, req *GamesRequest) (*GameList, error) {
+	lc := "ListGames"
+
+	if err := validatePlayerID(req.Player); err != nil {
+		return nil, statusError(err)
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultListedGames
+	}
+	if limit > maxRecentGames {
+		limit = maxRecentGames
+	}
+
+	ids, err := s.history.Games(ctx, req.Player, limit)
+	if err != nil {
+		logger.Error(ctx, lc, "Error listing the games of %v. %v", req.Player, err)
+		return nil, statusError(err)
+	}
+
+	list := &GameList{}
+	for _, id := range ids {
+		events, err := s.history.Events(ctx, id)
+		// the player's list can outlive the game's history by a little.
+		if err == ErrGameNotFound {
+			continue
+		}
+		if err != nil {
+			logger.Error(ctx, lc, "Error reading the history of %v. %v", id, err)
+			return nil, statusError(err)
+		}
+
+		rec := buildRecord(id, events)
+		rec.Turns = nil
+		list.Games = append(list.Games, rec)
+	}
+
+	return list, nil
+}
+
+// GetGame function is an implementation of the gRPC GetGame Service.
+// Returns the full record of the game.
+func (s *SimonSays) GetGame(ctx context.Context, req *GameRequest) (*GameRecord, error) {
+	if req.Game == "" {
+		return nil, statusError(invalidRequestError("The game to get is missing."))
+	}
+
+	events, err := s.history.Events(ctx, req.Game)
+	if err != nil {
+		logger.Error(ctx, "GetGame", "Error reading the history of %v. %v", req.Game, err)
+		return nil, statusError(err)
+	}
+
+	return buildRecord(req.Game, events), nil
+}
+
+// millis is the time in milliseconds since the Unix epoch.
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
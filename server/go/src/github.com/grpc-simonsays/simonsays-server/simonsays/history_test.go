@@ -0,0 +1,133 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestBuildRecord tests working out what happened in a game from its events.
+func TestBuildRecord(t *testing.T) {
+	Convey("Given the events of a three player game", t, func() {
+		players := []string{"Player One", "Player Two", "Player Three"}
+		events := []*Event{
+			{Type: Event_BEGIN, Player: "Player Three", Players: players, Time: 1000},
+			{Type: Event_STOP_TURN, Player: "Player Three", Time: 1001},
+			{Type: Event_LIGHTUP, Colors: []Color{Color_RED}, Time: 2000},
+			{Type: Event_STOP_TURN, Player: "Player One", Colors: []Color{Color_RED}, Time: 2001},
+			// player three goes out when it isn't their turn.
+			{Type: Event_LOST, Player: "Player Three", Reason: Elimination_FORFEIT, Time: 2500},
+			{Type: Event_LIGHTUP, Colors: []Color{Color_RED}, Time: 3000},
+			{Type: Event_LIGHTUP, Colors: []Color{Color_BLUE}, Time: 3500},
+			{Type: Event_STOP_TURN, Player: "Player Two", Colors: []Color{Color_RED, Color_BLUE}, Time: 3501},
+			{Type: Event_LIGHTUP, Colors: []Color{Color_GREEN}, Time: 4000},
+			{Type: Event_LOST, Player: "Player One", Colors: []Color{Color_RED, Color_BLUE}, Reason: Elimination_WRONG_COLOR, Time: 4001},
+		}
+
+		rec := buildRecord("Game", events)
+
+		Convey("The record has the players, times, winner, and longest sequence", func() {
+			So(rec.Id, ShouldEqual, "Game")
+			So(rec.Players, ShouldResemble, players)
+			So(rec.Start, ShouldEqual, 1000)
+			So(rec.End, ShouldEqual, 4001)
+			So(rec.Winner, ShouldEqual, "Player Two")
+			So(rec.Sequence, ShouldResemble, []Color{Color_RED, Color_BLUE})
+		})
+
+		Convey("The players that lost are in the order they went out, and why", func() {
+			So(rec.Eliminations, ShouldResemble, []*Elimination{
+				{Player: "Player Three", Reason: Elimination_FORFEIT, Round: 2, Time: 2500},
+				{Player: "Player One", Reason: Elimination_WRONG_COLOR, Round: 3, Time: 4001},
+			})
+		})
+
+		Convey("Each turn has who pressed what", func() {
+			So(rec.Turns, ShouldResemble, []*GameRecord_Turn{
+				{Round: 1, Player: "Player One", Presses: []Color{Color_RED}, Start: 1001, End: 2001},
+				{Round: 2, Player: "Player Two", Presses: []Color{Color_RED, Color_BLUE}, Start: 2001, End: 3501},
+				{Round: 3, Player: "Player One", Presses: []Color{Color_GREEN}, Start: 3501, End: 4001},
+			})
+		})
+	})
+}
+
+// TestGameHistory tests that games are recorded, and can be listed and fetched.
+func TestGameHistory(t *testing.T) {
+	Convey("Given a game between two players that has finished", t, func(c C) {
+		playerOne := newMockStream()
+		playerTwo := newMockStream()
+		game := mustSimonSays()
+		defer game.Close()
+		wg := sync.WaitGroup{}
+
+		startResumableGame(c, game, &wg, playerOne, playerTwo)
+
+		mustPress(playerOne, Color_GREEN)
+		So(playerOne, shouldLightup, Color_GREEN)
+		So(playerTwo, shouldLightup, Color_GREEN)
+		So(playerOne, shouldState, Response_STOP_TURN)
+		So(playerTwo, shouldState, Response_START_TURN)
+
+		mustPress(playerTwo, Color_RED)
+		So(playerOne, shouldLightup, Color_RED)
+		So(playerTwo, shouldLightup, Color_RED)
+		So(playerOne, shouldState, Response_WIN)
+		So(playerTwo, shouldState, Response_LOSE)
+		wg.Wait()
+
+		ctx := context.TODO()
+
+		Convey("It is in both players' lists of games, without its turns", func() {
+			for _, p := range []string{"Player One", "Player Two"} {
+				list, err := game.ListGames(ctx, &GamesRequest{Player: p})
+				So(err, ShouldBeNil)
+				So(list.Games, ShouldHaveLength, 1)
+				So(list.Games[0].Winner, ShouldEqual, "Player One")
+				So(list.Games[0].Turns, ShouldBeEmpty)
+			}
+
+			Convey("And its full record can be fetched", func() {
+				list, err := game.ListGames(ctx, &GamesRequest{Player: "Player One"})
+				So(err, ShouldBeNil)
+
+				rec, err := game.GetGame(ctx, &GameRequest{Game: list.Games[0].Id})
+				So(err, ShouldBeNil)
+				So(rec.Players, ShouldResemble, []string{"Player One", "Player Two"})
+				So(rec.Start, ShouldBeGreaterThan, 0)
+				So(rec.End, ShouldBeGreaterThanOrEqualTo, rec.Start)
+				So(rec.Sequence, ShouldResemble, []Color{Color_GREEN})
+				So(rec.Eliminations, ShouldHaveLength, 1)
+				So(rec.Eliminations[0].Player, ShouldEqual, "Player Two")
+				So(rec.Eliminations[0].Reason, ShouldEqual, Elimination_WRONG_COLOR)
+				So(rec.Turns, ShouldHaveLength, 2)
+				So(rec.Turns[0].Presses, ShouldResemble, []Color{Color_GREEN})
+				So(rec.Turns[1].Presses, ShouldResemble, []Color{Color_RED})
+			})
+		})
+
+		Convey("A game that wasn't recorded isn't found", func() {
+			_, err := game.GetGame(ctx, &GameRequest{Game: "not a game"})
+			So(grpc.Code(err), ShouldEqual, codes.NotFound)
+		})
+	})
+}
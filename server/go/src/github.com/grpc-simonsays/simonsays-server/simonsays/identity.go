@@ -0,0 +1,46 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/auth"
+	"golang.org/x/net/context"
+)
+
+// ErrWrongPlayer is returned when a player joins with a different id to the one their token was issued to.
+var ErrWrongPlayer = errors.New("Player id does not match the token")
+
+// identify checks the player is who their bearer token says they are. If they
+// left their id out, it is set to the one from the token. Players without a
+// token keep the id they sent, when the server doesn't need one.
+func identify(ctx context.Context, player *Request_Player) error {
+	subject, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	switch player.Id {
+	case "":
+		player.Id = subject
+	case subject:
+	default:
+		return ErrWrongPlayer
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/auth"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestIdentify tests checking players against their tokens.
+func TestIdentify(t *testing.T) {
+	Convey("Given a player", t, func() {
+		Convey("Without a token, they should keep the id they sent", func() {
+			player := &Request_Player{Id: "Player One"}
+			So(identify(context.TODO(), player), ShouldBeNil)
+			So(player.Id, ShouldEqual, "Player One")
+		})
+
+		Convey("With a token", func() {
+			ctx := auth.NewContext(context.TODO(), "Player One")
+
+			Convey("And the same id, they should be let through", func() {
+				player := &Request_Player{Id: "Player One"}
+				So(identify(ctx, player), ShouldBeNil)
+				So(player.Id, ShouldEqual, "Player One")
+			})
+
+			Convey("And no id, they should get the id from the token", func() {
+				player := &Request_Player{}
+				So(identify(ctx, player), ShouldBeNil)
+				So(player.Id, ShouldEqual, "Player One")
+			})
+
+			Convey("And someone else's id, they should be turned away", func() {
+				player := &Request_Player{Id: "Player Two"}
+				So(identify(ctx, player), ShouldEqual, ErrWrongPlayer)
+			})
+		})
+	})
+}
+
+// TestGameWithToken tests joining a game with a bearer token.
+func TestGameWithToken(t *testing.T) {
+	Convey("Given a player with a token", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+
+		game.pace = 10 * time.Millisecond
+
+		stream := newMockStream()
+		stream.ctx = auth.NewContext(stream.ctx, "Player One")
+
+		Convey("When they join as someone else, they should be told they can't", func() {
+			err := stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Two", Mode: Request_SOLO}}})
+			So(err, ShouldBeNil)
+
+			So(grpc.Code(game.Game(stream)), ShouldEqual, codes.PermissionDenied)
+			So(stream, shouldError, Error_PERMISSION_DENIED)
+		})
+
+		Convey("When they join without an id, they should play as themselves", func(c C) {
+			err := stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Mode: Request_SOLO}}})
+			So(err, ShouldBeNil)
+
+			done := make(chan bool)
+			go func() {
+				defer close(done)
+				c.So(game.Game(stream), ShouldBeNil)
+			}()
+
+			res, err := stream.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+			So(res.Current, ShouldEqual, "Player One")
+
+			// get the first colour wrong, to end the game.
+			res, err = stream.PullSend()
+			So(err, ShouldBeNil)
+			wrong := (res.GetLightup() + 1) % Color(len(Color_name))
+			So(stream, shouldState, Response_START_TURN)
+
+			mustPress(stream, wrong)
+			So(stream, shouldLightup, wrong)
+			So(stream, shouldState, Response_LOSE)
+			<-done
+		})
+	})
+}
@@ -16,54 +16,342 @@ limitations under the License.
 package simonsays
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
 	"github.com/garyburd/redigo/redis"
 	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
 	uuid "github.com/nu7hatch/gouuid"
 	"golang.org/x/net/context"
 )
 
-const openGames = "OpenGames"
+// ratedGames starts the keys of the sorted sets of open games. They are kept apart from
+// the OpenGames lists that came before them, so old and new servers can run side by side.
+const ratedGames = "RatedGames"
+
+// matchCandidates is how many open games either side of the player's rating are tried.
+const matchCandidates = 10
+
+// how long the list of players in a game is kept around for.
+const playersExpiry = 60 * 60
+
+const (
+	// playersSuffix is added to the game id for the list of players.
+	playersSuffix = ":Players"
+	// aliveInfix goes between the game id and the player id for
+	// the key that shows the player is still waiting on the game.
+	aliveInfix = ":Alive:"
+)
+
+const (
+	// livenessExpiry is how long a player is thought to still be waiting
+	// on a game, without them letting us know.
+	livenessExpiry = 3 * time.Second
+	// livenessInterval is how often waiting players let us know they are still there.
+	livenessInterval = time.Second
+)
+
+// errGameGone is returned when the players of a game have gone before it could begin.
+var errGameGone = errors.New("Game has gone")
+
+// claimScript takes the open game (ARGV[1]) off the open games (KEYS[1]), as long as one of its
+// players (ARGV[2] on) is still waiting on it, going by their alive keys (KEYS[3] on). Players
+// that are no longer waiting are removed from the game's players (KEYS[2]) as it goes, and the
+// game is closed if nobody is. Returns 1 if the game was taken, or 0 if it wasn't.
+var claimScript = redis.NewScript(-1, `
+local open, players, id = KEYS[1], KEYS[2], ARGV[1]
+if not redis.call("ZSCORE", open, id) then
+	return 0
+end
+
+local waiting = 0
+for i = 3, #KEYS do
+	if redis.call("EXISTS", KEYS[i]) == 1 then
+		waiting = waiting + 1
+	else
+		redis.call("LREM", players, 0, ARGV[i - 1])
+	end
+end
+
+redis.call("ZREM", open, id)
+if waiting == 0 then
+	return 0
+end
+return 1
+`)
 
-// findGame finds a game in the list of open games. If one doesn't exist, creates a new gameid
+// withdrawScript takes the open game (ARGV[1]) off the open games (KEYS[1]), as long as
+// nobody has joined its host in its players (KEYS[2]). Returns 1 if it was withdrawn.
+var withdrawScript = redis.NewScript(2, `
+if redis.call("LLEN", KEYS[2]) > 1 then
+	return 0
+end
+return redis.call("ZREM", KEYS[1], ARGV[1])
+`)
+
+// openGame is a game in the open games, and its rating.
+type openGame struct {
+	id     string
+	rating int
+}
+
+// openGamesKey is the sorted set of open games of a given size,
+// scored by the rating of the player that started them.
+// Games that can only be found on one server (the scope) are kept apart.
+func openGamesKey(scope string, size int) string {
+	if scope != "" {
+		return fmt.Sprintf("%v:%v:%v", ratedGames, scope, size)
+	}
+	return fmt.Sprintf("%v:%v", ratedGames, size)
+}
+
+// playersKey is the list of players that have joined a game, in turn order.
+func playersKey(g *Game) string {
+	return g.ID + playersSuffix
+}
+
+// aliveKey shows the player is still waiting on the game.
+func aliveKey(g *Game, id string) string {
+	return g.ID + aliveInfix + id
+}
+
+// findGame finds the game in the open games of the given scope and size, that still has players waiting
+// on it, and is closest to the given rating, as long as it is within the window.
+// If there isn't one, creates a new gameid, rated with the given rating.
 // returns a new Game and if it's a new game or not.
-func findGame(ctx context.Context, con redis.Conn) (*Game, bool, error) {
+func findGame(ctx context.Context, con redis.Conn, scope string, size, rating, window int) (*Game, bool, error) {
 	lc := "FindGame"
 
 	// do we have an open game?
-	gameID, err := redis.String(con.Do("RPOP", openGames))
+	g, err := matchGame(ctx, con, scope, size, rating, window, "")
+	if err != nil {
+		return nil, false, err
+	}
+	if g != nil {
+		return g, false, nil
+	}
+
+	logger.Info(ctx, lc, "Could not find open game within %v of %v, creating one... ", window, rating)
+	u, err := uuid.NewV4()
+	if err != nil {
+		return nil, false, err
+	}
+
+	g = NewGame(u.String())
+	g.Size = size
+	g.Rating = rating
+	g.Scope = scope
+
+	return g, true, nil
+}
 
-	// ignore nil errors, since that is expected
-	if err != nil && err != redis.ErrNil {
-		logger.Error(ctx, lc, "Error finding open game: %v", err)
-		return new(Game), false, err
+// matchGame takes the game in the open games of the given scope and size, that still has players waiting
+// on it, and is closest to the given rating, as long as it is within the window. The game with
+// the id to skip is left where it is. Returns nil if there is no such game.
+func matchGame(ctx context.Context, con redis.Conn, scope string, size, rating, window int, skip string) (*Game, error) {
+	games, err := nearGames(ctx, con, scope, size, rating, window, skip)
+	if err != nil {
+		return nil, err
 	}
 
-	// is this a brand new game?
-	isNew := (gameID == "")
+	for _, o := range games {
+		g := NewGame(o.id)
+		g.Size = size
+		g.Rating = o.rating
+		g.Scope = scope
 
-	if isNew {
-		logger.Info(ctx, lc, "Could not find open game, creating one... ")
-		u, err := uuid.NewV4()
+		ok, err := claimGame(ctx, con, g)
 		if err != nil {
-			return nil, false, err
+			return nil, err
+		}
+		if ok {
+			return g, nil
 		}
-		gameID = u.String()
 	}
 
-	return NewGame(gameID), isNew, nil
+	return nil, nil
 }
 
-// addOpenGame Adds an open game to the list.
+// nearGames lists the games in the open games of the given scope and size, that are within the
+// window of the given rating, closest first. The game with the id to skip is left out.
+func nearGames(ctx context.Context, con redis.Conn, scope string, size, rating, window int, skip string) ([]openGame, error) {
+	lc := "NearGames"
+	key := openGamesKey(scope, size)
+
+	// the closest games at or above the rating, then the closest below it.
+	above, err := redis.Values(con.Do("ZRANGEBYSCORE", key, rating, rating+window, "WITHSCORES", "LIMIT", 0, matchCandidates))
+	if err != nil {
+		logger.Error(ctx, lc, "Error finding open games: %v", err)
+		return nil, err
+	}
+	below, err := redis.Values(con.Do("ZREVRANGEBYSCORE", key, fmt.Sprintf("(%v", rating), rating-window, "WITHSCORES", "LIMIT", 0, matchCandidates))
+	if err != nil {
+		logger.Error(ctx, lc, "Error finding open games: %v", err)
+		return nil, err
+	}
+
+	var games []openGame
+	for v := append(above, below...); len(v) > 0; {
+		var o openGame
+		if v, err = redis.Scan(v, &o.id, &o.rating); err != nil {
+			logger.Error(ctx, lc, "Error reading open game: %v", err)
+			return nil, err
+		}
+		if o.id != skip {
+			games = append(games, o)
+		}
+	}
+
+	sort.SliceStable(games, func(i, j int) bool {
+		return abs(games[i].rating-rating) < abs(games[j].rating-rating)
+	})
+
+	return games, nil
+}
+
+// claimGame takes the game off the open games, if someone is still waiting on it.
+// Players that have stopped waiting are removed from it, and if nobody is left,
+// it is closed. Returns if the game was taken.
+func claimGame(ctx context.Context, con redis.Conn, g *Game) (bool, error) {
+	lc := "ClaimGame"
+
+	players, err := listPlayers(ctx, con, g)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []interface{}{openGamesKey(g.Scope, g.Size), playersKey(g)}
+	args := []interface{}{g.ID}
+	for _, p := range players {
+		keys = append(keys, aliveKey(g, p))
+		args = append(args, p)
+	}
+
+	ok, err := redis.Bool(claimScript.Do(con, append(append([]interface{}{len(keys)}, keys...), args...)...))
+	if err != nil {
+		logger.Error(ctx, lc, "Error taking open game %v: %v", g.ID, err)
+		return false, err
+	}
+	if !ok {
+		logger.Info(ctx, lc, "Open game %v has gone", g.ID)
+	}
+	return ok, nil
+}
+
+// withdrawGame takes the host's game off the open games, as long as nobody has taken
+// it, or joined it. Returns if it was withdrawn.
+func withdrawGame(ctx context.Context, con redis.Conn, g *Game) (bool, error) {
+	ok, err := redis.Bool(withdrawScript.Do(con, openGamesKey(g.Scope, g.Size), playersKey(g), g.ID))
+	if err != nil {
+		logger.Error(ctx, "WithdrawGame", "Error withdrawing open game %v: %v", g.ID, err)
+	}
+	return ok, err
+}
+
+// gameTaken returns if someone has taken the host's game off the open games, or joined it.
+func gameTaken(ctx context.Context, con redis.Conn, g *Game) (bool, error) {
+	lc := "GameTaken"
+
+	_, err := redis.Int(con.Do("ZSCORE", openGamesKey(g.Scope, g.Size), g.ID))
+	if err == redis.ErrNil {
+		return true, nil
+	}
+	if err != nil {
+		logger.Error(ctx, lc, "Error checking open game %v: %v", g.ID, err)
+		return false, err
+	}
+
+	n, err := redis.Int(con.Do("LLEN", playersKey(g)))
+	if err != nil {
+		logger.Error(ctx, lc, "Error counting players of game %v: %v", g.ID, err)
+		return false, err
+	}
+	return n > 1, nil
+}
+
+// abs returns how far n is from zero.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// addOpenGame Adds an open game to the open games.
 func addOpenGame(ctx context.Context, con redis.Conn, g *Game) error {
-	logger.Info(ctx, "AddOpenGame", "Adding open game %v", g.ID)
-	_, err := con.Do("LPUSH", openGames, g.ID)
+	logger.Info(ctx, "AddOpenGame", "Adding open game %v, rated %v", g.ID, g.Rating)
+	_, err := con.Do("ZADD", openGamesKey(g.Scope, g.Size), g.Rating, g.ID)
+	return err
+}
+
+// reopenGame puts a partially filled game back in the open games,
+// so the next player can find it.
+func reopenGame(ctx context.Context, con redis.Conn, g *Game) error {
+	logger.Info(ctx, "ReopenGame", "Reopening game %v", g.ID)
+	_, err := con.Do("ZADD", openGamesKey(g.Scope, g.Size), g.Rating, g.ID)
 	return err
 }
 
+// joinPlayers adds the player to the turn order of the game.
+// Returns how many players have joined so far.
+func joinPlayers(ctx context.Context, con redis.Conn, g *Game, player *Request_Player) (int, error) {
+	lc := "JoinPlayers"
+	key := playersKey(g)
+
+	n, err := redis.Int(con.Do("RPUSH", key, player.Id))
+	if err != nil {
+		logger.Error(ctx, lc, "Error joining players of game: %v", err)
+		return 0, err
+	}
+
+	if _, err := con.Do("EXPIRE", key, playersExpiry); err != nil {
+		logger.Error(ctx, lc, "Error setting expiry of players: %v", err)
+		return 0, err
+	}
+
+	if err := refreshAlive(ctx, con, g, player); err != nil {
+		return 0, err
+	}
+
+	logger.Info(ctx, lc, "%v of %v players have joined", n, g.Size)
+	return n, nil
+}
+
+// listPlayers returns all the players that have joined the game, in turn order.
+func listPlayers(ctx context.Context, con redis.Conn, g *Game) ([]string, error) {
+	players, err := redis.Strings(con.Do("LRANGE", playersKey(g), 0, -1))
+	if err != nil {
+		logger.Error(ctx, "ListPlayers", "Error listing players: %v", err)
+	}
+	return players, err
+}
+
 // closeOpenGame make sure the open game is removed
-// from the open game list.
+// from the open games.
 func closeOpenGame(ctx context.Context, con redis.Conn, g *Game) error {
 	logger.Info(ctx, "CloseOpenGame", "Removing open game %v", g.ID)
-	_, err := con.Do("LREM", openGames, 1, g.ID)
+	_, err := con.Do("ZREM", openGamesKey(g.Scope, g.Size), g.ID)
+	return err
+}
+
+// leavePlayers removes the player from the turn order of a game
+// that has yet to begin.
+func leavePlayers(ctx context.Context, con redis.Conn, g *Game, player *Request_Player) error {
+	logger.Info(ctx, "LeavePlayers", "Removing player %v from game %v", player.Id, g.ID)
+	if _, err := con.Do("LREM", playersKey(g), 1, player.Id); err != nil {
+		return err
+	}
+	_, err := con.Do("DEL", aliveKey(g, player.Id))
+	return err
+}
+
+// refreshAlive lets everyone know the player is still waiting on the game.
+func refreshAlive(ctx context.Context, con redis.Conn, g *Game, player *Request_Player) error {
+	_, err := con.Do("SET", aliveKey(g, player.Id), 1, "PX", int64(livenessExpiry/time.Millisecond))
+	if err != nil {
+		logger.Error(ctx, "RefreshAlive", "Error refreshing liveness: %v", err)
+	}
 	return err
 }
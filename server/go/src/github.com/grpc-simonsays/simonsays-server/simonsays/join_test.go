@@ -16,7 +16,9 @@ limitations under the License.
 package simonsays
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 	. "github.com/smartystreets/goconvey/convey"
@@ -34,7 +36,7 @@ func TestAddOpenGame(t *testing.T) {
 		_, err := con.Do("FLUSHDB")
 		So(err, ShouldBeNil)
 
-		result, err := redis.Values(con.Do("GET", openGames))
+		result, err := redis.Values(con.Do("GET", openGamesKey("", minPlayers)))
 
 		So(err, ShouldEqual, redis.ErrNil)
 		So(result, ShouldBeEmpty)
@@ -46,7 +48,7 @@ func TestAddOpenGame(t *testing.T) {
 			err := addOpenGame(ctx, con, game)
 			So(err, ShouldBeNil)
 
-			result, err := redis.Strings(con.Do("LRANGE", openGames, 0, -1))
+			result, err := redis.Strings(con.Do("ZRANGE", openGamesKey("", minPlayers), 0, -1))
 
 			So(err, ShouldBeNil)
 			So(result, ShouldNotBeEmpty)
@@ -56,7 +58,7 @@ func TestAddOpenGame(t *testing.T) {
 			Convey("We can remove an open game", func() {
 				err := closeOpenGame(ctx, con, game)
 				So(err, ShouldBeNil)
-				result, err := redis.Strings(con.Do("LRANGE", openGames, 0, -1))
+				result, err := redis.Strings(con.Do("ZRANGE", openGamesKey("", minPlayers), 0, -1))
 				So(err, ShouldBeNil)
 				So(result, ShouldBeEmpty)
 			})
@@ -78,7 +80,7 @@ func TestFindGame(t *testing.T) {
 		ctx := context.TODO()
 
 		Convey("And there is no game in the open games list, we should get a new game id", func() {
-			gameid, isNewGame, err := findGame(context.TODO(), con)
+			gameid, isNewGame, err := findGame(context.TODO(), con, "", minPlayers, initialRating, ratingWindow)
 
 			So(err, ShouldBeNil)
 			So(gameid, ShouldNotBeNil)
@@ -91,14 +93,245 @@ func TestFindGame(t *testing.T) {
 			defer con.Close()
 
 			game := NewGame("new game")
-			err := addOpenGame(ctx, con, game)
+			_, err := joinPlayers(ctx, con, game, &Request_Player{Id: "Host"})
+			So(err, ShouldBeNil)
+			err = addOpenGame(ctx, con, game)
 			So(err, ShouldBeNil)
 
-			foundGame, isNewGame, err := findGame(context.TODO(), con)
+			foundGame, isNewGame, err := findGame(context.TODO(), con, "", minPlayers, initialRating, ratingWindow)
 			So(err, ShouldBeNil)
 			So(isNewGame, ShouldBeFalse)
 			So(foundGame, ShouldResemble, game)
 		})
+
+		Convey("When the only open game is far from our rating", func() {
+			con := server.pool.Get()
+			defer con.Close()
+
+			game := NewGame("far game")
+			game.Rating = initialRating + 2*ratingWindow
+			_, err := joinPlayers(ctx, con, game, &Request_Player{Id: "Host"})
+			So(err, ShouldBeNil)
+			So(addOpenGame(ctx, con, game), ShouldBeNil)
+
+			Convey("We should host a new game, rated with our rating", func() {
+				foundGame, isNewGame, err := findGame(ctx, con, "", minPlayers, initialRating, ratingWindow)
+				So(err, ShouldBeNil)
+				So(isNewGame, ShouldBeTrue)
+				So(foundGame.ID, ShouldNotEqual, game.ID)
+				So(foundGame.Rating, ShouldEqual, initialRating)
+			})
+
+			Convey("We should find it once the window is wide enough", func() {
+				foundGame, isNewGame, err := findGame(ctx, con, "", minPlayers, initialRating, window(2*time.Second))
+				So(err, ShouldBeNil)
+				So(isNewGame, ShouldBeFalse)
+				So(foundGame, ShouldResemble, game)
+			})
+		})
+
+		Convey("When there are open games of different ratings", func() {
+			con := server.pool.Get()
+			defer con.Close()
+
+			for i, r := range []int{initialRating - 50, initialRating + 10, initialRating + 80} {
+				game := NewGame(fmt.Sprintf("game %v", i))
+				game.Rating = r
+				_, err := joinPlayers(ctx, con, game, &Request_Player{Id: "Host"})
+				So(err, ShouldBeNil)
+				So(addOpenGame(ctx, con, game), ShouldBeNil)
+			}
+
+			Convey("We should get the closest one", func() {
+				foundGame, _, err := findGame(ctx, con, "", minPlayers, initialRating, ratingWindow)
+				So(err, ShouldBeNil)
+				So(foundGame.ID, ShouldEqual, "game 1")
+				So(foundGame.Rating, ShouldEqual, initialRating+10)
+			})
+		})
+
+		Convey("When the host of an open game has gone, but another is waiting", func() {
+			con := server.pool.Get()
+			defer con.Close()
+
+			gone := NewGame("gone game")
+			_, err := joinPlayers(ctx, con, gone, &Request_Player{Id: "Gone Host"})
+			So(err, ShouldBeNil)
+			_, err = con.Do("DEL", aliveKey(gone, "Gone Host"))
+			So(err, ShouldBeNil)
+
+			game := NewGame("waiting game")
+			_, err = joinPlayers(ctx, con, game, &Request_Player{Id: "Host"})
+			So(err, ShouldBeNil)
+
+			// the gone game is first in line.
+			So(addOpenGame(ctx, con, gone), ShouldBeNil)
+			So(addOpenGame(ctx, con, game), ShouldBeNil)
+
+			foundGame, isNewGame, err := findGame(ctx, con, "", minPlayers, initialRating, ratingWindow)
+			So(err, ShouldBeNil)
+			So(isNewGame, ShouldBeFalse)
+			So(foundGame, ShouldResemble, game)
+
+			Convey("The gone host should be removed from their game", func() {
+				players, err := listPlayers(ctx, con, gone)
+				So(err, ShouldBeNil)
+				So(players, ShouldBeEmpty)
+			})
+
+			Convey("And after that, we should get a new game", func() {
+				foundGame, isNewGame, err := findGame(ctx, con, "", minPlayers, initialRating, ratingWindow)
+				So(err, ShouldBeNil)
+				So(isNewGame, ShouldBeTrue)
+				So(foundGame.ID, ShouldNotEqual, gone.ID)
+			})
+		})
+	})
+
+}
+
+// TestFindGameOfSize Testing out that games of different sizes are kept apart.
+func TestFindGameOfSize(t *testing.T) {
+
+	Convey("When we have a simon says", t, func() {
+		server := mustSimonSays()
+		defer server.Close()
+		con := server.pool.Get()
+		defer con.Close()
+
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+
+		Convey("And there is an open three player game", func() {
+			game := NewGame("three player game")
+			game.Size = 3
+			_, err := joinPlayers(ctx, con, game, &Request_Player{Id: "Host"})
+			So(err, ShouldBeNil)
+			err = addOpenGame(ctx, con, game)
+			So(err, ShouldBeNil)
+
+			Convey("We should not find it when looking for a two player game", func() {
+				foundGame, isNewGame, err := findGame(ctx, con, "", minPlayers, initialRating, ratingWindow)
+				So(err, ShouldBeNil)
+				So(isNewGame, ShouldBeTrue)
+				So(foundGame.ID, ShouldNotEqual, game.ID)
+			})
+
+			Convey("We should find it when looking for a three player game", func() {
+				foundGame, isNewGame, err := findGame(ctx, con, "", 3, initialRating, ratingWindow)
+				So(err, ShouldBeNil)
+				So(isNewGame, ShouldBeFalse)
+				So(foundGame, ShouldResemble, game)
+			})
+		})
 	})
+}
+
+// TestWithdrawGame tests that a host can only withdraw their open game while nobody has joined it.
+func TestWithdrawGame(t *testing.T) {
+
+	Convey("When we are hosting an open game", t, func() {
+		server := mustSimonSays()
+		defer server.Close()
+		con := server.pool.Get()
+		defer con.Close()
+
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+
+		game := NewGame("hosted game")
+		_, err = joinPlayers(ctx, con, game, &Request_Player{Id: "Host"})
+		So(err, ShouldBeNil)
+		So(addOpenGame(ctx, con, game), ShouldBeNil)
+
+		taken, err := gameTaken(ctx, con, game)
+		So(err, ShouldBeNil)
+		So(taken, ShouldBeFalse)
+
+		Convey("We can withdraw it", func() {
+			ok, err := withdrawGame(ctx, con, game)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			games, err := nearGames(ctx, con, "", minPlayers, initialRating, ratingWindow, "")
+			So(err, ShouldBeNil)
+			So(games, ShouldBeEmpty)
+		})
 
+		Convey("It isn't offered to us", func() {
+			games, err := nearGames(ctx, con, "", minPlayers, initialRating, ratingWindow, game.ID)
+			So(err, ShouldBeNil)
+			So(games, ShouldBeEmpty)
+		})
+
+		Convey("Once someone has joined it, we can't withdraw it", func() {
+			_, err := joinPlayers(ctx, con, game, &Request_Player{Id: "Joiner"})
+			So(err, ShouldBeNil)
+
+			taken, err := gameTaken(ctx, con, game)
+			So(err, ShouldBeNil)
+			So(taken, ShouldBeTrue)
+
+			ok, err := withdrawGame(ctx, con, game)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Once someone has taken it, it is taken", func() {
+			ok, err := claimGame(ctx, con, game)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			taken, err := gameTaken(ctx, con, game)
+			So(err, ShouldBeNil)
+			So(taken, ShouldBeTrue)
+		})
+	})
+}
+
+// TestJoinPlayers Testing out the turn order of players joining a game.
+func TestJoinPlayers(t *testing.T) {
+
+	Convey("When we have a game", t, func() {
+		server := mustSimonSays()
+		defer server.Close()
+		con := server.pool.Get()
+		defer con.Close()
+
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+		game := NewGame("new game")
+		game.Size = 3
+
+		Convey("Players are kept in the order they joined", func() {
+			for i, id := range []string{"Player One", "Player Two", "Player Three"} {
+				n, err := joinPlayers(ctx, con, game, &Request_Player{Id: id})
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, i+1)
+			}
+
+			players, err := listPlayers(ctx, con, game)
+			So(err, ShouldBeNil)
+			So(players, ShouldResemble, []string{"Player One", "Player Two", "Player Three"})
+
+			Convey("And a player can leave", func() {
+				err := leavePlayers(ctx, con, game, &Request_Player{Id: "Player Two"})
+				So(err, ShouldBeNil)
+
+				players, err := listPlayers(ctx, con, game)
+				So(err, ShouldBeNil)
+				So(players, ShouldResemble, []string{"Player One", "Player Three"})
+
+				alive, err := redis.Bool(con.Do("EXISTS", aliveKey(game, "Player Two")))
+				So(err, ShouldBeNil)
+				So(alive, ShouldBeFalse)
+			})
+		})
+	})
 }
@@ -0,0 +1,222 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// streaksKey is the hash of player ids to how many games in a row they have won.
+const streaksKey = "Streaks"
+
+const (
+	// defaultLeaders is how many of the top players are listed, if the request doesn't say.
+	defaultLeaders = 10
+	// maxLeaders is the most of the top players that can be listed.
+	maxLeaders = 100
+)
+
+// how long the daily and weekly leaderboards are kept for, after they start.
+const (
+	dailyExpiry  = 2 * 24 * 60 * 60
+	weeklyExpiry = 2 * 7 * 24 * 60 * 60
+)
+
+// windows are the leaderboard windows every game counts towards.
+var windows = []Leaderboard_Window{Leaderboard_ALL_TIME, Leaderboard_DAILY, Leaderboard_WEEKLY}
+
+// maxScript sets the player's (ARGV[1]) score on the leaderboard (KEYS[1]) to the score (ARGV[2]),
+// if it is higher than the one they have. Sets the leaderboard to expire after ARGV[3] seconds,
+// unless it is zero.
+var maxScript = redis.NewScript(1, `
+local key, player, score, expiry = KEYS[1], ARGV[1], tonumber(ARGV[2]), tonumber(ARGV[3])
+local current = redis.call("ZSCORE", key, player)
+
+if not current or tonumber(current) < score then
+	redis.call("ZADD", key, score, player)
+end
+
+if expiry > 0 then
+	redis.call("EXPIRE", key, expiry)
+end
+`)
+
+// leaderboardKey is the sorted set of players on the board, for the window the time falls in.
+func leaderboardKey(board Leaderboard_Board, window Leaderboard_Window, t time.Time) string {
+	t = t.UTC()
+
+	switch window {
+	case Leaderboard_DAILY:
+		return fmt.Sprintf("Leaderboard:%v:%v", board, t.Format("2006-01-02"))
+	case Leaderboard_WEEKLY:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("Leaderboard:%v:%v-W%02d", board, year, week)
+	}
+
+	return fmt.Sprintf("Leaderboard:%v", board)
+}
+
+// windowExpiry is how long a leaderboard for the window is kept for. Zero is forever.
+func windowExpiry(window Leaderboard_Window) int {
+	switch window {
+	case Leaderboard_DAILY:
+		return dailyExpiry
+	case Leaderboard_WEEKLY:
+		return weeklyExpiry
+	}
+	return 0
+}
+
+// recordWin counts the win on the WINS leaderboards, and the player's
+// winning streak on the STREAK leaderboards.
+func recordWin(ctx context.Context, con redis.Conn, player string, now time.Time) error {
+	lc := "RecordWin"
+
+	streak, err := redis.Int(con.Do("HINCRBY", streaksKey, player, 1))
+	if err != nil {
+		logger.Error(ctx, lc, "Error counting the winning streak of %v: %v", player, err)
+		return err
+	}
+
+	for _, w := range windows {
+		key := leaderboardKey(Leaderboard_WINS, w, now)
+		if _, err := con.Do("ZINCRBY", key, 1, player); err != nil {
+			logger.Error(ctx, lc, "Error counting the win: %v", err)
+			return err
+		}
+		if expiry := windowExpiry(w); expiry > 0 {
+			if _, err := con.Do("EXPIRE", key, expiry); err != nil {
+				return err
+			}
+		}
+
+		if _, err := maxScript.Do(con, leaderboardKey(Leaderboard_STREAK, w, now), player, streak, windowExpiry(w)); err != nil {
+			logger.Error(ctx, lc, "Error setting the winning streak: %v", err)
+			return err
+		}
+	}
+
+	logger.Info(ctx, lc, "%v has won %v in a row.", player, streak)
+	return nil
+}
+
+// recordLoss ends the player's winning streak, and puts the length of the
+// sequence they lost on on the SEQUENCE leaderboards.
+func recordLoss(ctx context.Context, con redis.Conn, player string, sequence int, now time.Time) error {
+	lc := "RecordLoss"
+
+	if _, err := con.Do("HDEL", streaksKey, player); err != nil {
+		logger.Error(ctx, lc, "Error ending the winning streak of %v: %v", player, err)
+		return err
+	}
+
+	if sequence == 0 {
+		return nil
+	}
+
+	for _, w := range windows {
+		if _, err := maxScript.Do(con, leaderboardKey(Leaderboard_SEQUENCE, w, now), player, sequence, windowExpiry(w)); err != nil {
+			logger.Error(ctx, lc, "Error setting the longest sequence: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rankGame updates the leaderboards at the end of the game, with the player's
+// win or loss. Errors are logged, since the game is over either way.
+func (s *SimonSays) rankGame(ctx context.Context, con redis.Conn, game *Game, player *Request_Player) {
+	now := time.Now()
+
+	if game.Lost() {
+		recordLoss(ctx, con, player.Id, len(game.ValidPresses()), now)
+		return
+	}
+
+	recordWin(ctx, con, player.Id, now)
+}
+
+// GetLeaderboard function is an implementation of the gRPC GetLeaderboard Service.
+// Returns the top players on the leaderboard, and the requested player's place on it.
+func (s *SimonSays) GetLeaderboard(ctx context.Context, req *LeaderboardRequest) (*Leaderboard, error) {
+	lc := "GetLeaderboard"
+
+	if _, ok := Leaderboard_Board_name[int32(req.Board)]; !ok {
+		return nil, statusError(invalidRequestError(fmt.Sprintf("Unknown leaderboard %v", req.Board)))
+	}
+	if _, ok := Leaderboard_Window_name[int32(req.Window)]; !ok {
+		return nil, statusError(invalidRequestError(fmt.Sprintf("Unknown leaderboard window %v", req.Window)))
+	}
+	if req.Player != "" {
+		if err := validatePlayerID(req.Player); err != nil {
+			return nil, statusError(err)
+		}
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultLeaders
+	}
+	if limit > maxLeaders {
+		limit = maxLeaders
+	}
+
+	con := s.pool.Get()
+	defer con.Close()
+
+	key := leaderboardKey(req.Board, req.Window, time.Now())
+	v, err := redis.Values(con.Do("ZREVRANGE", key, 0, limit-1, "WITHSCORES"))
+	if err != nil {
+		logger.Error(ctx, lc, "Error getting the top players: %v", err)
+		return nil, statusError(err)
+	}
+
+	board := &Leaderboard{Board: req.Board, Window: req.Window}
+	for rank := 1; len(v) > 0; rank++ {
+		e := &Leaderboard_Entry{Rank: int32(rank)}
+		if v, err = redis.Scan(v, &e.Player, &e.Score); err != nil {
+			return nil, statusError(err)
+		}
+		board.Entries = append(board.Entries, e)
+	}
+
+	if req.Player == "" {
+		return board, nil
+	}
+
+	rank, err := redis.Int(con.Do("ZREVRANK", key, req.Player))
+	if err == redis.ErrNil {
+		return board, nil
+	}
+	if err != nil {
+		logger.Error(ctx, lc, "Error getting the rank of %v: %v", req.Player, err)
+		return nil, statusError(err)
+	}
+
+	score, err := redis.Int64(con.Do("ZSCORE", key, req.Player))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	board.Player = &Leaderboard_Entry{Rank: int32(rank + 1), Player: req.Player, Score: score}
+	return board, nil
+}
@@ -0,0 +1,98 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestLeaderboardKey tests the leaderboards for each window.
+func TestLeaderboardKey(t *testing.T) {
+	Convey("Each window has its own leaderboard", t, func() {
+		now := time.Date(2016, time.January, 5, 23, 0, 0, 0, time.UTC)
+		So(leaderboardKey(Leaderboard_WINS, Leaderboard_ALL_TIME, now), ShouldEqual, "Leaderboard:WINS")
+		So(leaderboardKey(Leaderboard_WINS, Leaderboard_DAILY, now), ShouldEqual, "Leaderboard:WINS:2016-01-05")
+		So(leaderboardKey(Leaderboard_STREAK, Leaderboard_WEEKLY, now), ShouldEqual, "Leaderboard:STREAK:2016-W01")
+	})
+}
+
+// TestLeaderboard tests updating and reading the leaderboards.
+func TestLeaderboard(t *testing.T) {
+	Convey("Given some finished games", t, func() {
+		server := mustSimonSays()
+		defer server.Close()
+		con := server.pool.Get()
+		defer con.Close()
+
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+		now := time.Now()
+
+		// player one wins twice, loses on a sequence of 5, then wins again.
+		So(recordWin(ctx, con, "Player One", now), ShouldBeNil)
+		So(recordLoss(ctx, con, "Player Two", 3, now), ShouldBeNil)
+		So(recordWin(ctx, con, "Player One", now), ShouldBeNil)
+		So(recordLoss(ctx, con, "Player Two", 2, now), ShouldBeNil)
+		So(recordLoss(ctx, con, "Player One", 5, now), ShouldBeNil)
+		So(recordWin(ctx, con, "Player Two", now), ShouldBeNil)
+		So(recordWin(ctx, con, "Player One", now), ShouldBeNil)
+
+		Convey("Wins are counted", func() {
+			board, err := server.GetLeaderboard(ctx, &LeaderboardRequest{Board: Leaderboard_WINS, Window: Leaderboard_DAILY, Player: "Player Two"})
+			So(err, ShouldBeNil)
+			So(board.Entries, ShouldResemble, []*Leaderboard_Entry{
+				{Rank: 1, Player: "Player One", Score: 3},
+				{Rank: 2, Player: "Player Two", Score: 1},
+			})
+			So(board.Player, ShouldResemble, &Leaderboard_Entry{Rank: 2, Player: "Player Two", Score: 1})
+		})
+
+		Convey("The longest winning streak is kept, even after a loss", func() {
+			board, err := server.GetLeaderboard(ctx, &LeaderboardRequest{Board: Leaderboard_STREAK, Window: Leaderboard_WEEKLY, Limit: 1})
+			So(err, ShouldBeNil)
+			So(board.Entries, ShouldResemble, []*Leaderboard_Entry{{Rank: 1, Player: "Player One", Score: 2}})
+			So(board.Player, ShouldBeNil)
+		})
+
+		Convey("The longest sequence lost on is kept", func() {
+			board, err := server.GetLeaderboard(ctx, &LeaderboardRequest{Board: Leaderboard_SEQUENCE, Player: "Player Two"})
+			So(err, ShouldBeNil)
+			So(board.Entries, ShouldResemble, []*Leaderboard_Entry{
+				{Rank: 1, Player: "Player One", Score: 5},
+				{Rank: 2, Player: "Player Two", Score: 3},
+			})
+		})
+
+		Convey("A player that isn't on the leaderboard has no place", func() {
+			board, err := server.GetLeaderboard(ctx, &LeaderboardRequest{Board: Leaderboard_WINS, Player: "Player Three"})
+			So(err, ShouldBeNil)
+			So(board.Player, ShouldBeNil)
+		})
+
+		Convey("An unknown leaderboard is rejected", func() {
+			_, err := server.GetLeaderboard(ctx, &LeaderboardRequest{Board: Leaderboard_Board(42)})
+			So(grpc.Code(err), ShouldEqual, codes.InvalidArgument)
+		})
+	})
+}
@@ -0,0 +1,273 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	uuid "github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+)
+
+// DuplicatePolicy is what happens when a player joins a game while they
+// already have one going, on this server or any other.
+type DuplicatePolicy int
+
+const (
+	// RejectDuplicates turns away the new join, and the older session carries on.
+	RejectDuplicates DuplicatePolicy = iota
+	// ReplaceDuplicates ends the older session, and the new join carries on.
+	ReplaceDuplicates
+)
+
+// ParseDuplicatePolicy parses "reject" or "replace".
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch s {
+	case "reject":
+		return RejectDuplicates, nil
+	case "replace":
+		return ReplaceDuplicates, nil
+	}
+	return 0, fmt.Errorf("Unknown duplicate session policy %q", s)
+}
+
+const (
+	// leaseExpiry is how long a player's lease on their session lasts, if it isn't renewed.
+	leaseExpiry = 15 * time.Second
+	// leaseInterval is how often the lease is renewed.
+	leaseInterval = 5 * time.Second
+)
+
+var (
+	// ErrDuplicateSession is returned when a player joins while they already have a game going.
+	ErrDuplicateSession = errors.New("Player already has a game going. Resume it, or wait for it to end")
+	// ErrSessionReplaced is returned when a player's session has been ended by them joining again.
+	ErrSessionReplaced = errors.New("Player has joined again from somewhere else")
+)
+
+// renewScript renews the lease (KEYS[1]) for ARGV[2] milliseconds, if it still
+// belongs to the session ARGV[1]. Returns 1 if it was renewed, 0 otherwise.
+var renewScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lease (KEYS[1]), if it still belongs to the session ARGV[1].
+var releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// replaceScript gives the lease (KEYS[1]) to the session ARGV[1] for ARGV[2] milliseconds,
+// and returns the session that had it, if any.
+var replaceScript = redis.NewScript(1, `
+local old = redis.call("GET", KEYS[1])
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return old
+`)
+
+// activeKey is the lease on the player's active session, holding the session's id.
+func activeKey(player string) string {
+	return "Active:" + player
+}
+
+// kickTopic is the topic the session with the id is told it has been replaced on.
+func kickTopic(id string) string {
+	return "Kick:" + id
+}
+
+// lease is a player's claim on having the one active session, kept in Redis
+// so it holds across servers. It is renewed until it is released, and
+// lost if another session takes it over.
+type lease struct {
+	id     string
+	player string
+	pool   *redis.Pool
+	sub    Subscription
+
+	// closed when the lease has been lost.
+	lost     chan struct{}
+	lostOnce sync.Once
+	// closed when the lease is released.
+	done chan struct{}
+}
+
+// acquireLease claims the player's active session. If they already have one, it is turned
+// away with ErrDuplicateSession, or the other session is told to end, depending on the policy.
+func (s *SimonSays) acquireLease(ctx context.Context, player string) (*lease, error) {
+	lc := "AcquireLease"
+
+	u, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	// the lease outlives the stream, while the player can resume the game.
+	ctx = streamValues{ctx}
+
+	// listen before taking the lease, so a kick can't be missed.
+	sub, err := s.direct.Watch(ctx, kickTopic(u.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &lease{
+		id:     u.String(),
+		player: player,
+		pool:   s.pool,
+		sub:    sub,
+		lost:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := s.claim(ctx, l); err != nil {
+		if err := sub.Unsubscribe(); err != nil {
+			logger.Error(ctx, lc, "Error unsubscribing from kicks. %v", err)
+		}
+		return nil, err
+	}
+
+	go l.keep(ctx)
+	return l, nil
+}
+
+// claim takes the lease for the player, if nobody has it, or according to the policy if they do.
+func (s *SimonSays) claim(ctx context.Context, l *lease) error {
+	lc := "ClaimLease"
+	con := s.pool.Get()
+	defer con.Close()
+
+	key := activeKey(l.player)
+	expiry := int64(leaseExpiry / time.Millisecond)
+
+	_, err := redis.String(con.Do("SET", key, l.id, "NX", "PX", expiry))
+	if err == nil {
+		return nil
+	}
+	if err != redis.ErrNil {
+		logger.Error(ctx, lc, "Error taking the lease. %v", err)
+		return err
+	}
+
+	if s.DuplicateSessions == RejectDuplicates {
+		logger.Info(ctx, lc, "Player already has a session. Turning this one away.")
+		return ErrDuplicateSession
+	}
+
+	old, err := redis.String(replaceScript.Do(con, key, l.id, expiry))
+	if err == redis.ErrNil {
+		// the old lease expired in the meantime.
+		return nil
+	}
+	if err != nil {
+		logger.Error(ctx, lc, "Error taking over the lease. %v", err)
+		return err
+	}
+
+	logger.Info(ctx, lc, "Player already has a session. Ending it.")
+	if err := s.direct.Publish(ctx, kickTopic(old), []byte(l.id)); err != nil {
+		// the old session finds out when it next renews its lease.
+		logger.Warn(ctx, lc, "Could not tell the old session to end. %v", err)
+	}
+
+	return nil
+}
+
+// keep renews the lease every leaseInterval, until it is released or lost.
+func (l *lease) keep(ctx context.Context) {
+	lc := "KeepLease"
+	t := time.NewTicker(leaseInterval)
+	defer t.Stop()
+
+	kicks := l.sub.Messages()
+
+	for {
+		select {
+		case <-t.C:
+			ok, err := l.renew()
+			if err != nil {
+				// try again next time, before the lease runs out.
+				logger.Warn(ctx, lc, "Could not renew the lease. %v", err)
+				continue
+			}
+			if !ok {
+				logger.Info(ctx, lc, "Lease has been taken over.")
+				l.lose()
+				return
+			}
+		case _, ok := <-kicks:
+			if !ok {
+				kicks = nil
+				continue
+			}
+			logger.Info(ctx, lc, "Told to end, as the player has joined again.")
+			l.lose()
+			return
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// renew extends the lease, returning false if it belongs to another session now.
+func (l *lease) renew() (bool, error) {
+	con := l.pool.Get()
+	defer con.Close()
+
+	n, err := redis.Int(renewScript.Do(con, activeKey(l.player), l.id, int64(leaseExpiry/time.Millisecond)))
+	return n == 1, err
+}
+
+// lose marks the lease as lost.
+func (l *lease) lose() {
+	l.lostOnce.Do(func() { close(l.lost) })
+}
+
+// release gives up the lease, if it hasn't been taken over, and stops renewing it.
+func (l *lease) release(ctx context.Context) {
+	lc := "ReleaseLease"
+	close(l.done)
+
+	if err := l.sub.Unsubscribe(); err != nil {
+		logger.Error(ctx, lc, "Error unsubscribing from kicks. %v", err)
+	}
+
+	con := l.pool.Get()
+	defer con.Close()
+	if _, err := releaseScript.Do(con, activeKey(l.player), l.id); err != nil {
+		logger.Error(ctx, lc, "Error releasing the lease. %v", err)
+	}
+}
+
+// leaveReplaced lets the other players know the player has left the game,
+// as they have joined again from somewhere else.
+func leaveReplaced(stream SimonSays_GameServer, broker Broker, game *Game, player *Request_Player) error {
+	lc := "LeaveReplaced"
+	ctx := stream.Context()
+
+	logger.Info(ctx, lc, "Player has joined again from somewhere else. Leaving the game.")
+
+	return publishLost(ctx, broker, game, player, Elimination_FORFEIT)
+}
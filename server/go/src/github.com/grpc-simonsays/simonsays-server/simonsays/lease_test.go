@@ -0,0 +1,159 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestLease tests a player only having the one active session.
+func TestLease(t *testing.T) {
+	Convey("Given a SimonSays", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+		ctx := context.TODO()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		first, err := game.acquireLease(ctx, "Player One")
+		So(err, ShouldBeNil)
+
+		Convey("Another player should be able to have a session", func() {
+			other, err := game.acquireLease(ctx, "Player Two")
+			So(err, ShouldBeNil)
+			other.release(ctx)
+			first.release(ctx)
+		})
+
+		Convey("When duplicates are rejected", func() {
+			Convey("A second session should be turned away", func() {
+				_, err := game.acquireLease(ctx, "Player One")
+				So(err, ShouldEqual, ErrDuplicateSession)
+				first.release(ctx)
+			})
+
+			Convey("A second session should be let in, once the first is released", func() {
+				first.release(ctx)
+				second, err := game.acquireLease(ctx, "Player One")
+				So(err, ShouldBeNil)
+				second.release(ctx)
+			})
+		})
+
+		Convey("When duplicates are replaced", func() {
+			game.DuplicateSessions = ReplaceDuplicates
+			second, err := game.acquireLease(ctx, "Player One")
+			So(err, ShouldBeNil)
+
+			Convey("The first session should lose its lease", func() {
+				select {
+				case <-first.lost:
+				case <-time.After(time.Second):
+					So("The first lease was not lost", ShouldBeEmpty)
+				}
+
+				ok, err := first.renew()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("The first session should not release the second's lease", func() {
+				first.release(ctx)
+				id, err := redis.String(con.Do("GET", activeKey("Player One")))
+				So(err, ShouldBeNil)
+				So(id, ShouldEqual, second.id)
+
+				ok, err := second.renew()
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+			})
+
+			second.release(ctx)
+		})
+	})
+}
+
+// TestDuplicateGame tests a player joining a game while they have one going.
+func TestDuplicateGame(t *testing.T) {
+	Convey("Given a player with a solo game going", t, func(c C) {
+		game := mustSimonSays()
+		defer game.Close()
+		game.pace = 10 * time.Millisecond
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		first := newMockStream()
+		second := newMockStream()
+		join := &Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Mode: Request_SOLO}}}
+
+		done := make(chan error, 1)
+		So(first.PushRecv(join), ShouldBeNil)
+		go func() {
+			done <- game.Game(first)
+		}()
+		So(first, shouldState, Response_BEGIN)
+
+		Convey("When they join again, they should be told they already have a game going", func() {
+			So(second.PushRecv(join), ShouldBeNil)
+			So(grpc.Code(game.Game(second)), ShouldEqual, codes.AlreadyExists)
+			So(second, shouldError, Error_DUPLICATE_SESSION)
+
+			first.cancel()
+			<-done
+		})
+
+		Convey("When duplicates are replaced, and they join again", func() {
+			game.DuplicateSessions = ReplaceDuplicates
+			So(second.PushRecv(join), ShouldBeNil)
+
+			secondDone := make(chan error, 1)
+			go func() {
+				secondDone <- game.Game(second)
+			}()
+
+			Convey("The first game should end, and the second begin", func() {
+				So(second, shouldState, Response_BEGIN)
+				So(grpc.Code(<-done), ShouldEqual, codes.Aborted)
+
+				// skip the rest of the first game, to get to the error.
+				for {
+					res, err := first.PullSend()
+					So(err, ShouldBeNil)
+					if res.GetError() != nil {
+						So(res.GetError().Reason, ShouldEqual, Error_REPLACED)
+						break
+					}
+				}
+
+				second.cancel()
+				<-secondDone
+			})
+		})
+	})
+}
@@ -15,82 +15,231 @@ limitations under the License.
 
 // Package logger is logging that is specific to this application
 // Need to track the game, and the player, so we can see
-// everything that is going on.
+// everything that is going on. The fields that are tracked live in
+// the context, so they go away with it.
 package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/context"
 )
 
+// Level is how important a log line is.
+type Level int32
+
+const (
+	// DebugLevel is for the details of every message going back and forth.
+	DebugLevel Level = iota
+	// InfoLevel is for the things that happen over the course of a game.
+	InfoLevel
+	// WarnLevel is for things that went wrong, but the game can carry on.
+	WarnLevel
+	// ErrorLevel is for things that went wrong.
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "Debug",
+	InfoLevel:  "Info",
+	WarnLevel:  "Warn",
+	ErrorLevel: "Error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", l)
+}
+
+// ParseLevel parses the name of a level, ignoring case.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown log level %q", s)
+}
+
 var (
-	data = map[context.Context]map[string]string{}
-	// this is to track keys in the order they are set
-	keys = map[context.Context][]string{}
-	lock sync.RWMutex
+	// the lowest level that gets logged. Accessed atomically.
+	threshold = int32(InfoLevel)
+	// if lines are logged as JSON. Accessed atomically.
+	jsonLines int32
+
+	// where lines are logged to.
+	out   io.Writer = os.Stderr
+	outMu sync.Mutex
 )
 
-// Set set a value for this context
-func Set(ctx context.Context, key, value string) {
-	lock.Lock()
-	defer lock.Unlock()
+// SetLevel sets the lowest level that gets logged. Defaults to InfoLevel.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&threshold, int32(l))
+}
+
+// SetJSON sets whether each line is logged as a JSON object, rather than text.
+func SetJSON(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&jsonLines, v)
+}
+
+// SetOutput sets where lines are logged to. Defaults to standard error.
+func SetOutput(w io.Writer) {
+	outMu.Lock()
+	defer outMu.Unlock()
+	out = w
+}
+
+// fieldsKey is the context key for the fields.
+type fieldsKey struct{}
+
+// fields are the keys and values logged with every line for a context,
+// in the order they were first set. Fields from the parent context
+// come first.
+type fields struct {
+	parent *fields
+	mu     sync.RWMutex
+	keys   []string
+	values map[string]string
+}
 
-	if _, ok := data[ctx]; !ok {
-		data[ctx] = map[string]string{}
+// NewContext returns a context that fields can be set on. It starts with
+// the fields of the parent, but fields set on it aren't seen by the parent.
+func NewContext(ctx context.Context) context.Context {
+	f := &fields{values: map[string]string{}}
+	f.parent, _ = ctx.Value(fieldsKey{}).(*fields)
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// Set set a value for this context. The context must have come from
+// NewContext, otherwise the value is dropped.
+func Set(ctx context.Context, key, value string) {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return
 	}
 
-	data[ctx][key] = value
-	keys[ctx] = append(keys[ctx], key)
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
+	if _, ok := f.values[key]; !ok {
+		f.keys = append(f.keys, key)
+	}
+	f.values[key] = value
 }
 
-// Clear clears the data for this context. Very important
-// to do at the end of a request, to ensure no memory leaks
-func Clear(ctx context.Context) {
-	lock.Lock()
-	defer lock.Unlock()
+// each calls fn with each key and value, parents first. Keys set again
+// further down take the place of the parent's.
+func (f *fields) each(fn func(key, value string)) {
+	var chain []*fields
+	for ; f != nil; f = f.parent {
+		chain = append([]*fields{f}, chain...)
+	}
+
+	var keys []string
+	values := map[string]string{}
+	for _, f := range chain {
+		f.mu.RLock()
+		for _, k := range f.keys {
+			if _, ok := values[k]; !ok {
+				keys = append(keys, k)
+			}
+			values[k] = f.values[k]
+		}
+		f.mu.RUnlock()
+	}
+
+	for _, k := range keys {
+		fn(k, values[k])
+	}
+}
 
-	delete(data, ctx)
-	delete(keys, ctx)
+// Debug debug level logging.
+func Debug(ctx context.Context, category, msg string, args ...interface{}) {
+	printf(ctx, DebugLevel, category, msg, args...)
 }
 
 // Info informational level logging.
 func Info(ctx context.Context, category, msg string, args ...interface{}) {
-	printf(ctx, "Info", category, msg, args...)
+	printf(ctx, InfoLevel, category, msg, args...)
+}
+
+// Warn warning level logging.
+func Warn(ctx context.Context, category, msg string, args ...interface{}) {
+	printf(ctx, WarnLevel, category, msg, args...)
 }
 
 // Error error level logging.
 func Error(ctx context.Context, category, msg string, args ...interface{}) {
-	printf(ctx, "Error", category, msg, args...)
+	printf(ctx, ErrorLevel, category, msg, args...)
 }
 
-func printf(ctx context.Context, level, category, msg string, args ...interface{}) {
-	lock.RLock()
-	defer lock.RUnlock()
+func printf(ctx context.Context, level Level, category, msg string, args ...interface{}) {
+	if int32(level) < atomic.LoadInt32(&threshold) {
+		return
+	}
 
-	buf := new(bytes.Buffer)
-	_, err := buf.WriteString(fmt.Sprintf("[%v][%v]", level, category))
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	now := time.Now()
+	msg = fmt.Sprintf(msg, args...)
 
-	if err != nil {
-		log.Println("[Error][Logging] Could not write level and category to buffer.")
+	buf := new(bytes.Buffer)
+	if atomic.LoadInt32(&jsonLines) == 1 {
+		writeJSON(buf, now, f, level, category, msg)
+	} else {
+		writeText(buf, now, f, level, category, msg)
 	}
 
-	for _, k := range keys[ctx] {
-		_, err = buf.WriteString(fmt.Sprintf("[%v: %v]", k, data[ctx][k]))
-		if err != nil {
-			log.Println("[Error][Logging] Could not write key, value to the buffer")
-		}
-	}
+	outMu.Lock()
+	defer outMu.Unlock()
+	// nowhere else to log it to, if it doesn't work.
+	_, _ = buf.WriteTo(out)
+}
 
-	_, err = buf.WriteString(fmt.Sprintf(" "+msg, args...))
+// writeText writes the line the way the standard logger does, with the
+// level, category, and each field in square brackets before the message.
+func writeText(buf *bytes.Buffer, now time.Time, f *fields, level Level, category, msg string) {
+	fmt.Fprintf(buf, "%v [%v][%v]", now.Format("2006/01/02 15:04:05"), level, category)
+	f.each(func(k, v string) {
+		fmt.Fprintf(buf, "[%v: %v]", k, v)
+	})
+	fmt.Fprintf(buf, " %v\n", msg)
+}
 
-	if err != nil {
-		log.Println("[Error][Logging] Could not write message to buffer.")
+// writeJSON writes the line as a JSON object, with each field as a property.
+func writeJSON(buf *bytes.Buffer, now time.Time, f *fields, level Level, category, msg string) {
+	pair := func(k, v string) {
+		kb, _ := json.Marshal(k)
+		vb, _ := json.Marshal(v)
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
 	}
 
-	log.Println(buf.String())
+	buf.WriteByte('{')
+	pair("time", now.UTC().Format(time.RFC3339Nano))
+	buf.WriteByte(',')
+	pair("level", strings.ToLower(level.String()))
+	buf.WriteByte(',')
+	pair("category", category)
+	f.each(func(k, v string) {
+		buf.WriteByte(',')
+		pair(k, v)
+	})
+	buf.WriteByte(',')
+	pair("msg", msg)
+	buf.WriteString("}\n")
 }
@@ -0,0 +1,115 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+// TestLogger tests fields, levels, and the formats lines are logged in.
+func TestLogger(t *testing.T) {
+	Convey("Given a context with the player and game set", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		defer SetOutput(&bytes.Buffer{})
+		defer SetLevel(InfoLevel)
+		defer SetJSON(false)
+
+		ctx := NewContext(context.Background())
+		Set(ctx, "Player", "Player One")
+		Set(ctx, "Game", "Game One")
+
+		Convey("Lines are tagged with the fields, in the order they were set", func() {
+			Info(ctx, "Test", "Hello %v", 1)
+			So(buf.String(), ShouldEndWith, "[Info][Test][Player: Player One][Game: Game One] Hello 1\n")
+		})
+
+		Convey("Setting a field again replaces it", func() {
+			Set(ctx, "Player", "Player Two")
+			Error(ctx, "Test", "Hello")
+			So(buf.String(), ShouldEndWith, "[Error][Test][Player: Player Two][Game: Game One] Hello\n")
+		})
+
+		Convey("A child context has its own fields, as well as its parent's", func() {
+			child := NewContext(ctx)
+			Set(child, "Game", "Game Two")
+			Set(child, "Room", "ABCD")
+
+			Warn(child, "Test", "Child")
+			So(buf.String(), ShouldEndWith, "[Warn][Test][Player: Player One][Game: Game Two][Room: ABCD] Child\n")
+
+			buf.Reset()
+			Info(ctx, "Test", "Parent")
+			So(buf.String(), ShouldEndWith, "[Info][Test][Player: Player One][Game: Game One] Parent\n")
+		})
+
+		Convey("Fields set on a context without any are dropped", func() {
+			ctx := context.Background()
+			Set(ctx, "Player", "Player One")
+			Info(ctx, "Test", "Hello")
+			So(buf.String(), ShouldEndWith, "[Info][Test] Hello\n")
+		})
+
+		Convey("Lines below the threshold aren't logged", func() {
+			Debug(ctx, "Test", "Hidden")
+			So(buf.Len(), ShouldEqual, 0)
+
+			SetLevel(DebugLevel)
+			Debug(ctx, "Test", "Shown")
+			So(buf.String(), ShouldContainSubstring, "[Debug][Test]")
+
+			buf.Reset()
+			SetLevel(ErrorLevel)
+			Warn(ctx, "Test", "Hidden")
+			So(buf.Len(), ShouldEqual, 0)
+		})
+
+		Convey("Lines can be logged as JSON", func() {
+			SetJSON(true)
+			Info(ctx, "Test", "Hello %q", "there")
+
+			line := map[string]string{}
+			So(json.Unmarshal(buf.Bytes(), &line), ShouldBeNil)
+			So(line["level"], ShouldEqual, "info")
+			So(line["category"], ShouldEqual, "Test")
+			So(line["Player"], ShouldEqual, "Player One")
+			So(line["Game"], ShouldEqual, "Game One")
+			So(line["msg"], ShouldEqual, `Hello "there"`)
+			So(line["time"], ShouldNotBeEmpty)
+		})
+	})
+}
+
+// TestParseLevel tests parsing the names of levels.
+func TestParseLevel(t *testing.T) {
+	Convey("Levels are parsed by name, ignoring case", t, func() {
+		l, err := ParseLevel("warn")
+		So(err, ShouldBeNil)
+		So(l, ShouldEqual, WarnLevel)
+
+		l, err = ParseLevel("DEBUG")
+		So(err, ShouldBeNil)
+		So(l, ShouldEqual, DebugLevel)
+
+		_, err = ParseLevel("loud")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,191 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// memoryBroker is a Broker that passes messages around in memory,
+// for when all the players are connected to the one server.
+type memoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]map[*memorySubscription]bool
+}
+
+// memorySubscription is a subscription to a memoryBroker topic.
+// Messages are queued, so publishing never blocks on a slow subscriber.
+type memorySubscription struct {
+	broker  *memoryBroker
+	topic   string
+	watcher bool
+	c       chan []byte
+	// signals that there is something in the queue.
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+	queue  [][]byte
+}
+
+// NewMemoryBroker creates a Broker that only passes messages
+// between players connected to this server.
+func NewMemoryBroker() Broker {
+	return &memoryBroker{topics: map[string]map[*memorySubscription]bool{}}
+}
+
+// Publish queues the data for each subscriber of the topic.
+func (b *memoryBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.topics[topic] {
+		s.push(data)
+	}
+
+	return nil
+}
+
+// Subscribe subscribes to the topic.
+func (b *memoryBroker) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	return b.subscribe(ctx, topic, false), nil
+}
+
+// Watch subscribes to the topic, without being counted as a subscriber.
+func (b *memoryBroker) Watch(ctx context.Context, topic string) (Subscription, error) {
+	return b.subscribe(ctx, topic, true), nil
+}
+
+func (b *memoryBroker) subscribe(ctx context.Context, topic string, watcher bool) *memorySubscription {
+	s := &memorySubscription{
+		broker:  b,
+		topic:   topic,
+		watcher: watcher,
+		c:       make(chan []byte),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs, ok := b.topics[topic]
+	if !ok {
+		subs = map[*memorySubscription]bool{}
+		b.topics[topic] = subs
+	}
+	subs[s] = true
+
+	go s.receive(ctx)
+
+	return s
+}
+
+// Subscribers returns how many subscribers the topic has, not counting watchers.
+func (b *memoryBroker) Subscribers(ctx context.Context, topic string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	for s := range b.topics[topic] {
+		if !s.watcher {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Close does nothing, as there is nothing to close.
+func (b *memoryBroker) Close() error {
+	return nil
+}
+
+// push adds the data to the queue.
+func (s *memorySubscription) push(data []byte) {
+	s.mu.Lock()
+	s.queue = append(s.queue, data)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the next data off the queue, if there is any.
+func (s *memorySubscription) pop() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+
+	data := s.queue[0]
+	s.queue = s.queue[1:]
+	return data, true
+}
+
+// receive sends the queued messages down the channel, until we have
+// unsubscribed, or the context is done. Closes the channel when finished.
+func (s *memorySubscription) receive(ctx context.Context) {
+	defer close(s.c)
+
+	for {
+		data, ok := s.pop()
+		if !ok {
+			select {
+			case <-s.notify:
+				continue
+			case <-s.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case s.c <- data:
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Messages returns the channel of messages.
+func (s *memorySubscription) Messages() <-chan []byte {
+	return s.c
+}
+
+// Unsubscribe removes the subscription from the topic.
+func (s *memorySubscription) Unsubscribe() error {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.topics[s.topic], s)
+	if len(b.topics[s.topic]) == 0 {
+		delete(b.topics, s.topic)
+	}
+
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
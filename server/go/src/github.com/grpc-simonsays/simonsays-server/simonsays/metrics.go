@@ -0,0 +1,147 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"log"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/metrics"
+)
+
+const (
+	// the modes a game can be played in, as a metric label.
+	publicMode  = "public"
+	privateMode = "private"
+	soloMode    = "solo"
+)
+
+const (
+	// the role a player had in a game, as a metric label.
+	// The host started the game, and the joiners found it.
+	hostRole   = "host"
+	joinerRole = "joiner"
+)
+
+var (
+	joinsTotal = metrics.NewCounter("simonsays_joins_total",
+		"Number of players that have asked to join a game.", "mode")
+	gamesStartedTotal = metrics.NewCounter("simonsays_games_started_total",
+		"Number of games that have begun.", "mode")
+	gamesFinishedTotal = metrics.NewCounter("simonsays_games_finished_total",
+		"Number of games that have been played to the end.", "mode")
+	winsTotal = metrics.NewCounter("simonsays_wins_total",
+		"Number of games won, by whether the winner started the game or joined it.", "role")
+	pressesTotal = metrics.NewCounter("simonsays_presses_total",
+		"Number of colours pressed by players in their turn.", "mode")
+	publishSeconds = metrics.NewHistogram("simonsays_publish_duration_seconds",
+		"How long it takes to publish a message to a game's topic.", metrics.DefaultBuckets)
+	ensureSubscribersRetries = metrics.NewCounter("simonsays_ensure_subscribers_retries_total",
+		"Number of times the subscribers of a game were checked again, because not everyone had subscribed.")
+	matchmakingSeconds = metrics.NewHistogram("simonsays_matchmaking_wait_seconds",
+		"How long players wait to be matched with a game.",
+		[]float64{.1, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}, "mode")
+)
+
+func init() {
+	metrics.Default.MustRegister(joinsTotal, gamesStartedTotal, gamesFinishedTotal, winsTotal,
+		pressesTotal, publishSeconds, ensureSubscribersRetries, matchmakingSeconds)
+}
+
+// RegisterMetrics adds the gauges of the open games waiting for players, by their size,
+// and the Redis connections in use, to the registry. They are brought up to date each
+// time the metrics are collected. The open games are counted in the background, so
+// collecting never waits on Redis, and are as of the last count that finished.
+// Counts that fail are added to the Redis errors.
+func (s *SimonSays) RegisterMetrics(r *metrics.Registry) error {
+	openGames := metrics.NewGauge("simonsays_open_games",
+		"Number of open games waiting for players.", "players")
+	poolActive := metrics.NewGauge("simonsays_redis_pool_active_connections",
+		"Number of connections in the Redis pool, including those in use.")
+	redisErrors := metrics.NewCounter("simonsays_metrics_redis_errors_total",
+		"Number of times the open games could not be counted from Redis.")
+
+	if err := r.Register(openGames, poolActive, redisErrors); err != nil {
+		return err
+	}
+
+	// set while the open games are being counted, so only one count runs at a time.
+	var counting int32
+
+	r.OnCollect(func() {
+		poolActive.Set(float64(s.pool.ActiveCount()))
+
+		if !atomic.CompareAndSwapInt32(&counting, 0, 1) {
+			return
+		}
+
+		go func() {
+			defer atomic.StoreInt32(&counting, 0)
+
+			if err := s.countOpenGames(openGames); err != nil {
+				log.Printf("[Warn][Metrics] Could not count the open games. %v", err)
+				redisErrors.Inc()
+			}
+		}()
+	})
+
+	return nil
+}
+
+// countOpenGames sets the gauge to the number of open games of each size.
+func (s *SimonSays) countOpenGames(openGames *metrics.Gauge) error {
+	con := s.pool.Get()
+	defer con.Close()
+
+	for size := minPlayers; size <= maxPlayers; size++ {
+		n, err := redis.Int(con.Do("ZCARD", openGamesKey(s.scope, size)))
+		if err != nil {
+			return err
+		}
+		openGames.Set(float64(n), strconv.Itoa(size))
+	}
+
+	return nil
+}
+
+// joinMode is the mode the player has asked to play in.
+func joinMode(player *Request_Player) string {
+	switch {
+	case player.Mode == Request_SOLO:
+		return soloMode
+	case player.Private || player.Room != "":
+		return privateMode
+	}
+	return publicMode
+}
+
+// gameMode is the mode a game with other players is played in.
+func gameMode(game *Game) string {
+	if game.Room != "" {
+		return privateMode
+	}
+	return publicMode
+}
+
+// gameRole is the role the player had in the game.
+func gameRole(game *Game) string {
+	if game.Host {
+		return hostRole
+	}
+	return joinerRole
+}
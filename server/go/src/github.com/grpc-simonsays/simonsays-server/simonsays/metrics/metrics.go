@@ -0,0 +1,352 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+// Package metrics is just enough of Prometheus to count what
+// the application is doing, and expose it in the text format
+// for Prometheus to scrape.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the default histogram buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the application's metrics are registered with.
+var Default = NewRegistry()
+
+// Metric is something that can be written out in the Prometheus text format.
+type Metric interface {
+	// Name is the name of the metric.
+	Name() string
+	// write writes out the metric.
+	write(w io.Writer)
+}
+
+// Registry is a set of metrics, that can be served over HTTP.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]Metric
+	// run before the metrics are written out.
+	collectors []func()
+}
+
+// NewRegistry returns a new Registry with no metrics.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]Metric{}}
+}
+
+// Register adds the metrics to the registry.
+// Returns an error if there is already a metric with the same name.
+func (r *Registry) Register(metrics ...Metric) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range metrics {
+		if _, ok := r.metrics[m.Name()]; ok {
+			return fmt.Errorf("Metric %v is already registered", m.Name())
+		}
+		r.metrics[m.Name()] = m
+	}
+
+	return nil
+}
+
+// MustRegister adds the metrics to the registry. Panics if there is already
+// a metric with the same name.
+func (r *Registry) MustRegister(metrics ...Metric) {
+	if err := r.Register(metrics...); err != nil {
+		panic(err)
+	}
+}
+
+// OnCollect adds a function that is run each time the metrics are written out,
+// to bring gauges up to date.
+func (r *Registry) OnCollect(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, fn)
+}
+
+// Write writes out all the metrics in the Prometheus text format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]func(){}, r.collectors...)
+	var names []string
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]Metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	for _, fn := range collectors {
+		fn()
+	}
+
+	var b bytes.Buffer
+	for _, m := range metrics {
+		m.write(&b)
+	}
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := r.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// desc describes a metric, and the names of its labels.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// Name is the name of the metric.
+func (d desc) Name() string {
+	return d.name
+}
+
+// header writes out the HELP and TYPE lines for the metric.
+func (d desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.Replace(d.help, "\n", `\n`, -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// key joins label values into a map key.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("Metric %v has %v labels, given %v values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats the labels for the given key, with any extra pairs on the end.
+func (d desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", d.labels[i], v))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// values is a set of values, by their label key.
+type values struct {
+	desc
+	mu sync.Mutex
+	m  map[string]float64
+}
+
+// add adds to the value for the labels.
+func (v *values) add(delta float64, labels []string) {
+	k := v.key(labels)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.m[k] += delta
+}
+
+// set sets the value for the labels.
+func (v *values) set(value float64, labels []string) {
+	k := v.key(labels)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.m[k] = value
+}
+
+// write writes out the metric, with its values sorted by label.
+func (v *values) write(w io.Writer) {
+	v.header(w)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, k := range sortedKeys(v.m) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelPairs(k), formatFloat(v.m[k]))
+	}
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	values
+}
+
+// NewCounter returns a new Counter, with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{values{desc: desc{name, help, "counter", labels}, m: map[string]float64{}}}
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *Counter) Inc(labels ...string) {
+	c.add(1, labels)
+}
+
+// Add adds to the counter with the given label values. Panics if v is negative.
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		panic("Counters can't go down")
+	}
+	c.add(v, labels)
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	values
+}
+
+// NewGauge returns a new Gauge, with the given label names.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{values{desc: desc{name, help, "gauge", labels}, m: map[string]float64{}}}
+}
+
+// Set sets the gauge with the given label values.
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.set(v, labels)
+}
+
+// Add adds to the gauge with the given label values.
+func (g *Gauge) Add(v float64, labels ...string) {
+	g.add(v, labels)
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*series
+}
+
+// series is the buckets of a histogram for one set of labels.
+type series struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns a new Histogram, with the given upper bounds
+// for its buckets, and label names.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+
+	return &Histogram{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: b,
+		series:  map[string]*series{},
+	}
+}
+
+// Observe adds an observation to the histogram with the given label values.
+func (h *Histogram) Observe(v float64, labels ...string) {
+	k := h.key(labels)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[k]
+	if !ok {
+		s = &series{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// ObserveSince observes how many seconds it has been since start.
+func (h *Histogram) ObserveSince(start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+// write writes out the histogram, with its series sorted by label.
+func (h *Histogram) write(w io.Writer) {
+	h.header(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(k), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(k), s.count)
+	}
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a value the way Prometheus expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,116 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestCounter tests counting with and without labels.
+func TestCounter(t *testing.T) {
+	Convey("Given a registry with counters", t, func() {
+		r := NewRegistry()
+		plain := NewCounter("plain_total", "A plain counter.")
+		labelled := NewCounter("labelled_total", "A labelled counter.", "mode")
+		r.MustRegister(plain, labelled)
+
+		plain.Inc()
+		plain.Add(2)
+		labelled.Inc("solo")
+		labelled.Inc("public")
+		labelled.Inc("public")
+
+		Convey("They are written out sorted by name, then label", func() {
+			var b bytes.Buffer
+			So(r.Write(&b), ShouldBeNil)
+			So(b.String(), ShouldEqual, `# HELP labelled_total A labelled counter.
+# TYPE labelled_total counter
+labelled_total{mode="public"} 2
+labelled_total{mode="solo"} 1
+# HELP plain_total A plain counter.
+# TYPE plain_total counter
+plain_total 3
+`)
+		})
+
+		Convey("Counters can't go down", func() {
+			So(func() { plain.Add(-1) }, ShouldPanic)
+		})
+
+		Convey("Label values have to match the label names", func() {
+			So(func() { labelled.Inc() }, ShouldPanic)
+		})
+
+		Convey("Metrics can't be registered twice", func() {
+			So(r.Register(NewCounter("plain_total", "Again.")), ShouldNotBeNil)
+		})
+	})
+}
+
+// TestGauge tests that gauges are brought up to date when collected.
+func TestGauge(t *testing.T) {
+	Convey("Given a gauge that is set on collection", t, func() {
+		r := NewRegistry()
+		g := NewGauge("queue_length", "The queue length.", "size")
+		r.MustRegister(g)
+
+		n := 0
+		r.OnCollect(func() {
+			n++
+			g.Set(float64(n), "2")
+		})
+
+		Convey("It is up to date each time it is served", func() {
+			for i := 1; i <= 2; i++ {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+				So(w.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+				So(w.Body.String(), ShouldContainSubstring, fmt.Sprintf(`queue_length{size="2"} %v`, i))
+			}
+		})
+	})
+}
+
+// TestHistogram tests observations are counted in every bucket they fit in.
+func TestHistogram(t *testing.T) {
+	Convey("Given a histogram with some observations", t, func() {
+		r := NewRegistry()
+		h := NewHistogram("wait_seconds", "The wait.", []float64{1, 0.5})
+		r.MustRegister(h)
+
+		h.Observe(0.25)
+		h.Observe(0.75)
+		h.Observe(2)
+
+		Convey("The buckets are cumulative, with the sum and count", func() {
+			var b bytes.Buffer
+			So(r.Write(&b), ShouldBeNil)
+			So(b.String(), ShouldEqual, `# HELP wait_seconds The wait.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.5"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 3
+wait_seconds_count 3
+`)
+		})
+	})
+}
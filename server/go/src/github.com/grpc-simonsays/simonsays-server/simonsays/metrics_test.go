@@ -0,0 +1,88 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/metrics"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestRegisterMetrics tests the gauges of the open games and Redis pool.
+func TestRegisterMetrics(t *testing.T) {
+	Convey("Given a SimonSays with its metrics registered", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+
+		r := metrics.NewRegistry()
+		So(game.RegisterMetrics(r), ShouldBeNil)
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		Convey("When there are open games, they are counted by size", func() {
+			_, err := con.Do("ZADD", openGamesKey("", 2), initialRating, "one")
+			So(err, ShouldBeNil)
+			_, err = con.Do("ZADD", openGamesKey("", 3), initialRating, "two")
+			So(err, ShouldBeNil)
+			_, err = con.Do("ZADD", openGamesKey("", 3), initialRating, "three")
+			So(err, ShouldBeNil)
+
+			// they are counted in the background, so wait for the count to finish.
+			out := collectUntil(r, `simonsays_open_games{players="8"} 0`)
+			So(out, ShouldContainSubstring, `simonsays_open_games{players="2"} 1`)
+			So(out, ShouldContainSubstring, `simonsays_open_games{players="3"} 2`)
+			So(out, ShouldContainSubstring, `simonsays_open_games{players="8"} 0`)
+			So(out, ShouldContainSubstring, "simonsays_redis_pool_active_connections ")
+		})
+
+		Convey("When Redis can't be reached, the open games aren't counted", func() {
+			gone := &SimonSays{pool: newPool("localhost:1")}
+			defer gone.pool.Close()
+
+			r := metrics.NewRegistry()
+			So(gone.RegisterMetrics(r), ShouldBeNil)
+
+			out := collectUntil(r, "simonsays_metrics_redis_errors_total 1")
+			So(out, ShouldContainSubstring, "simonsays_metrics_redis_errors_total 1")
+			So(out, ShouldNotContainSubstring, "simonsays_open_games{")
+		})
+
+		Convey("The metrics can't be registered twice", func() {
+			So(game.RegisterMetrics(r), ShouldNotBeNil)
+		})
+	})
+}
+
+// collectUntil writes out the metrics until they contain the substring,
+// or it times out, and returns the last of them.
+func collectUntil(r *metrics.Registry, substr string) string {
+	deadline := time.Now().Add(timeOut)
+
+	for {
+		var b bytes.Buffer
+		if err := r.Write(&b); err != nil || strings.Contains(b.String(), substr) || time.Now().After(deadline) {
+			return b.String()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
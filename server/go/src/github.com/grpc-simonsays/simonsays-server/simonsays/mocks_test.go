@@ -33,24 +33,30 @@ type mockStream struct {
 	sendChan chan *Response
 	recvChan chan *Request
 	ctx      context.Context
+	cancel   context.CancelFunc
 }
 
 // newMockStream creates a new mock stream for testing.
 func newMockStream() *mockStream {
 	// just need a new context specific to this stream,
 	// easiest way to get it.
-	ctx, _ := context.WithCancel(context.TODO())
+	ctx, cancel := context.WithCancel(context.TODO())
 
 	return &mockStream{
 		sendChan: make(chan *Response, 100),
 		recvChan: make(chan *Request, 100),
 		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
 // Send Sends a Response to the mocked client, which
 // can then be retrieved by PullSend().
 func (m *mockStream) Send(r *Response) error {
+	if err := m.ctx.Err(); err != nil {
+		return err
+	}
+
 	select {
 	case m.sendChan <- r:
 	case <-time.After(timeOut):
@@ -65,6 +71,8 @@ func (m *mockStream) PullSend() (*Response, error) {
 	select {
 	case r := <-m.sendChan:
 		return r, nil
+	case <-m.ctx.Done():
+		return nil, m.ctx.Err()
 	case <-time.After(timeOut):
 		return nil, errors.New("Timeout on PullSend")
 	}
@@ -91,6 +99,8 @@ func (m *mockStream) Recv() (*Request, error) {
 		}
 
 		return r, nil
+	case <-m.ctx.Done():
+		return nil, m.ctx.Err()
 	case <-time.After(timeOut):
 		return nil, errors.New("Timout on Recv")
 	}
@@ -102,6 +112,11 @@ func (m *mockStream) Close() {
 	close(m.sendChan)
 }
 
+// Disconnect drops the mocked client, as if the connection was lost.
+func (m *mockStream) Disconnect() {
+	m.cancel()
+}
+
 func (m *mockStream) SendHeader(md metadata.MD) error { return nil }
 func (m *mockStream) SetHeader(md metadata.MD) error  { return nil }
 func (m *mockStream) SetTrailer(md metadata.MD)       {}
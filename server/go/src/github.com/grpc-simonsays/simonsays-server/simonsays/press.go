@@ -16,19 +16,16 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
-	"errors"
+	"time"
 
-	"github.com/garyburd/redigo/redis"
 	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
 )
 
 // recvPress Manages receiving Press Events through a go-routine.
-// Will need it's own Redis connection, that it will close once complete.
 // Sends io.EOF when the connection closes, and pushes the error into the chan if it
-// occurs.
-func recvPress(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer) <-chan error {
+// occurs. Presses faster than the limiter allows end with ErrRateLimited.
+func recvPress(broker Broker, limiter Limiter, game *Game, player *Request_Player, stream SimonSays_GameServer) <-chan error {
 	lc := "RecvPress"
 	ctx := stream.Context()
 	c := make(chan error, 10)
@@ -37,9 +34,8 @@ func recvPress(con redis.Conn, game *Game, player *Request_Player, stream SimonS
 
 	go func() {
 		defer close(c)
-		defer con.Close()
 		for {
-			stop, err := handleColorPress(con, game, player, stream)
+			stop, err := handleColorPress(broker, limiter, game, player, stream)
 			if err != nil {
 				c <- err
 				return
@@ -53,9 +49,9 @@ func recvPress(con redis.Conn, game *Game, player *Request_Player, stream SimonS
 }
 
 // handleColorPress handles one color being pressed.
-// If it's the player turn it modifies the given game and sends a lightUpMessage to Redis.
+// If it's the player turn it modifies the given game and sends a lightUpMessage to the Broker.
 // This function is thread safe.
-func handleColorPress(con redis.Conn, game *Game, player *Request_Player, stream SimonSays_GameServer) (bool, error) {
+func handleColorPress(broker Broker, limiter Limiter, game *Game, player *Request_Player, stream SimonSays_GameServer) (bool, error) {
 	lc := "handleColorPress"
 	ctx := stream.Context()
 	press, err := receivePressRequest(stream)
@@ -65,7 +61,11 @@ func handleColorPress(con redis.Conn, game *Game, player *Request_Player, stream
 		return true, err
 	}
 
-	logger.Info(ctx, lc, "Press Received: %v", press)
+	if err := checkLimit(ctx, limiter, player.Id); err != nil {
+		return true, err
+	}
+
+	logger.Debug(ctx, lc, "Press Received: %v", press)
 
 	//lock the game for this entire block, since we are doing lots of things with
 	//it, and this will prevent any concurrency issues.
@@ -85,13 +85,15 @@ func handleColorPress(con redis.Conn, game *Game, player *Request_Player, stream
 		return true, err
 	}
 
-	err = sendLightupEvent(press, stream, con, game)
+	pressesTotal.Inc(gameMode(game))
+
+	err = sendLightupEvent(press, stream, broker, game)
 	if err != nil {
 		return true, err
 	}
 
 	// When you reach the point that the game has turned.
-	return handleEndOfTurn(stream, con, game, player)
+	return handleEndOfTurn(stream, broker, game, player)
 }
 
 // receivePress Receives a press. Returns an error if there is an issue, and publishes it
@@ -109,31 +111,29 @@ func receivePressRequest(stream SimonSays_GameServer) (*Request_Press, error) {
 	press, ok := res.Event.(*Request_Press)
 
 	if !ok {
-		err := errors.New("Recieved a request other than a Press")
+		err := invalidRequestError("Recieved a request other than a Press")
 		logger.Error(ctx, lc, "Error: %v. %v", err, res)
 		return nil, err
 	}
 
+	if err := validateColor(press.Press); err != nil {
+		logger.Warn(ctx, lc, "Invalid press. %v", err)
+		return nil, err
+	}
+
 	return press, nil
 }
 
 // sendLightupEvent sends out a lightup event to everyone.
-func sendLightupEvent(press *Request_Press, stream SimonSays_GameServer, con redis.Conn, game *Game) error {
-	buf := new(bytes.Buffer)
-	err := gob.NewEncoder(buf).Encode(press.Press)
+func sendLightupEvent(press *Request_Press, stream SimonSays_GameServer, broker Broker, game *Game) error {
 	ctx := stream.Context()
 
-	if err != nil {
-		logger.Info(ctx, "sendLightupEvent", "Error gob encoding Color. %v, %v", press.Press, err)
-		return err
-	}
-
 	// send out lightup events.
-	return publish(ctx, con, game, message{Type: lightUpMessage, Data: buf.Bytes()})
+	return publish(ctx, broker, game, message{Type: lightUpMessage, Colors: []Color{press.Press}})
 }
 
 // handleEndOfTurn handles if it is the end of the turn, and if the player has lost (bool).
-func handleEndOfTurn(stream SimonSays_GameServer, con redis.Conn, game *Game, player *Request_Player) (bool, error) {
+func handleEndOfTurn(stream SimonSays_GameServer, broker Broker, game *Game, player *Request_Player) (bool, error) {
 	lc := "handleEndOfTurn"
 	ctx := stream.Context()
 
@@ -143,14 +143,8 @@ func handleEndOfTurn(stream SimonSays_GameServer, con redis.Conn, game *Game, pl
 	}
 
 	if game.match() {
-		b, err := game.encodePresses()
-		if err != nil {
-			logger.Error(ctx, lc, "Error encoding presses: %#v, %v", game, err)
-			return false, err
-		}
-
-		msg := message{Type: stopTurnMessage, Player: player.Id, Data: b}
-		if err := publish(ctx, con, game, msg); err != nil {
+		msg := message{Type: stopTurnMessage, Player: player.Id, Colors: game.currentPresses}
+		if err := publish(ctx, broker, game, msg); err != nil {
 			logger.Error(ctx, lc, "error publishing StopTurnMessage %#v, %v", msg, err)
 			return false, err
 		}
@@ -159,9 +153,8 @@ func handleEndOfTurn(stream SimonSays_GameServer, con redis.Conn, game *Game, pl
 	}
 
 	// if there is no match, you did something wrong. otherwise, my friend, you have lost the game.
-	msg := message{Type: lostMessage, Player: player.Id}
-	if err := publish(ctx, con, game, msg); err != nil {
-		logger.Error(ctx, lc, "error publishing LostMessage %#v, %v", msg, err)
+	// the game is already locked, so the sequence to pass on is read straight from it.
+	if err := publishLostSequence(ctx, broker, game, player, game.validPresses, Elimination_WRONG_COLOR); err != nil {
 		return false, err
 	}
 
@@ -169,3 +162,48 @@ func handleEndOfTurn(stream SimonSays_GameServer, con redis.Conn, game *Game, pl
 	logger.Info(ctx, lc, "We are done taking input. Returning that we have lost. %#v", game)
 	return true, nil
 }
+
+// handleTurnTimeout checks if the player has run out of time for their turn,
+// and if so, lets everyone know they have lost.
+func handleTurnTimeout(stream SimonSays_GameServer, broker Broker, game *Game, player *Request_Player) error {
+	lc := "handleTurnTimeout"
+	ctx := stream.Context()
+
+	if !game.ExpireTurn(time.Now()) {
+		return nil
+	}
+
+	logger.Info(ctx, lc, "Player ran out of time. They have lost.")
+
+	return publishLost(ctx, broker, game, player, Elimination_TIMEOUT)
+}
+
+// pressErrorReason is why the player is eliminated for the press error that ended
+// their game, if it is one the other players need to be told about.
+func pressErrorReason(err error) (Elimination_Reason, bool) {
+	if err == ErrRateLimited {
+		return Elimination_RATE_LIMITED, true
+	}
+	if _, ok := err.(invalidRequestError); ok {
+		return Elimination_INVALID_REQUEST, true
+	}
+	return Elimination_UNKNOWN, false
+}
+
+// publishLost lets everyone know the player has lost, and why. The sequence is passed on,
+// in case it was their turn, so the next player can have a go at it.
+func publishLost(ctx context.Context, broker Broker, game *Game, player *Request_Player, reason Elimination_Reason) error {
+	return publishLostSequence(ctx, broker, game, player, game.ValidPresses(), reason)
+}
+
+// publishLostSequence is publishLost, passing on the given sequence,
+// for when the game is already locked.
+func publishLostSequence(ctx context.Context, broker Broker, game *Game, player *Request_Player, seq []Color, reason Elimination_Reason) error {
+	msg := message{Type: lostMessage, Player: player.Id, Colors: seq, Reason: reason}
+	if err := publish(ctx, broker, game, msg); err != nil {
+		logger.Error(ctx, "PublishLost", "error publishing LostMessage %#v, %v", msg, err)
+		return err
+	}
+
+	return nil
+}
@@ -16,8 +16,6 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
 	"io"
 	"testing"
 	"time"
@@ -31,21 +29,16 @@ func TestRecvPress(t *testing.T) {
 
 	Convey("When you have a GREEN button being pressed", t, func() {
 		stream := newMockStream()
-		server := mustSimonSays()
-		defer server.Close()
+		broker := NewMemoryBroker()
 
 		player := &Request_Player{Id: "Player One"}
 
-		con := server.pool.Get()
-
-		_, err := con.Do("FLUSHDB")
-		So(err, ShouldBeNil)
 		u, err := uuid.NewV4()
 		So(err, ShouldBeNil)
 		game := NewGame(u.String())
 		game.StartTurn([]Color{Color_GREEN})
 
-		msgs, err := subscribe(stream.Context(), server.pool.Get(), game)
+		msgs, _, err := subscribe(stream.Context(), broker, game)
 		So(err, ShouldBeNil)
 
 		press := &Request{Event: &Request_Press{Press: Color_GREEN}}
@@ -53,18 +46,13 @@ func TestRecvPress(t *testing.T) {
 		So(err, ShouldBeNil)
 
 		Convey("We should recieve a lightup event through pubsub", func() {
-			errors := recvPress(server.pool.Get(), game, player, stream)
+			errors := recvPress(broker, nil, game, player, stream)
 
 			select {
 			case msg := <-msgs:
 				So(msg.Type, ShouldEqual, lightUpMessage)
 
-				// decode the data, make sure it's okay
-				c := new(Color)
-				buf := bytes.NewBuffer(msg.Data)
-				err := gob.NewDecoder(buf).Decode(c)
-				So(err, ShouldBeNil)
-				So(*c, ShouldEqual, Color_GREEN)
+				So(msg.Colors, ShouldResemble, []Color{Color_GREEN})
 				So(game.currentPresses, ShouldResemble, []Color{Color_GREEN})
 
 			case <-time.After(3 * time.Second):
@@ -89,12 +77,7 @@ func TestRecvPress(t *testing.T) {
 				case msg := <-msgs:
 					So(msg.Type, ShouldEqual, stopTurnMessage)
 
-					colors := []Color{}
-					// decode the data, make sure it's okay
-					buf := bytes.NewBuffer(msg.Data)
-					err := gob.NewDecoder(buf).Decode(&colors)
-					So(err, ShouldBeNil)
-					So(colors, ShouldResemble, []Color{Color_GREEN, Color_GREEN})
+					So(msg.Colors, ShouldResemble, []Color{Color_GREEN, Color_GREEN})
 
 				case <-time.After(3 * time.Second):
 					So("Should recieve StopTurnMessage", ShouldBeNil)
@@ -118,21 +101,16 @@ func TestSendLightupEvent(t *testing.T) {
 	Convey("When you send a lightup event", t, func() {
 		press := &Request_Press{Press: Color_GREEN}
 		stream := newMockStream()
-		server := mustSimonSays()
-		defer server.Close()
-
-		con := server.pool.Get()
-		_, err := con.Do("FLUSHDB")
-		So(err, ShouldBeNil)
+		broker := NewMemoryBroker()
 
 		u, err := uuid.NewV4()
 		So(err, ShouldBeNil)
 		game := NewGame(u.String())
 
-		msgs, err := subscribe(stream.Context(), server.pool.Get(), game)
+		msgs, _, err := subscribe(stream.Context(), broker, game)
 		So(err, ShouldBeNil)
 
-		err = sendLightupEvent(press, stream, con, game)
+		err = sendLightupEvent(press, stream, broker, game)
 		So(err, ShouldBeNil)
 
 		Convey("You should recieve a LightUpMessage over pubsub", func() {
@@ -140,12 +118,7 @@ func TestSendLightupEvent(t *testing.T) {
 			case msg := <-msgs:
 				So(msg.Type, ShouldEqual, lightUpMessage)
 
-				// decode the data, make sure it's okay
-				c := new(Color)
-				buf := bytes.NewBuffer(msg.Data)
-				err := gob.NewDecoder(buf).Decode(c)
-				So(err, ShouldBeNil)
-				So(*c, ShouldEqual, Color_GREEN)
+				So(msg.Colors, ShouldResemble, []Color{Color_GREEN})
 
 			case <-time.After(3 * time.Second):
 				So("Should recieve lightup event", ShouldBeNil)
@@ -158,27 +131,25 @@ func TestSendLightupEvent(t *testing.T) {
 func TestHandleEndOfTurn(t *testing.T) {
 	Convey("When we have started a turn", t, func() {
 		stream := newMockStream()
-		server := mustSimonSays()
-		defer server.Close()
+		broker := NewMemoryBroker()
 		u, err := uuid.NewV4()
 		So(err, ShouldBeNil)
 
 		game := NewGame(u.String())
 		player := &Request_Player{Id: "Player One"}
 
-		con := server.pool.Get()
 		colors := []Color{Color_GREEN, Color_BLUE}
 
 		game.StartTurn(colors)
 
-		msgs, err := subscribe(stream.Context(), server.pool.Get(), game)
+		msgs, _, err := subscribe(stream.Context(), broker, game)
 		So(err, ShouldBeNil)
 
 		Convey("We press a color that is right", func() {
 			err := game.PressColor(Color_GREEN)
 			So(err, ShouldBeNil)
 
-			lost, err := handleEndOfTurn(stream, con, game, player)
+			lost, err := handleEndOfTurn(stream, broker, game, player)
 			So(err, ShouldBeNil)
 			So(lost, ShouldBeFalse)
 
@@ -193,7 +164,7 @@ func TestHandleEndOfTurn(t *testing.T) {
 				err := game.PressColor(Color_BLUE)
 				So(err, ShouldBeNil)
 
-				lost, err := handleEndOfTurn(stream, con, game, player)
+				lost, err := handleEndOfTurn(stream, broker, game, player)
 				So(err, ShouldBeNil)
 				So(lost, ShouldBeFalse)
 
@@ -210,7 +181,7 @@ func TestHandleEndOfTurn(t *testing.T) {
 
 					So(game.IsMyTurn(), ShouldBeFalse)
 
-					lost, err := handleEndOfTurn(stream, con, game, player)
+					lost, err := handleEndOfTurn(stream, broker, game, player)
 					So(err, ShouldBeNil)
 					So(lost, ShouldBeFalse)
 
@@ -218,12 +189,7 @@ func TestHandleEndOfTurn(t *testing.T) {
 					case msg := <-msgs:
 						So(msg.Type, ShouldEqual, stopTurnMessage)
 
-						colors := []Color{}
-						// decode the data, make sure it's okay.
-						buf := bytes.NewBuffer(msg.Data)
-						err := gob.NewDecoder(buf).Decode(&colors)
-						So(err, ShouldBeNil)
-						So(colors, ShouldResemble, []Color{Color_GREEN, Color_BLUE, Color_BLUE})
+						So(msg.Colors, ShouldResemble, []Color{Color_GREEN, Color_BLUE, Color_BLUE})
 
 					case <-time.After(3 * time.Second):
 						So("Should recieve StopTurnMessage", ShouldBeNil)
@@ -235,7 +201,7 @@ func TestHandleEndOfTurn(t *testing.T) {
 				err := game.PressColor(Color_YELLOW)
 				So(err, ShouldBeNil)
 
-				lost, err := handleEndOfTurn(stream, con, game, player)
+				lost, err := handleEndOfTurn(stream, broker, game, player)
 				So(err, ShouldBeNil)
 				So(lost, ShouldBeTrue)
 				So(game.IsMyTurn(), ShouldBeFalse)
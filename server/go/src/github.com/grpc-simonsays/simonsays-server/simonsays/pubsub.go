@@ -16,109 +16,164 @@ limitations under the License.
 package simonsays
 
 import (
-	"bytes"
-	"encoding/gob"
 	"errors"
-	"fmt"
 	"strconv"
 	"time"
 
-	"github.com/garyburd/redigo/redis"
+	"github.com/golang/protobuf/proto"
 	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
 	"golang.org/x/net/context"
 )
 
+// eventVersion is the version of the Event schema this server writes.
+const eventVersion = 1
+
 // message is the pubsub message
-// that gets sent.
+// that gets sent. It goes over the wire as an Event.
 type message struct {
-	//leave these as exported, so gob will encode/decode them
-	Type   string
+	Type   Event_Type
 	Player string
-	Data   []byte
+	// the sequence of colours, or the colour that lit up.
+	Colors []Color
+	// the players in turn order.
+	Players  []string
+	Sequence int64
+	// when the message was published.
+	Time time.Time
+	// why the player lost, on a lostMessage.
+	Reason Elimination_Reason
 }
 
-// encode convert into []bytes as gob
-func (m *message) marshalGob() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	enc := gob.NewEncoder(buf)
-	err := enc.Encode(m)
-	return buf.Bytes(), err
+// event converts into an Event protobuf.
+func (m *message) event() *Event {
+	e := &Event{
+		Version:  eventVersion,
+		Type:     m.Type,
+		Player:   m.Player,
+		Colors:   m.Colors,
+		Players:  m.Players,
+		Sequence: m.Sequence,
+		Reason:   m.Reason,
+	}
+
+	if !m.Time.IsZero() {
+		e.Time = millis(m.Time)
+	}
+
+	return e
 }
 
-// decode converts from []bytes back into the object
-func (m *message) unmarshalGob(b []byte) error {
-	buf := bytes.NewBuffer(b)
-	dec := gob.NewDecoder(buf)
-	return dec.Decode(m)
+// marshal converts into []bytes as an Event protobuf.
+func (m *message) marshal() ([]byte, error) {
+	return proto.Marshal(m.event())
 }
 
-// subscribe subscribes to the topic for this game
-// Returns a channel of Messages that can be used to receive messages
-// Make sure to send it a new redis.Conn. It will handle closing it
-// when finished.
-func subscribe(ctx context.Context, con redis.Conn, g *Game) (<-chan *message, error) {
-	lc := "Subscribe"
-	c := make(chan *message)
+// unmarshal converts from Event protobuf []bytes back into the object.
+// Fields added by newer versions of the schema are ignored.
+func (m *message) unmarshal(b []byte) error {
+	e := new(Event)
+	if err := proto.Unmarshal(b, e); err != nil {
+		return err
+	}
+
+	if e.Version == 0 {
+		return errors.New("Event has no schema version")
+	}
 
-	psc := redis.PubSubConn{con}
+	m.fromEvent(e)
+	return nil
+}
+
+// fromEvent converts from an Event protobuf.
+func (m *message) fromEvent(e *Event) {
+	*m = message{
+		Type:     e.Type,
+		Player:   e.Player,
+		Colors:   e.Colors,
+		Players:  e.Players,
+		Sequence: e.Sequence,
+		Reason:   e.Reason,
+	}
+
+	if e.Time != 0 {
+		m.Time = time.Unix(0, e.Time*int64(time.Millisecond))
+	}
+}
 
-	logger.Info(ctx, lc, "Subscribing to topic '%v'", g.ID)
+// subscribe subscribes to the topic for this game
+// Returns a channel of Messages that can be used to receive messages,
+// and the Subscription, to unsubscribe with when finished.
+func subscribe(ctx context.Context, broker Broker, g *Game) (<-chan *message, Subscription, error) {
+	sub, err := broker.Subscribe(ctx, g.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return decodeMessages(ctx, sub, g), sub, nil
+}
 
-	err := psc.Subscribe(g.ID)
+// watch subscribes to the topic for this game as a spectator.
+// Works like subscribe, but the subscription is not counted as a player.
+func watch(ctx context.Context, broker Broker, g *Game) (<-chan *message, Subscription, error) {
+	sub, err := broker.Watch(ctx, g.ID)
 	if err != nil {
-		logger.Info(ctx, lc, "Error Subscribing. %v", err)
-		close(c)
-		return c, err
+		return nil, nil, err
 	}
 
-	go func(c chan<- *message) {
-		defer con.Close()
+	return decodeMessages(ctx, sub, g), sub, nil
+}
+
+// decodeMessages decodes the messages from the subscription, and sends them down the channel,
+// until the subscription ends, or the context is done. Closes the channel when finished.
+// Messages that can't be decoded are skipped, so a game can survive a rolling upgrade.
+func decodeMessages(ctx context.Context, sub Subscription, g *Game) <-chan *message {
+	lc := "Receive"
+	c := make(chan *message)
+
+	go func() {
 		defer close(c)
-		for {
-			switch v := psc.Receive().(type) {
-			case redis.Message:
-				msg := new(message)
-				err := msg.unmarshalGob(v.Data)
-				if err != nil {
-					logger.Error(ctx, lc, "Could not decode message. Closing channel. %v, %v", v, err)
-					return
-				}
-
-				logger.Info(ctx, lc, "Received Message. Sending %#v to channel.", msg)
-
-				c <- msg
-
-				// special case for LostMessage, since we know to close at this point.
-				if msg.Type == lostMessage {
-					logger.Info(ctx, lc, "Lost Message, Closing Subscribe Pipeline.")
-					return
-				}
-			case error:
-				logger.Error(ctx, lc, "Error processing messages. Closing channel. %v", err)
+
+		for data := range sub.Messages() {
+			msg := new(message)
+			err := msg.unmarshal(data)
+			if err != nil {
+				logger.Warn(ctx, lc, "Could not decode message. Skipping. %v, %v", data, err)
+				continue
+			}
+			g.ObserveSequence(msg.Sequence)
+
+			logger.Debug(ctx, lc, "Received Message. Sending %#v to channel.", msg)
+
+			select {
+			case c <- msg:
+			case <-ctx.Done():
+				logger.Info(ctx, lc, "Context is done. Closing Subscribe Pipeline.")
 				return
-			default:
-				logger.Info(ctx, lc, "Received unknown message. Ignored: %#v", v)
 			}
 		}
-	}(c)
+	}()
 
-	return c, nil
+	return c
 }
 
 // publish publishes a message to the game's topic.
-func publish(ctx context.Context, con redis.Conn, g *Game, msg message) error {
+func publish(ctx context.Context, broker Broker, g *Game, msg message) error {
 	lc := "Publish"
 
-	logger.Info(ctx, lc, "Sending message: %#v, to topic: '%v'", msg, g.ID)
+	msg.Sequence = g.NextSequence()
+	msg.Time = time.Now()
+	logger.Debug(ctx, lc, "Sending message: %#v, to topic: '%v'", msg, g.ID)
 
-	data, err := msg.marshalGob()
+	data, err := msg.marshal()
 
 	if err != nil {
 		logger.Error(ctx, lc, "Error encoding message. %#v, %v", msg, err)
 		return err
 	}
 
-	_, err = con.Do("PUBLISH", g.ID, data)
+	start := time.Now()
+	err = broker.Publish(ctx, g.ID, data)
+	publishSeconds.ObserveSince(start)
 
 	if err != nil {
 		logger.Error(ctx, lc, "Error publishing message. %#v, %v", msg, err)
@@ -128,51 +183,28 @@ func publish(ctx context.Context, con redis.Conn, g *Game, msg message) error {
 }
 
 // ensureSubscribers Make sure n number of Game subscriptions at this point.
-// Blocks until we have two people. Times out on too many retries.
-func ensureSubscribers(ctx context.Context, con redis.Conn, g *Game, n int) error {
+// Blocks until all n players of the game (2 to 8) have subscribed. Times out on too many retries.
+func ensureSubscribers(ctx context.Context, broker Broker, g *Game, n int) error {
 	lc := "EnsureSubscribers"
 
 	for i := 0; i <= 5; i++ {
-		res, err := con.Do("PUBSUB", "NUMSUB", g.ID)
-
+		count, err := broker.Subscribers(ctx, g.ID)
 		if err != nil {
-			logger.Error(ctx, lc, "Error getting number of subscriptions: %v", err)
-			return err
-		}
-
-		vals, err := redis.Values(res, err)
-
-		if err != nil {
-			logger.Error(ctx, lc, "Error converting to values: %v", err)
-			return err
-		}
-
-		if l := len(vals); l != 2 {
-			err := fmt.Errorf("Should only be two items in the result. Weird. %#v. %v.", vals, l)
-			logger.Error(ctx, lc, err.Error())
-			return err
-		}
-
-		count, ok := vals[1].(int64)
-
-		if !ok {
-			val := vals[1]
-			err := fmt.Errorf("Second value should be an integer. %T %#v", val, val)
-			logger.Error(ctx, lc, err.Error())
 			return err
 		}
 
-		logger.Info(ctx, lc, "Found %v subscriptions for Game. Require %v", count, n)
+		logger.Debug(ctx, lc, "Found %v subscriptions for Game. Require %v", count, n)
 
-		if int(count) == n {
+		if count == n {
 			return nil
 		}
 
-		logger.Info(ctx, lc, "Could not find enough subscriptions, retrying...")
+		logger.Debug(ctx, lc, "Could not find enough subscriptions, retrying...")
+		ensureSubscribersRetries.Inc()
 		time.Sleep(100 * time.Millisecond)
 	}
 
-	err := errors.New("Timeout attempting to ensure subscriber count of " + strconv.Itoa(n))
+	err := timeoutError("Timeout attempting to ensure subscriber count of " + strconv.Itoa(n))
 	logger.Error(ctx, lc, err.Error())
 	return err
 }
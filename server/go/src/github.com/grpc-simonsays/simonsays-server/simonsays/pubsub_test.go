@@ -19,117 +19,194 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	uuid "github.com/nu7hatch/gouuid"
 	. "github.com/smartystreets/goconvey/convey"
 	"golang.org/x/net/context"
 )
 
-// TestEncodeDecode testing gob encode/decode.
+// TestEncodeDecode testing Event protobuf encode/decode.
 func TestEncodeDecode(t *testing.T) {
+	Convey("When you have something that isn't an Event", t, func() {
+		msg := new(message)
+		err := msg.unmarshal([]byte("Not an Event"))
+		So(err, ShouldNotBeNil)
+	})
+
 	Convey("When you have a message", t, func() {
-		msg := &message{Player: "Player One", Data: []byte("My Data"), Type: "BEGIN"}
+		msg := &message{Player: "Player One", Colors: []Color{Color_RED, Color_BLUE}, Type: stopTurnMessage, Sequence: 3}
 
 		Convey("You can encode it", func() {
-			b, err := msg.marshalGob()
+			b, err := msg.marshal()
 			So(err, ShouldBeNil)
 			So(b, ShouldNotBeEmpty)
 			Convey("And then decode it", func() {
 				msg2 := new(message)
-				err := msg2.unmarshalGob(b)
+				err := msg2.unmarshal(b)
 				So(err, ShouldBeNil)
 				So(msg2, ShouldResemble, msg)
 			})
+
+			Convey("And read it as an Event", func() {
+				e := new(Event)
+				err := proto.Unmarshal(b, e)
+				So(err, ShouldBeNil)
+				So(e.Version, ShouldEqual, eventVersion)
+				So(e.Type, ShouldEqual, Event_STOP_TURN)
+				So(e.Colors, ShouldResemble, msg.Colors)
+			})
 		})
 	})
 }
 
+// testBrokers are the Brokers to test with.
+var testBrokers = map[string]func() Broker{
+	"Redis":  func() Broker { return NewRedisBroker("") },
+	"Memory": NewMemoryBroker,
+}
+
 // TestPublishAndSubscribe Testing out the Message Publish and Subscribe mechanism.
 func TestPublishAndSubscribe(t *testing.T) {
-	Convey("When you are subscribed to a topic", t, func() {
-		server := mustSimonSays()
-		defer server.Close()
+	for name, newBroker := range testBrokers {
+		Convey("When you are subscribed to a topic with the "+name+" broker", t, func() {
+			broker := newBroker()
+			defer broker.Close()
 
-		con := server.pool.Get()
-		_, err := con.Do("FLUSHDB")
-		So(err, ShouldBeNil)
+			u, err := uuid.NewV4()
+			So(err, ShouldBeNil)
+			game := NewGame(u.String())
 
-		u, err := uuid.NewV4()
-		So(err, ShouldBeNil)
-		game := NewGame(u.String())
+			ctx := context.TODO()
 
-		ctx := context.TODO()
+			pubsub, sub, err := subscribe(ctx, broker, game)
+			So(err, ShouldBeNil)
+			Convey("We can create a message", func() {
+				msg := message{Player: "Player One", Players: []string{"Player One", "Player Two"}, Type: beginMessage}
+
+				Convey("We can publish a message to the topic", func() {
+					err := publish(ctx, broker, game, msg)
+					So(err, ShouldBeNil)
+
+					Convey("And we can retrieve it back", func() {
+
+						select {
+						case msg2 := <-pubsub:
+							So(msg2, ShouldNotBeNil)
+							// it's the first event in the game, stamped with when it was published.
+							msg.Sequence = 1
+							So(msg2.Time, ShouldHappenWithin, time.Second, time.Now())
+							msg.Time = msg2.Time
+							So(msg2, ShouldResemble, &msg)
+						case <-time.After(5 * time.Second):
+							So("Timeout getting message", ShouldBeNil)
+						}
+
+					})
+				})
+			})
+
+			Convey("Once we unsubscribe, the channel is closed", func() {
+				err := sub.Unsubscribe()
+				So(err, ShouldBeNil)
+
+				select {
+				case msg, ok := <-pubsub:
+					So(msg, ShouldBeNil)
+					So(ok, ShouldBeFalse)
+				case <-time.After(5 * time.Second):
+					So("Timeout waiting for channel to close", ShouldBeNil)
+				}
+			})
 
-		pubsub, err := subscribe(ctx, server.pool.Get(), game)
-		So(err, ShouldBeNil)
-		Convey("We can create a message", func() {
-			msg := message{Player: "Player One", Data: []byte("BEGIN!"), Type: "BEGIN"}
+			Convey("We can unsubscribe while the subscription is finishing", func() {
+				ctx, cancel := context.WithCancel(ctx)
+				pubsub, sub, err := subscribe(ctx, broker, game)
+				So(err, ShouldBeNil)
 
-			Convey("We can publish a message to the topic", func() {
-				err := publish(ctx, con, game, msg)
+				// the context being done, and a message coming in, ends the subscription
+				// while we are unsubscribing.
+				cancel()
+				err = publish(context.TODO(), broker, game, message{Player: "Player One", Type: beginMessage})
 				So(err, ShouldBeNil)
 
-				Convey("And we can retrieve it back", func() {
+				done := make(chan error)
+				go func() { done <- sub.Unsubscribe() }()
 
+				timeout := time.After(5 * time.Second)
+				for closed := false; !closed; {
 					select {
-					case msg2 := <-pubsub:
-						So(msg2, ShouldNotBeNil)
-						So(msg2, ShouldResemble, &msg)
-					case <-time.After(5 * time.Second):
-						So("Timeout getting message", ShouldBeNil)
+					case _, ok := <-pubsub:
+						closed = !ok
+					case <-timeout:
+						So("Timeout waiting for channel to close", ShouldBeNil)
+						closed = true
 					}
+				}
 
-				})
+				So(<-done, ShouldBeNil)
+				// and again, once it has finished.
+				So(sub.Unsubscribe(), ShouldBeNil)
 			})
 		})
-	})
+	}
 }
 
 // TestEnsureSubscribers test out ensuring we have the right number of subscribers.
 func TestEnsureSubscribers(t *testing.T) {
+	for name, newBroker := range testBrokers {
+		Convey("When you have a game that you can subscribe to with the "+name+" broker", t, func(c C) {
+			broker := newBroker()
+			defer broker.Close()
 
-	Convey("When you have a game that you can subscribe to", t, func(c C) {
-		server := mustSimonSays()
-		defer server.Close()
-
-		u, err := uuid.NewV4()
-		So(err, ShouldBeNil)
-		game := NewGame(u.String())
-		ctx := context.TODO()
-
-		done := make(chan bool)
-
-		go func(c C) {
-			defer close(done)
-			err := ensureSubscribers(ctx, server.pool.Get(), game, 2)
-			c.So(err, ShouldBeNil)
-		}(c)
-
-		select {
-		case <-done:
-			So("Should not be done at this point. No subscribers", ShouldBeNil)
-		case <-time.After(100 * time.Millisecond):
-			// this is what should happen.
-		}
-
-		_, err = subscribe(ctx, server.pool.Get(), game)
-		So(err, ShouldBeNil)
-
-		select {
-		case <-done:
-			So("Should not be done at this point. Only 1 subscriber", ShouldBeNil)
-		case <-time.After(100 * time.Millisecond):
-			// this is what should happen.
-		}
-
-		_, err = subscribe(ctx, server.pool.Get(), game)
-		So(err, ShouldBeNil)
-
-		select {
-		case <-done:
-			// this should work now
-		case <-time.After(500 * time.Millisecond):
-			So("We have two subscribers now, so done should close", ShouldBeNil)
-		}
-	})
+			u, err := uuid.NewV4()
+			So(err, ShouldBeNil)
+			game := NewGame(u.String())
+			ctx := context.TODO()
+
+			done := make(chan bool)
+
+			go func(c C) {
+				defer close(done)
+				err := ensureSubscribers(ctx, broker, game, 2)
+				c.So(err, ShouldBeNil)
+			}(c)
+
+			select {
+			case <-done:
+				So("Should not be done at this point. No subscribers", ShouldBeNil)
+			case <-time.After(100 * time.Millisecond):
+				// this is what should happen.
+			}
 
+			_, _, err = subscribe(ctx, broker, game)
+			So(err, ShouldBeNil)
+
+			select {
+			case <-done:
+				So("Should not be done at this point. Only 1 subscriber", ShouldBeNil)
+			case <-time.After(100 * time.Millisecond):
+				// this is what should happen.
+			}
+
+			// spectators don't count as subscribers.
+			_, _, err = watch(ctx, broker, game)
+			So(err, ShouldBeNil)
+			select {
+			case <-done:
+				So("Should not be done at this point. Only 1 subscriber", ShouldBeNil)
+			case <-time.After(100 * time.Millisecond):
+				// this is what should happen.
+			}
+
+			_, _, err = subscribe(ctx, broker, game)
+			So(err, ShouldBeNil)
+
+			select {
+			case <-done:
+				// this should work now
+			case <-time.After(500 * time.Millisecond):
+				So("We have two subscribers now, so done should close", ShouldBeNil)
+			}
+		})
+	}
 }
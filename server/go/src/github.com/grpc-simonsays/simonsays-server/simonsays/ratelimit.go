@@ -0,0 +1,264 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/auth"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// ErrRateLimited is returned when a player, or their address, joins or presses too often.
+var ErrRateLimited = errors.New("Too many requests. Slow down")
+
+// gameMethod is the full name of the Game stream.
+const gameMethod = "/simonsays.SimonSays/Game"
+
+// bucketSweepInterval is how often buckets that have filled back up are forgotten.
+const bucketSweepInterval = time.Minute
+
+// Limiter limits how often something can happen for each key, like a player
+// id or an address. Each key has a token bucket, that refills at a steady rate,
+// up to a burst, and each event takes a token.
+type Limiter interface {
+	// Allow takes a token from the key's bucket, and returns
+	// false if there wasn't one to take.
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+// bucket is a token bucket for a key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryLimiter is a Limiter kept in memory, so it only counts
+// what happens on this server.
+type memoryLimiter struct {
+	rate  float64
+	burst float64
+	// now is the current time. Replaced in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates a Limiter for this server, that allows rate events
+// a second for each key, with bursts of up to burst events.
+func NewMemoryLimiter(rate float64, burst int) Limiter {
+	return &memoryLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		now:       time.Now,
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the key's bucket, after topping it up for the time since the last one.
+func (l *memoryLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = l.fill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+	return true, nil
+}
+
+// fill returns how many tokens the bucket has by now.
+func (l *memoryLimiter) fill(b *bucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// sweep forgets the buckets that have filled back up, every bucketSweepInterval,
+// since they are the same as a new one.
+func (l *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for k, b := range l.buckets {
+		if l.fill(b, now) >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// bucketScript takes a token from the bucket (KEYS[1]) that refills at ARGV[1] tokens a second,
+// up to ARGV[2], at the time ARGV[3] in milliseconds. Returns 1 if there was a token to take.
+// The bucket expires once it would have filled back up.
+var bucketScript = redis.NewScript(1, `
+local key, rate, burst, now = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local b = redis.call("HMGET", key, "tokens", "time")
+local tokens, last = tonumber(b[1]), tonumber(b[2])
+
+if not tokens then
+	tokens, last = burst, now
+end
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", key, "tokens", tostring(tokens), "time", tostring(now))
+redis.call("PEXPIRE", key, math.ceil(burst / rate * 1000))
+return allowed
+`)
+
+// redisLimiter is a Limiter kept in Redis, so it is shared between servers.
+type redisLimiter struct {
+	pool  *redis.Pool
+	name  string
+	rate  float64
+	burst int
+}
+
+// NewRedisLimiter creates a Limiter shared between servers through the Redis server at the
+// address, that allows rate events a second for each key, with bursts of up to burst events.
+// Limiters with different names have their own buckets. The options are used to connect to Redis.
+func NewRedisLimiter(address, name string, rate float64, burst int, options ...redis.DialOption) Limiter {
+	if address == "" {
+		address = ":6379"
+	}
+	return &redisLimiter{pool: newPool(address, options...), name: name, rate: rate, burst: burst}
+}
+
+// bucketKey is the hash of the key's token bucket.
+func (l *redisLimiter) bucketKey(key string) string {
+	return fmt.Sprintf("RateLimit:%v:%v", l.name, key)
+}
+
+// Allow takes a token from the key's bucket in Redis.
+func (l *redisLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	con := l.pool.Get()
+	defer con.Close()
+
+	n, err := redis.Int(bucketScript.Do(con, l.bucketKey(key), l.rate, l.burst, millis(time.Now())))
+	return n == 1, err
+}
+
+// limitKeys are the keys a player is limited by: their id, and the address they connect from.
+func limitKeys(ctx context.Context, player string) []string {
+	keys := []string{"player:" + player}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		keys = append(keys, "address:"+addr)
+	}
+
+	return keys
+}
+
+// checkLimit takes a token for each of the player's keys, and returns ErrRateLimited if any of
+// them have run out. Lets everything through if the limiter is nil, or can't be reached.
+func checkLimit(ctx context.Context, limiter Limiter, player string) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for _, key := range limitKeys(ctx, player) {
+		ok, err := limiter.Allow(ctx, key)
+		if err != nil {
+			logger.Warn(ctx, "RateLimit", "Could not check the rate limit of %v. Letting it through. %v", key, err)
+			continue
+		}
+		if !ok {
+			logger.Info(ctx, "RateLimit", "%v is over the rate limit.", key)
+			return ErrRateLimited
+		}
+	}
+
+	return nil
+}
+
+// joinLimitStream is a Game stream that checks the JoinLimiter when the join comes in.
+type joinLimitStream struct {
+	grpc.ServerStream
+	limiter Limiter
+	joined  bool
+}
+
+// RecvMsg receives the message, and returns ErrRateLimited instead if it is
+// the join, and the player or their address has joined too often.
+func (s *joinLimitStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	req, ok := m.(*Request)
+	if s.joined || !ok || req.GetJoin() == nil {
+		return nil
+	}
+	s.joined = true
+
+	ctx := s.Context()
+	player := req.GetJoin().Id
+	if subject, ok := auth.FromContext(ctx); ok {
+		player = subject
+	}
+	// don't keep count of ids that would be turned away anyway.
+	if err := validatePlayerID(player); err != nil {
+		return err
+	}
+
+	return checkLimit(ctx, s.limiter, player)
+}
+
+// JoinInterceptor returns an interceptor that ends Game streams with RATE_LIMITED, if the
+// player, or the address they connect from, has joined too often for the JoinLimiter.
+// It should come after any interceptor that authenticates the player.
+func (s *SimonSays) JoinInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod != gameMethod || s.JoinLimiter == nil {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &joinLimitStream{ServerStream: ss, limiter: s.JoinLimiter})
+	}
+}
@@ -0,0 +1,270 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+)
+
+// TestMemoryLimiter tests limiting with token buckets kept in memory.
+func TestMemoryLimiter(t *testing.T) {
+	Convey("Given a limiter of one a second, in bursts of two", t, func() {
+		ctx := context.TODO()
+		now := time.Now()
+		l := NewMemoryLimiter(1, 2).(*memoryLimiter)
+		l.now = func() time.Time { return now }
+
+		Convey("A burst should be let through, then limited", func() {
+			So(mustAllow(l, "a"), ShouldBeTrue)
+			So(mustAllow(l, "a"), ShouldBeTrue)
+			So(mustAllow(l, "a"), ShouldBeFalse)
+
+			Convey("Other keys should have their own bucket", func() {
+				So(mustAllow(l, "b"), ShouldBeTrue)
+			})
+
+			Convey("The bucket should refill over time", func() {
+				now = now.Add(time.Second)
+				So(mustAllow(l, "a"), ShouldBeTrue)
+				So(mustAllow(l, "a"), ShouldBeFalse)
+			})
+
+			Convey("Buckets that have refilled should be forgotten", func() {
+				now = now.Add(bucketSweepInterval + time.Second)
+				_, err := l.Allow(ctx, "b")
+				So(err, ShouldBeNil)
+				So(l.buckets, ShouldContainKey, "b")
+				So(l.buckets, ShouldNotContainKey, "a")
+			})
+		})
+	})
+}
+
+// TestRedisLimiter tests limiting with token buckets shared through Redis.
+func TestRedisLimiter(t *testing.T) {
+	Convey("Given a Redis limiter of one a minute, in bursts of two", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		l := NewRedisLimiter("", "Test", 1.0/60, 2)
+
+		Convey("A burst should be let through, then limited", func() {
+			So(mustAllow(l, "a"), ShouldBeTrue)
+			So(mustAllow(l, "a"), ShouldBeTrue)
+			So(mustAllow(l, "a"), ShouldBeFalse)
+			So(mustAllow(l, "b"), ShouldBeTrue)
+
+			Convey("Limiters with other names should have their own buckets", func() {
+				So(mustAllow(NewRedisLimiter("", "Other", 1.0/60, 2), "a"), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// mustAllow returns if the limiter allows the key. Panics if there is an error.
+func mustAllow(l Limiter, key string) bool {
+	ok, err := l.Allow(context.TODO(), key)
+	if err != nil {
+		panic(err)
+	}
+	return ok
+}
+
+// TestCheckLimit tests limiting players by their id and address.
+func TestCheckLimit(t *testing.T) {
+	Convey("Given a limiter of one, from the same address", t, func() {
+		l := NewMemoryLimiter(1.0/60, 1)
+		ctx := peer.NewContext(context.TODO(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+
+		So(limitKeys(ctx, "Player One"), ShouldResemble, []string{"player:Player One", "address:10.0.0.1"})
+
+		Convey("A player should be limited", func() {
+			So(checkLimit(context.TODO(), l, "Player One"), ShouldBeNil)
+			So(checkLimit(context.TODO(), l, "Player One"), ShouldEqual, ErrRateLimited)
+		})
+
+		Convey("Another player from the same address should be limited", func() {
+			So(checkLimit(ctx, l, "Player One"), ShouldBeNil)
+			So(checkLimit(ctx, l, "Player Two"), ShouldEqual, ErrRateLimited)
+		})
+
+		Convey("Nothing should be limited without a limiter", func() {
+			So(checkLimit(ctx, nil, "Player One"), ShouldBeNil)
+			So(checkLimit(ctx, nil, "Player One"), ShouldBeNil)
+		})
+	})
+}
+
+// fakeServerStream is a stream that receives the requests it is given.
+type fakeServerStream struct {
+	grpc.ServerStream
+	reqs []*Request
+}
+
+func (f *fakeServerStream) Context() context.Context { return context.TODO() }
+
+func (f *fakeServerStream) RecvMsg(m interface{}) error {
+	req := f.reqs[0]
+	f.reqs = f.reqs[1:]
+	proto.Merge(m.(*Request), req)
+	return nil
+}
+
+// TestJoinInterceptor tests limiting joins.
+func TestJoinInterceptor(t *testing.T) {
+	Convey("Given a SimonSays that allows one join", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+		game.JoinLimiter = NewMemoryLimiter(1.0/60, 1)
+
+		intercept := game.JoinInterceptor()
+		info := &grpc.StreamServerInfo{FullMethod: gameMethod}
+		join := &Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One"}}}
+		press := &Request{Event: &Request_Press{Press: Color_RED}}
+
+		// receives everything the client sent, returning the first error.
+		recvAll := func(srv interface{}, ss grpc.ServerStream) error {
+			for i := 0; i < 2; i++ {
+				if err := ss.RecvMsg(new(Request)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		Convey("The first join, and the presses after it, should be let through", func() {
+			So(intercept(nil, &fakeServerStream{reqs: []*Request{join, press}}, info, recvAll), ShouldBeNil)
+
+			Convey("But the second join should be limited", func() {
+				err := intercept(nil, &fakeServerStream{reqs: []*Request{join, press}}, info, recvAll)
+				So(err, ShouldEqual, ErrRateLimited)
+			})
+		})
+	})
+}
+
+// TestPressLimit tests a player being disconnected for pressing too fast.
+func TestPressLimit(t *testing.T) {
+	Convey("Given a solo game that allows one press", t, func(c C) {
+		game := mustSimonSays()
+		defer game.Close()
+		game.pace = 10 * time.Millisecond
+		game.PressLimiter = NewMemoryLimiter(1.0/60, 1)
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		stream := newMockStream()
+		err = stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Mode: Request_SOLO}}})
+		So(err, ShouldBeNil)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- game.Game(stream)
+		}()
+
+		Convey("The second press should end the game", func() {
+			So(stream, shouldState, Response_BEGIN)
+			res, err := stream.PullSend()
+			So(err, ShouldBeNil)
+			So(stream, shouldState, Response_START_TURN)
+
+			mustPress(stream, res.GetLightup())
+			So(stream, shouldLightup, res.GetLightup())
+			So(stream, shouldState, Response_STOP_TURN)
+
+			// the next round's playback.
+			for i := 0; i < 2; i++ {
+				_, err := stream.PullSend()
+				So(err, ShouldBeNil)
+			}
+			So(stream, shouldState, Response_START_TURN)
+
+			mustPress(stream, Color_RED)
+			So(stream, shouldError, Error_RATE_LIMITED)
+			So(grpc.Code(<-done), ShouldEqual, codes.ResourceExhausted)
+		})
+	})
+}
+
+// TestPressLimitAgainstOthers tests a player that presses too fast losing
+// a game against someone else, rather than leaving them waiting.
+func TestPressLimitAgainstOthers(t *testing.T) {
+	Convey("Given a SimonSays that allows one press each", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+		game.PressLimiter = NewMemoryLimiter(1.0/60, 1)
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		playerOne := newMockStream()
+		playerTwo := newMockStream()
+		done := make(chan error, 2)
+
+		for i, p := range []*mockStream{playerOne, playerTwo} {
+			err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1)}}})
+			So(err, ShouldBeNil)
+
+			go func(p *mockStream) {
+				done <- game.Game(p)
+			}(p)
+
+			// hacky, but makes sure the players join in order.
+			time.Sleep(time.Second)
+		}
+
+		Convey("The player that presses again loses, and the other player wins", func() {
+			So(playerOne, shouldState, Response_BEGIN)
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(playerOne, shouldState, Response_START_TURN)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			mustPress(playerOne, Color_RED)
+			So(playerOne, shouldError, Error_RATE_LIMITED)
+			So(playerTwo, shouldState, Response_WIN)
+
+			ended := []codes.Code{grpc.Code(<-done), grpc.Code(<-done)}
+			So(ended, ShouldContain, codes.ResourceExhausted)
+			So(ended, ShouldContain, codes.OK)
+		})
+	})
+}
@@ -0,0 +1,119 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// ratingsKey is the hash of player ids to their Elo rating.
+const ratingsKey = "Ratings"
+
+const (
+	// initialRating is the rating of a player that has yet to finish a game.
+	initialRating = 1500
+	// kFactor is the most a rating can change by, against a single opponent.
+	kFactor = 32
+)
+
+const (
+	// ratingWindow is how far apart the ratings of players
+	// can be when they are first matched.
+	ratingWindow = 100
+	// ratingWindowGrowth is how much wider the rating window
+	// gets for each second a player waits.
+	ratingWindowGrowth = 50
+)
+
+// rateScript updates the ratings of the players at the end of a game, where the
+// loser (ARGV[1]) has lost to each of the winners (ARGV[4] on), using the Elo
+// rating system. Ratings are rounded to whole numbers.
+var rateScript = redis.NewScript(1, `
+local key, loser, k, initial = KEYS[1], ARGV[1], tonumber(ARGV[2]), tonumber(ARGV[3])
+local lost = tonumber(redis.call("HGET", key, loser) or initial)
+local change = 0
+
+for i = 4, #ARGV do
+	local winner = ARGV[i]
+	local won = tonumber(redis.call("HGET", key, winner) or initial)
+	local expected = 1 / (1 + 10 ^ ((won - lost) / 400))
+	local delta = math.floor(k * expected + 0.5)
+	redis.call("HSET", key, winner, won + delta)
+	change = change + delta
+end
+
+redis.call("HSET", key, loser, lost - change)
+return lost - change
+`)
+
+// window is how far apart ratings can be for players to be matched,
+// after waiting for the given time.
+func window(waited time.Duration) int {
+	return ratingWindow + int(ratingWindowGrowth*waited/time.Second)
+}
+
+// getRating returns the rating of the player.
+func getRating(ctx context.Context, con redis.Conn, id string) (int, error) {
+	r, err := redis.Int(con.Do("HGET", ratingsKey, id))
+	if err == redis.ErrNil {
+		return initialRating, nil
+	}
+	if err != nil {
+		logger.Error(ctx, "GetRating", "Error getting rating of %v: %v", id, err)
+	}
+	return r, err
+}
+
+// rateGame updates the ratings of the loser, and the winners that beat them.
+func rateGame(ctx context.Context, con redis.Conn, loser string, winners []string) error {
+	lc := "RateGame"
+
+	args := []interface{}{ratingsKey, loser, kFactor, initialRating}
+	for _, w := range winners {
+		args = append(args, w)
+	}
+
+	r, err := redis.Int(rateScript.Do(con, args...))
+	if err != nil {
+		logger.Error(ctx, lc, "Error rating game: %v", err)
+		return err
+	}
+
+	logger.Info(ctx, lc, "%v lost to %v. Rating is now %v", loser, winners, r)
+	return nil
+}
+
+// GetRating function is an implementation of the gRPC GetRating Service.
+// Returns the Elo rating of the player.
+func (s *SimonSays) GetRating(ctx context.Context, req *RatingRequest) (*Rating, error) {
+	if err := validatePlayerID(req.Player); err != nil {
+		return nil, statusError(err)
+	}
+
+	con := s.pool.Get()
+	defer con.Close()
+
+	r, err := getRating(ctx, con, req.Player)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &Rating{Player: req.Player, Rating: int32(r)}, nil
+}
@@ -0,0 +1,106 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestWindow tests that the rating window widens over time.
+func TestWindow(t *testing.T) {
+	Convey("The rating window should start small, and get wider", t, func() {
+		So(window(0), ShouldEqual, ratingWindow)
+		So(window(2*time.Second), ShouldEqual, ratingWindow+2*ratingWindowGrowth)
+	})
+}
+
+// TestRateGame tests updating ratings at the end of a game.
+func TestRateGame(t *testing.T) {
+	Convey("When we have a simon says", t, func() {
+		server := mustSimonSays()
+		defer server.Close()
+		con := server.pool.Get()
+		defer con.Close()
+
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+
+		Convey("A new player should have the initial rating", func() {
+			r, err := server.GetRating(ctx, &RatingRequest{Player: "Player One"})
+			So(err, ShouldBeNil)
+			So(r.Player, ShouldEqual, "Player One")
+			So(r.Rating, ShouldEqual, initialRating)
+		})
+
+		Convey("A rating can't be got without Redis", func() {
+			pool := server.pool
+			server.pool = newPool("localhost:1")
+			defer func() {
+				server.pool.Close()
+				server.pool = pool
+			}()
+
+			_, err := server.GetRating(ctx, &RatingRequest{Player: "Player One"})
+			So(grpc.Code(err), ShouldEqual, codes.Unavailable)
+		})
+
+		Convey("When players of the same rating play", func() {
+			err := rateGame(ctx, con, "Player One", []string{"Player Two"})
+			So(err, ShouldBeNil)
+
+			Convey("The loser should go down, and the winner up, by half the k factor", func() {
+				r, err := getRating(ctx, con, "Player One")
+				So(err, ShouldBeNil)
+				So(r, ShouldEqual, initialRating-kFactor/2)
+
+				r, err = getRating(ctx, con, "Player Two")
+				So(err, ShouldBeNil)
+				So(r, ShouldEqual, initialRating+kFactor/2)
+			})
+
+			Convey("And the winner beats the loser again, they should win less", func() {
+				err := rateGame(ctx, con, "Player One", []string{"Player Two"})
+				So(err, ShouldBeNil)
+
+				r, err := getRating(ctx, con, "Player Two")
+				So(err, ShouldBeNil)
+				So(r, ShouldBeGreaterThan, initialRating+kFactor/2)
+				So(r, ShouldBeLessThan, initialRating+kFactor)
+			})
+		})
+
+		Convey("When a player loses to more than one player", func() {
+			err := rateGame(ctx, con, "Player One", []string{"Player Two", "Player Three"})
+			So(err, ShouldBeNil)
+
+			r, err := getRating(ctx, con, "Player One")
+			So(err, ShouldBeNil)
+			So(r, ShouldEqual, initialRating-kFactor)
+
+			r, err = getRating(ctx, con, "Player Three")
+			So(err, ShouldBeNil)
+			So(r, ShouldEqual, initialRating+kFactor/2)
+		})
+	})
+}
@@ -0,0 +1,224 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// redisBroker is a Broker that uses Redis pub/sub, so players can be
+// spread across many servers.
+type redisBroker struct {
+	pool *redis.Pool
+}
+
+// redisSubscription is a subscription to a Redis topic, with its own connection.
+// The connection is read by the receive goroutine, and written to by Unsubscribe,
+// which redigo allows at the same time, but it must not be written to once
+// the goroutine has closed it.
+type redisSubscription struct {
+	psc redis.PubSubConn
+	c   chan []byte
+	// unsubscribe from the topic, or pattern.
+	unsubscribe func() error
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRedisBroker creates a Broker that uses the Redis server at the given address,
+// connecting with the given options.
+func NewRedisBroker(address string, options ...redis.DialOption) Broker {
+	if address == "" {
+		address = ":6379"
+	}
+
+	return &redisBroker{pool: newPool(address, options...)}
+}
+
+// Publish publishes the data to the topic.
+func (b *redisBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	con := b.pool.Get()
+	defer con.Close()
+
+	_, err := con.Do("PUBLISH", topic, data)
+	return err
+}
+
+// Subscribe subscribes to the topic, on a new connection.
+func (b *redisBroker) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	lc := "Subscribe"
+	psc := redis.PubSubConn{Conn: b.pool.Get()}
+
+	logger.Info(ctx, lc, "Subscribing to topic '%v'", topic)
+
+	if err := psc.Subscribe(topic); err != nil {
+		logger.Info(ctx, lc, "Error Subscribing. %v", err)
+		psc.Close()
+		return nil, err
+	}
+
+	return b.receive(ctx, psc, "subscribe", topic, func() error { return psc.Unsubscribe(topic) })
+}
+
+// watchPattern is the pattern watchers subscribe to the topic with.
+// Pattern subscriptions are not counted by PUBSUB NUMSUB, so watchers
+// aren't counted as subscribers.
+func watchPattern(topic string) string {
+	return globEscaper.Replace(topic)
+}
+
+// globEscaper escapes the special characters in a Redis glob pattern.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Watch subscribes to the topic with a pattern, on a new connection.
+func (b *redisBroker) Watch(ctx context.Context, topic string) (Subscription, error) {
+	lc := "Watch"
+	psc := redis.PubSubConn{Conn: b.pool.Get()}
+	pattern := watchPattern(topic)
+
+	logger.Info(ctx, lc, "Watching topic '%v'", topic)
+
+	if err := psc.PSubscribe(pattern); err != nil {
+		logger.Info(ctx, lc, "Error Subscribing. %v", err)
+		psc.Close()
+		return nil, err
+	}
+
+	return b.receive(ctx, psc, "psubscribe", topic, func() error { return psc.PUnsubscribe(pattern) })
+}
+
+// receive waits until the subscription has been confirmed, so nothing
+// published after we return is missed, then starts receiving messages.
+func (b *redisBroker) receive(ctx context.Context, psc redis.PubSubConn, kind, topic string, unsubscribe func() error) (Subscription, error) {
+	if v, ok := psc.Receive().(redis.Subscription); !ok || v.Kind != kind {
+		err := fmt.Errorf("Subscription to topic '%v' was not confirmed. %#v", topic, v)
+		logger.Error(ctx, "ConfirmSubscription", err.Error())
+		psc.Close()
+		return nil, err
+	}
+
+	s := &redisSubscription{psc: psc, c: make(chan []byte), unsubscribe: unsubscribe}
+	go s.receive(ctx)
+
+	return s, nil
+}
+
+// receive receives messages from the subscription, and sends them down the channel,
+// until we have unsubscribed, or the context is done. Closes the channel, and the
+// subscription's connection when finished.
+func (s *redisSubscription) receive(ctx context.Context) {
+	lc := "Receive"
+	defer s.close()
+	defer close(s.c)
+
+	for {
+		var data []byte
+
+		switch v := s.psc.Receive().(type) {
+		case redis.Message:
+			data = v.Data
+		case redis.PMessage:
+			data = v.Data
+		case redis.Subscription:
+			// once we have unsubscribed from everything, we are done.
+			if v.Count == 0 {
+				logger.Info(ctx, lc, "Unsubscribed. Closing Subscribe Pipeline.")
+				return
+			}
+			continue
+		case error:
+			logger.Error(ctx, lc, "Error processing messages. Closing channel. %v", v)
+			return
+		default:
+			logger.Warn(ctx, lc, "Received unknown message. Ignored: %#v", v)
+			continue
+		}
+
+		select {
+		case s.c <- data:
+		case <-ctx.Done():
+			logger.Info(ctx, lc, "Context is done. Closing Subscribe Pipeline.")
+			return
+		}
+	}
+}
+
+// Messages returns the channel of messages.
+func (s *redisSubscription) Messages() <-chan []byte {
+	return s.c
+}
+
+// Unsubscribe unsubscribes from the topic. Does nothing if
+// the subscription has already finished.
+func (s *redisSubscription) Unsubscribe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	return s.unsubscribe()
+}
+
+// close closes the subscription's connection, once it has finished.
+func (s *redisSubscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.psc.Close()
+}
+
+// Subscribers returns the number of subscribers to the topic, from PUBSUB NUMSUB.
+func (b *redisBroker) Subscribers(ctx context.Context, topic string) (int, error) {
+	lc := "Subscribers"
+	con := b.pool.Get()
+	defer con.Close()
+
+	vals, err := redis.Values(con.Do("PUBSUB", "NUMSUB", topic))
+	if err != nil {
+		logger.Error(ctx, lc, "Error getting number of subscriptions: %v", err)
+		return 0, err
+	}
+
+	if l := len(vals); l != 2 {
+		err := fmt.Errorf("Should only be two items in the result. Weird. %#v. %v.", vals, l)
+		logger.Error(ctx, lc, err.Error())
+		return 0, err
+	}
+
+	count, ok := vals[1].(int64)
+	if !ok {
+		val := vals[1]
+		err := fmt.Errorf("Second value should be an integer. %T %#v", val, val)
+		logger.Error(ctx, lc, err.Error())
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+// Close closes the connection pool.
+func (b *redisBroker) Close() error {
+	return b.pool.Close()
+}
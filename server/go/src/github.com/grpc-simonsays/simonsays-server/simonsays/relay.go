@@ -0,0 +1,371 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"io"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	uuid "github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// relayTimeout is how long to wait for the server running a game
+// to pick up a stream that was resumed on another server.
+const relayTimeout = 5 * time.Second
+
+// sessionExpiry is how long a session is kept in Redis, in case the
+// server running it goes away without removing it.
+const sessionExpiry = 24 * time.Hour
+
+// sessionKey is the hash of the player, game and server of the session
+// with the token, so it can be resumed on any server.
+func sessionKey(token string) string {
+	return "Session:" + token
+}
+
+// resumeTopic is the topic the server with the id is asked to
+// relay one of its sessions to another server on.
+func resumeTopic(server string) string {
+	return "Resume:" + server
+}
+
+// relayInTopic is the topic the requests of the relay with the id are sent on.
+func relayInTopic(id string) string {
+	return "RelayIn:" + id
+}
+
+// relayOutTopic is the topic the responses of the relay with the id are sent on.
+func relayOutTopic(id string) string {
+	return "RelayOut:" + id
+}
+
+// relayedError is the error a game ended with, on the server it was running on.
+type relayedError struct {
+	reason  Error_Reason
+	message string
+}
+
+// Error returns the message of the error.
+func (e relayedError) Error() string {
+	return e.message
+}
+
+// relayError is the error to send over a relay, if there was one.
+func relayError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Reason: errorReason(err), Message: err.Error()}
+}
+
+// saveSession stores the session in Redis, so it can be resumed on any server.
+func (s *SimonSays) saveSession(ctx context.Context, con redis.Conn, sess *session, game *Game) error {
+	key := sessionKey(sess.token)
+	if _, err := con.Do("HMSET", key, "player", sess.player, "game", game.ID, "server", s.id); err != nil {
+		logger.Error(ctx, "SaveSession", "Error saving the session. %v", err)
+		return err
+	}
+	_, err := con.Do("PEXPIRE", key, int64(sessionExpiry/time.Millisecond))
+	return err
+}
+
+// deleteSession removes the session from Redis, once it can no longer be resumed.
+func (s *SimonSays) deleteSession(ctx context.Context, sess *session) {
+	con := s.pool.Get()
+	defer con.Close()
+
+	if _, err := con.Do("DEL", sessionKey(sess.token)); err != nil {
+		logger.Error(ctx, "DeleteSession", "Error deleting the session. %v", err)
+	}
+}
+
+// relayResumes listens for other servers asking to resume the sessions on this one.
+func (s *SimonSays) relayResumes() error {
+	ctx := logger.NewContext(context.Background())
+	logger.Set(ctx, "Server", s.id)
+
+	sub, err := s.direct.Subscribe(ctx, resumeTopic(s.id))
+	if err != nil {
+		return err
+	}
+	s.resumes = sub
+
+	go func() {
+		for data := range sub.Messages() {
+			r := new(Relay)
+			if err := proto.Unmarshal(data, r); err != nil {
+				logger.Error(ctx, "RelayResumes", "Could not decode the request to resume. %v", err)
+				continue
+			}
+			s.attachRelay(ctx, r)
+		}
+	}()
+
+	return nil
+}
+
+// attachRelay hands the relay over to the session it is for, as its new stream,
+// or lets the other server know there is no session to resume.
+func (s *SimonSays) attachRelay(ctx context.Context, r *Relay) {
+	lc := "AttachRelay"
+
+	sess, err := s.findSession(r.Token, &Request_Player{Id: r.Player})
+	if err != nil {
+		logger.Info(ctx, lc, "Could not attach relay %v. %v", r.Attach, err)
+		if err := publishRelay(ctx, s.direct, relayOutTopic(r.Attach), &Relay{Done: true, Error: relayError(err)}); err != nil {
+			logger.Error(ctx, lc, "Could not let the other server know. %v", err)
+		}
+		return
+	}
+
+	rs, err := newRelayStream(sess, s.direct, r.Attach)
+	if err != nil {
+		logger.Error(ctx, lc, "Could not relay the session. %v", err)
+		return
+	}
+
+	go func() {
+		select {
+		case sess.attaching <- rs:
+		case <-sess.done:
+		}
+	}()
+}
+
+// resumeRelay resumes the player's session on the server that is running it, when it isn't
+// this one, relaying the stream to it. Blocks until the game is over, returning what it
+// ended with, or the stream is done.
+func (s *SimonSays) resumeRelay(stream SimonSays_GameServer, player *Request_Player) error {
+	lc := "ResumeRelay"
+	ctx := stream.Context()
+
+	// the other servers can't be reached, so neither can their sessions.
+	if s.scope != "" {
+		logger.Info(ctx, lc, "No session to resume with that token on this server.")
+		return ErrSessionNotFound
+	}
+
+	con := s.pool.Get()
+	v, err := redis.StringMap(con.Do("HGETALL", sessionKey(player.Resume)))
+	con.Close()
+	if err != nil {
+		logger.Error(ctx, lc, "Error finding the session. %v", err)
+		return err
+	}
+	if v["player"] != player.Id || v["server"] == "" || v["server"] == s.id {
+		logger.Error(ctx, lc, "No session to resume with that token.")
+		return ErrSessionNotFound
+	}
+
+	u, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	id := u.String()
+
+	out, err := s.direct.Subscribe(ctx, relayOutTopic(id))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Unsubscribe(); err != nil {
+			logger.Error(ctx, lc, "Error unsubscribing from the relay. %v", err)
+		}
+	}()
+
+	logger.Info(ctx, lc, "Resuming game %v on server %v.", v["game"], v["server"])
+	err = publishRelay(ctx, s.direct, resumeTopic(v["server"]), &Relay{Token: player.Resume, Player: player.Id, Attach: id})
+	if err != nil {
+		return err
+	}
+
+	timeout := time.After(relayTimeout)
+	for {
+		select {
+		case data, ok := <-out.Messages():
+			if !ok {
+				return errGameGone
+			}
+
+			r := new(Relay)
+			if err := proto.Unmarshal(data, r); err != nil {
+				logger.Error(ctx, lc, "Could not decode the relayed response. %v", err)
+				return err
+			}
+
+			if r.Done {
+				if r.Error != nil {
+					return relayedError{reason: r.Error.Reason, message: r.Error.Message}
+				}
+				return nil
+			}
+
+			// the other server has picked up the relay, so it is listening for requests.
+			if timeout != nil {
+				timeout = nil
+				go relayRequests(stream, s.direct, id)
+			}
+
+			if err := stream.Send(r.Response); err != nil {
+				return err
+			}
+
+		case <-timeout:
+			logger.Error(ctx, lc, "Server %v did not pick up the relay in time.", v["server"])
+			return ErrSessionNotFound
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// relayRequests sends the requests on the stream over the relay with the id,
+// until the stream is done, then lets the other server know it has dropped.
+func relayRequests(stream SimonSays_GameServer, broker Broker, id string) {
+	lc := "RelayRequests"
+	// the other server still needs to hear about it, once the stream is done.
+	ctx := streamValues{stream.Context()}
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			logger.Info(ctx, lc, "Relayed stream is done. %v", err)
+			if err := publishRelay(ctx, broker, relayInTopic(id), &Relay{Done: true}); err != nil {
+				logger.Error(ctx, lc, "Could not let the other server know. %v", err)
+			}
+			return
+		}
+
+		if err := publishRelay(ctx, broker, relayInTopic(id), &Relay{Request: req}); err != nil {
+			logger.Error(ctx, lc, "Could not relay the request. %v", err)
+		}
+	}
+}
+
+// publishRelay publishes the relay message to the topic.
+func publishRelay(ctx context.Context, broker Broker, topic string, r *Relay) error {
+	data, err := proto.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return broker.Publish(ctx, topic, data)
+}
+
+// relayStream is a stream on another server, that a session has been resumed on.
+// Responses are sent to it, and requests received from it, over the relay.
+type relayStream struct {
+	broker Broker
+	id     string
+	in     Subscription
+	ctx    context.Context
+}
+
+// newRelayStream listens for the requests of the relay with the id, for the session.
+// Once the session is over, the other server is told what it ended with.
+func newRelayStream(sess *session, broker Broker, id string) (*relayStream, error) {
+	ctx, cancel := context.WithCancel(sess.Context())
+
+	in, err := broker.Subscribe(ctx, relayInTopic(id))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	rs := &relayStream{broker: broker, id: id, in: in, ctx: ctx}
+
+	go func() {
+		defer cancel()
+
+		select {
+		case <-sess.done:
+			err := publishRelay(ctx, broker, relayOutTopic(id), &Relay{Done: true, Error: relayError(sess.err)})
+			if err != nil {
+				logger.Error(ctx, "RelayStream", "Could not let the other server know the game is over. %v", err)
+			}
+		case <-ctx.Done():
+		}
+
+		if err := in.Unsubscribe(); err != nil {
+			logger.Error(ctx, "RelayStream", "Error unsubscribing from the relay. %v", err)
+		}
+	}()
+
+	return rs, nil
+}
+
+// Send sends the response over the relay.
+func (rs *relayStream) Send(res *Response) error {
+	return publishRelay(rs.ctx, rs.broker, relayOutTopic(rs.id), &Relay{Response: res})
+}
+
+// Recv receives the next request over the relay. Returns io.EOF
+// once the stream on the other server is done.
+func (rs *relayStream) Recv() (*Request, error) {
+	for data := range rs.in.Messages() {
+		r := new(Relay)
+		if err := proto.Unmarshal(data, r); err != nil {
+			logger.Error(rs.ctx, "RelayStream", "Could not decode the relayed request. %v", err)
+			continue
+		}
+
+		if r.Done {
+			return nil, io.EOF
+		}
+		return r.Request, nil
+	}
+
+	return nil, io.EOF
+}
+
+// Context returns the context of the relay, which is done with the session.
+func (rs *relayStream) Context() context.Context {
+	return rs.ctx
+}
+
+// SetHeader does nothing, as the header is the other server's to send.
+func (rs *relayStream) SetHeader(md metadata.MD) error { return nil }
+
+// SendHeader does nothing, as the header is the other server's to send.
+func (rs *relayStream) SendHeader(md metadata.MD) error { return nil }
+
+// SetTrailer does nothing, as the trailer is the other server's to send.
+func (rs *relayStream) SetTrailer(md metadata.MD) {}
+
+// SendMsg sends the message over the relay, if it is a response.
+func (rs *relayStream) SendMsg(m interface{}) error {
+	if res, ok := m.(*Response); ok {
+		return rs.Send(res)
+	}
+	return nil
+}
+
+// RecvMsg receives the next request over the relay into the message.
+func (rs *relayStream) RecvMsg(m interface{}) error {
+	req, err := rs.Recv()
+	if err != nil {
+		return err
+	}
+	if r, ok := m.(*Request); ok {
+		*r = *req
+	}
+	return nil
+}
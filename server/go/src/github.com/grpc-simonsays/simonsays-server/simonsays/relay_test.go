@@ -0,0 +1,115 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestResumeOnAnotherServer tests that a player can pick up a game on a
+// server other than the one running it, which relays the stream to it.
+func TestResumeOnAnotherServer(t *testing.T) {
+	Convey("Given a game between two players, and another server", t, func(c C) {
+		playerOne := newMockStream()
+		playerTwo := newMockStream()
+		resumed := newMockStream()
+		game := mustSimonSays()
+		defer game.Close()
+		other := mustSimonSays()
+		defer other.Close()
+		wg := sync.WaitGroup{}
+
+		tokens := startResumableGame(c, game, &wg, playerOne, playerTwo)
+
+		mustPress(playerOne, Color_GREEN)
+		So(playerOne, shouldLightup, Color_GREEN)
+		So(playerTwo, shouldLightup, Color_GREEN)
+		So(playerOne, shouldState, Response_STOP_TURN)
+		So(playerTwo, shouldState, Response_START_TURN)
+
+		playerTwo.Disconnect()
+		So(playerOne, shouldPlayerState, Response_RECONNECTING, "Player Two")
+
+		Convey("Player two can resume the game on the other server", func() {
+			err := resumed.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Two", Resume: tokens[1]}}})
+			So(err, ShouldBeNil)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.So(other.Game(resumed), ShouldBeNil)
+			}()
+
+			res, err := resumed.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+			So(res.Resume, ShouldEqual, tokens[1])
+
+			res, err = resumed.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetLightup(), ShouldEqual, Color_GREEN)
+			So(res.Playback, ShouldBeTrue)
+			So(resumed, shouldState, Response_START_TURN)
+
+			So(playerOne, shouldPlayerState, Response_RECONNECTED, "Player Two")
+
+			mustPress(resumed, Color_RED)
+			So(playerOne, shouldLightup, Color_RED)
+			So(resumed, shouldLightup, Color_RED)
+			So(playerOne, shouldState, Response_WIN)
+			So(resumed, shouldState, Response_LOSE)
+
+			wg.Wait()
+		})
+
+		Convey("Somebody else can't resume player two's game with their token", func() {
+			err := resumed.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Three", Resume: tokens[1]}}})
+			So(err, ShouldBeNil)
+
+			So(grpc.Code(other.Game(resumed)), ShouldEqual, codes.NotFound)
+			So(resumed, shouldError, Error_NOT_FOUND)
+
+			// player two can still resume it, and lose.
+			stream := newMockStream()
+			err = stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Two", Resume: tokens[1]}}})
+			So(err, ShouldBeNil)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.So(other.Game(stream), ShouldBeNil)
+			}()
+
+			So(stream, shouldState, Response_BEGIN)
+			So(stream, shouldLightup, Color_GREEN)
+			So(stream, shouldState, Response_START_TURN)
+			So(playerOne, shouldPlayerState, Response_RECONNECTED, "Player Two")
+
+			mustPress(stream, Color_RED)
+			So(playerOne, shouldLightup, Color_RED)
+			So(stream, shouldLightup, Color_RED)
+			So(playerOne, shouldState, Response_WIN)
+			So(stream, shouldState, Response_LOSE)
+
+			wg.Wait()
+		})
+	})
+}
@@ -0,0 +1,106 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"io"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// Replay function is an implementation of the gRPC Replay Service.
+// Streams the events of a recorded game the way a spectator would have
+// seen them, at the pace they happened, sped up by the requested speed.
+// If it ends early, an ERROR is sent, and it ends with the gRPC status for the reason.
+func (s *SimonSays) Replay(req *ReplayRequest, stream SimonSays_ReplayServer) error {
+	defer s.track()()
+
+	rs := &replayStream{responseStream: stream, ctx: logger.NewContext(stream.Context())}
+
+	return endStream(rs, s.replay(req, rs))
+}
+
+// replay streams the events of the recorded game to the stream.
+func (s *SimonSays) replay(req *ReplayRequest, stream responseStream) error {
+	ctx := stream.Context()
+
+	lc := "Replay"
+	if req.Game == "" {
+		logger.Error(ctx, lc, "Game was missing from the replay request. %v", req)
+		return invalidRequestError("The game to replay is missing.")
+	}
+
+	game := NewGame(req.Game)
+	logger.Set(ctx, "Game", game.ID)
+
+	speed := float64(req.Speed)
+	if speed <= 0 {
+		speed = 1
+	}
+	logger.Info(ctx, lc, "Replaying game at %vx.", speed)
+
+	events, err := s.history.Events(ctx, game.ID)
+	if err != nil {
+		return err
+	}
+
+	sp := &spectator{game: game, stream: stream}
+
+	for i, e := range events {
+		// wait as long as there was between this event and the one before it.
+		if i > 0 {
+			wait := time.Duration(float64(e.Time-events[i-1].Time) * float64(time.Millisecond) / speed)
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.stopping:
+				return ErrShuttingDown
+			}
+		}
+
+		msg := new(message)
+		msg.fromEvent(e)
+
+		err := sp.handle(msg)
+		if err == io.EOF {
+			logger.Info(ctx, lc, "[Replay] EOF. Closing connection.")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// the game never finished, like when the server it was on went away.
+	logger.Info(ctx, lc, "Replayed every event, but the game did not finish.")
+	return nil
+}
+
+// replayStream is a Replay stream with a context
+// that the game being replayed is logged with.
+type replayStream struct {
+	responseStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (r *replayStream) Context() context.Context {
+	return r.ctx
+}
@@ -0,0 +1,118 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestReplay replays a recorded game.
+func TestReplay(t *testing.T) {
+	Convey("Given a recorded game", t, func(c C) {
+		game := mustSimonSays()
+		defer game.Close()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+		events := []*Event{
+			{Type: Event_BEGIN, Player: "Player Two", Players: []string{"Player One", "Player Two"}, Time: 1000},
+			{Type: Event_STOP_TURN, Player: "Player Two", Time: 1000},
+			{Type: Event_LIGHTUP, Colors: []Color{Color_RED}, Time: 1400},
+			{Type: Event_STOP_TURN, Player: "Player One", Colors: []Color{Color_RED}, Time: 1400},
+			{Type: Event_LIGHTUP, Colors: []Color{Color_BLUE}, Time: 1800},
+			{Type: Event_LOST, Player: "Player Two", Colors: []Color{Color_RED}, Reason: Elimination_WRONG_COLOR, Time: 1800},
+		}
+		for _, e := range events {
+			So(game.history.Record(ctx, "Game", e), ShouldBeNil)
+		}
+
+		Convey("It is replayed with the events a spectator would see, sped up", func() {
+			stream := newMockStream()
+			done := make(chan struct{})
+			start := time.Now()
+
+			go func() {
+				defer close(done)
+				c.So(game.Replay(&ReplayRequest{Game: "Game", Speed: 2}, stream), ShouldBeNil)
+			}()
+
+			res, err := stream.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+			So(res.Game, ShouldEqual, "Game")
+
+			So(stream, shouldPlayerState, Response_STOP_TURN, "Player Two")
+			So(stream, shouldPlayerState, Response_START_TURN, "Player One")
+
+			res, err = stream.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetLightup(), ShouldEqual, Color_RED)
+			So(res.Player, ShouldEqual, "Player One")
+
+			So(stream, shouldPlayerState, Response_STOP_TURN, "Player One")
+			So(stream, shouldPlayerState, Response_START_TURN, "Player Two")
+
+			res, err = stream.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetLightup(), ShouldEqual, Color_BLUE)
+			So(res.Player, ShouldEqual, "Player Two")
+
+			So(stream, shouldPlayerState, Response_LOSE, "Player Two")
+			So(stream, shouldPlayerState, Response_WIN, "Player One")
+
+			<-done
+			// 800ms of game, at twice the speed.
+			So(time.Since(start), ShouldBeBetween, 350*time.Millisecond, 800*time.Millisecond)
+		})
+
+		Convey("A SOLO game is over once its player has lost", func() {
+			solo := []*Event{
+				{Type: Event_BEGIN, Player: "Player One", Players: []string{"Player One"}, Time: 1000},
+				{Type: Event_STOP_TURN, Player: "Player One", Time: 1000},
+				{Type: Event_LOST, Player: "Player One", Reason: Elimination_WRONG_COLOR, Time: 1000},
+			}
+			for _, e := range solo {
+				So(game.history.Record(ctx, "Solo Game", e), ShouldBeNil)
+			}
+
+			stream := newMockStream()
+			So(game.Replay(&ReplayRequest{Game: "Solo Game"}, stream), ShouldBeNil)
+
+			So(stream, shouldState, Response_BEGIN)
+			So(stream, shouldPlayerState, Response_STOP_TURN, "Player One")
+			So(stream, shouldPlayerState, Response_START_TURN, "Player One")
+			So(stream, shouldPlayerState, Response_LOSE, "Player One")
+			So(stream.sendChan, ShouldBeEmpty)
+		})
+
+		Convey("A game that wasn't recorded can't be replayed", func() {
+			stream := newMockStream()
+			err := game.Replay(&ReplayRequest{Game: "Not A Game"}, stream)
+			So(grpc.Code(err), ShouldEqual, codes.NotFound)
+			So(stream, shouldError, Error_NOT_FOUND)
+		})
+	})
+}
@@ -0,0 +1,163 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	uuid "github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+)
+
+// defaultRoomExpiry is how long a private room waits for players by default.
+const defaultRoomExpiry = 5 * time.Minute
+
+// roomCodeLength is how many characters are in a room code.
+const roomCodeLength = 6
+
+// roomCodeAlphabet leaves out characters that are easily confused
+// with one another, such as 0 and O, or 1 and I.
+const roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// ErrRoomNotFound is returned when there is no open room with the given code.
+var ErrRoomNotFound = errors.New("Room not found")
+
+// ErrRoomFull is returned when a room already has all the players it needs.
+var ErrRoomFull = errors.New("Room is full")
+
+// ErrRoomExpired is returned when nobody joined a room in time.
+var ErrRoomExpired = errors.New("Room has expired")
+
+// roomKey is the hash holding the game a room code is for. Rooms that
+// can only be found on one server (the scope) are kept apart.
+func roomKey(scope, code string) string {
+	if scope != "" {
+		return "Room:" + scope + ":" + code
+	}
+	return "Room:" + code
+}
+
+// createRoomScript creates the room (KEYS[1]) for the game (ARGV[1]) of the given size (ARGV[2]),
+// expiring after ARGV[3] milliseconds, all at once so nobody finds it half made.
+// Returns 0 if the code is already in use.
+var createRoomScript = redis.NewScript(1, `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "game", ARGV[1], "size", ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+// newRoomCode generates a random room code.
+func newRoomCode() (string, error) {
+	b := make([]byte, roomCodeLength)
+	max := big.NewInt(int64(len(roomCodeAlphabet)))
+
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = roomCodeAlphabet[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// createRoom creates a new game of the given size, in a private room of the given scope
+// that expires if it hasn't filled up in time.
+func createRoom(ctx context.Context, con redis.Conn, scope string, size int, expiry time.Duration) (*Game, error) {
+	lc := "CreateRoom"
+
+	u, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	g := NewGame(u.String())
+	g.Size = size
+	g.Scope = scope
+
+	// keep trying until we find a code that isn't in use.
+	for {
+		code, err := newRoomCode()
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := redis.Bool(createRoomScript.Do(con, roomKey(scope, code), g.ID, size, int64(expiry/time.Millisecond)))
+		if err != nil {
+			logger.Error(ctx, lc, "Error creating room: %v", err)
+			return nil, err
+		}
+
+		if ok {
+			g.Room = code
+			break
+		}
+
+		logger.Info(ctx, lc, "Room code %v is in use, trying another...", code)
+	}
+
+	logger.Info(ctx, lc, "Created room %v for game %v", g.Room, g.ID)
+	return g, nil
+}
+
+// findRoom finds the game for the private room of the given scope, with the given code.
+// Codes are not case sensitive.
+func findRoom(ctx context.Context, con redis.Conn, scope, code string) (*Game, error) {
+	lc := "FindRoom"
+	code = strings.ToUpper(code)
+
+	v, err := redis.Values(con.Do("HMGET", roomKey(scope, code), "game", "size"))
+	if err != nil {
+		logger.Error(ctx, lc, "Error finding room: %v", err)
+		return nil, err
+	}
+
+	var gameID string
+	var size int
+	if _, err := redis.Scan(v, &gameID, &size); err != nil {
+		logger.Error(ctx, lc, "Error reading room: %v", err)
+		return nil, err
+	}
+
+	if gameID == "" {
+		logger.Info(ctx, lc, "Could not find room %v", code)
+		return nil, ErrRoomNotFound
+	}
+
+	g := NewGame(gameID)
+	g.Size = size
+	g.Room = code
+	g.Scope = scope
+
+	return g, nil
+}
+
+// closeRoom removes the room, so nobody else can join it.
+func closeRoom(ctx context.Context, con redis.Conn, g *Game) error {
+	logger.Info(ctx, "CloseRoom", "Closing room %v", g.Room)
+	_, err := con.Do("DEL", roomKey(g.Scope, g.Room))
+	return err
+}
@@ -0,0 +1,190 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestRoomCode tests generating room codes.
+func TestRoomCode(t *testing.T) {
+	Convey("When we generate a room code", t, func() {
+		code, err := newRoomCode()
+		So(err, ShouldBeNil)
+
+		Convey("It should be short and readable", func() {
+			So(len(code), ShouldEqual, roomCodeLength)
+			for _, r := range code {
+				So(strings.ContainsRune(roomCodeAlphabet, r), ShouldBeTrue)
+			}
+		})
+	})
+}
+
+// TestCreateAndFindRoom tests creating a private room, and finding it by its code.
+func TestCreateAndFindRoom(t *testing.T) {
+	Convey("When we have a simon says", t, func() {
+		server := mustSimonSays()
+		defer server.Close()
+		con := server.pool.Get()
+
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		ctx := context.TODO()
+
+		Convey("There shouldn't be a room that hasn't been created", func() {
+			_, err := findRoom(ctx, con, "", "NOROOM")
+			So(err, ShouldEqual, ErrRoomNotFound)
+		})
+
+		Convey("We can create a room", func() {
+			game, err := createRoom(ctx, con, "", 3, time.Minute)
+			So(err, ShouldBeNil)
+			So(game.ID, ShouldNotBeEmpty)
+			So(game.Room, ShouldNotBeEmpty)
+			So(game.Size, ShouldEqual, 3)
+
+			ttl, err := redis.Int(con.Do("PTTL", roomKey("", game.Room)))
+			So(err, ShouldBeNil)
+			So(ttl, ShouldBeGreaterThan, 0)
+
+			Convey("It should not be in the open games", func() {
+				n, err := redis.Int(con.Do("ZCARD", openGamesKey("", 3)))
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+			})
+
+			Convey("We can find it by its code, in any case", func() {
+				found, err := findRoom(ctx, con, "", strings.ToLower(game.Room))
+				So(err, ShouldBeNil)
+				So(found.ID, ShouldEqual, game.ID)
+				So(found.Room, ShouldEqual, game.Room)
+				So(found.Size, ShouldEqual, 3)
+			})
+
+			Convey("Its code can't be taken by another room", func() {
+				ok, err := redis.Bool(createRoomScript.Do(con, roomKey("", game.Room), "another game", 2, 1000))
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+
+				found, err := findRoom(ctx, con, "", game.Room)
+				So(err, ShouldBeNil)
+				So(found.ID, ShouldEqual, game.ID)
+				So(found.Size, ShouldEqual, 3)
+			})
+
+			Convey("Once it's closed, we can't find it", func() {
+				err := closeRoom(ctx, con, game)
+				So(err, ShouldBeNil)
+				_, err = findRoom(ctx, con, "", game.Room)
+				So(err, ShouldEqual, ErrRoomNotFound)
+			})
+		})
+	})
+}
+
+// TestPrivateGame tests playing a game in a private room.
+func TestPrivateGame(t *testing.T) {
+
+	Convey("Given a SimonSays", t, func() {
+		game, err := NewSimonSays("", nil)
+		So(err, ShouldBeNil)
+		defer game.Close()
+
+		Convey("A room should expire if nobody joins", func() {
+			game.RoomExpiry = 200 * time.Millisecond
+			p := newMockStream()
+			err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Lonely", Private: true}}})
+			So(err, ShouldBeNil)
+
+			err = game.Game(p)
+			So(grpc.Code(err), ShouldEqual, codes.DeadlineExceeded)
+
+			res, err := p.PullSend()
+			So(err, ShouldBeNil)
+			So(res.Room, ShouldNotBeEmpty)
+			So(p, shouldError, Error_TIMEOUT)
+		})
+
+		Convey("Friends should be able to play in a private room", func(c C) {
+			playerOne := newMockStream()
+			playerTwo := newMockStream()
+			wg := sync.WaitGroup{}
+
+			err := playerOne.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Private: true}}})
+			So(err, ShouldBeNil)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := game.Game(playerOne)
+				c.So(err, ShouldBeNil)
+			}()
+
+			res, err := playerOne.PullSend()
+			So(err, ShouldBeNil)
+			So(res.Room, ShouldNotBeEmpty)
+			So(res.Event, ShouldBeNil)
+
+			err = playerTwo.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Two", Room: res.Room}}})
+			So(err, ShouldBeNil)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := game.Game(playerTwo)
+				c.So(err, ShouldBeNil)
+			}()
+
+			So(playerOne, shouldState, Response_BEGIN)
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(playerOne, shouldState, Response_START_TURN)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			Convey("And nobody else can join once it's full", func() {
+				p := newMockStream()
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Three", Room: res.Room}}})
+				So(err, ShouldBeNil)
+				So(grpc.Code(game.Game(p)), ShouldEqual, codes.NotFound)
+				So(p, shouldError, Error_NOT_FOUND)
+
+				mustPress(playerOne, Color_GREEN)
+				So(playerOne, shouldLightup, Color_GREEN)
+				So(playerTwo, shouldLightup, Color_GREEN)
+				So(playerOne, shouldState, Response_STOP_TURN)
+				So(playerTwo, shouldState, Response_START_TURN)
+
+				mustPress(playerTwo, Color_BLUE)
+				So(playerOne, shouldLightup, Color_BLUE)
+				So(playerTwo, shouldLightup, Color_BLUE)
+				So(playerOne, shouldState, Response_WIN)
+				So(playerTwo, shouldState, Response_LOSE)
+
+				wg.Wait()
+			})
+		})
+	})
+}
@@ -16,51 +16,168 @@ limitations under the License.
 package simonsays
 
 import (
-	"errors"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	"github.com/garyburd/redigo/redis"
 	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	uuid "github.com/nu7hatch/gouuid"
 	"golang.org/x/net/context"
 )
 
 // SimonSays is the data structure that implements the SimonSaysServer
 // interface for our gRPC server.
 type SimonSays struct {
-	pool *redis.Pool
+	// the number of streams in progress. Accessed atomically,
+	// so it is kept first for alignment.
+	active int64
+	pool   *redis.Pool
+	// passes messages between the players of each game.
+	broker Broker
+	// records what happens in each game.
+	history History
+	// passes messages straight between servers, outside of any game, like telling
+	// a player's older session it has been replaced, or relaying a resumed session.
+	// Not recorded.
+	direct Broker
+	// the id of this server, that other servers relay resumed sessions to.
+	id string
+	// the requests from other servers to relay the sessions on this one.
+	resumes Subscription
+	// set to the id of this server when the broker can't reach other servers,
+	// so players are only matched with others on this one.
+	scope string
+	// how long to wait between each colour the server plays.
+	pace time.Duration
+
+	// TurnTimeout is how long a player has to complete their turn
+	// before they lose. Zero means there is no limit.
+	TurnTimeout time.Duration
+	// PressTimeout is how long a player has between each press
+	// in their turn before they lose. Zero means there is no limit.
+	PressTimeout time.Duration
+	// RoomExpiry is how long a private room waits for
+	// players to join, before it expires.
+	RoomExpiry time.Duration
+	// GracePeriod is how long a game waits for a player whose stream
+	// has dropped to resume it. Zero means games can't be resumed.
+	GracePeriod time.Duration
+	// DuplicateSessions is what happens when a player joins while they
+	// already have a game going. Defaults to RejectDuplicates.
+	DuplicateSessions DuplicatePolicy
+	// JoinLimiter limits how often each player, and each address, can join
+	// a game, through the JoinInterceptor. Nil means there is no limit.
+	JoinLimiter Limiter
+	// PressLimiter limits how fast each player, and each address, can press
+	// colours. Nil means there is no limit.
+	PressLimiter Limiter
+
+	// the players' sessions, that can be resumed.
+	sessions *sessions
+	// closed when the server stops taking new games, and when
+	// the games that are left should be ended, on shutdown.
+	draining chan struct{}
+	stopping chan struct{}
 }
 
+// deadlineInterval is how often turn deadlines are checked.
+const deadlineInterval = 100 * time.Millisecond
+
+// redisDialTimeout is how long connecting to Redis over TLS can take.
+const redisDialTimeout = 10 * time.Second
+
+// matchInterval is how often a player waiting on their own open game
+// looks for another one close enough to their rating to join instead.
+const matchInterval = 500 * time.Millisecond
+
 // Version is the current version of this implementation of Simon Says.
 const Version string = "v0.1e"
 
-// NewSimonSays Create a new Simon Says.
-func NewSimonSays(address string) (*SimonSays, error) {
+// NewSimonSays Create a new Simon Says, using the Redis server at the given address
+// to find games, and the broker to pass messages between players.
+// If the broker is nil, Redis pub/sub is used. With the memory broker, players are
+// only matched with others on this server, though Redis is still used for everything
+// else. The options are used to connect to Redis, like redis.DialPassword, or RedisTLS.
+func NewSimonSays(address string, broker Broker, options ...redis.DialOption) (*SimonSays, error) {
 	log.Printf("[Info][Server] Starting Server: %v", Version)
 
 	if address == "" {
 		address = ":6379"
 	}
 
-	s := &SimonSays{pool: newPool(address)}
+	if broker == nil {
+		broker = NewRedisBroker(address, options...)
+	}
+
+	u, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := newPool(address, options...)
+	history := newRedisHistory(pool)
+
+	s := &SimonSays{
+		pool:        pool,
+		broker:      &recordingBroker{Broker: broker, history: history},
+		history:     history,
+		direct:      broker,
+		id:          u.String(),
+		pace:        defaultPace,
+		RoomExpiry:  defaultRoomExpiry,
+		GracePeriod: defaultGracePeriod,
+		sessions:    &sessions{m: map[string]*session{}},
+		draining:    make(chan struct{}),
+		stopping:    make(chan struct{}),
+	}
+
+	// messages in memory never leave this server, so neither can its games.
+	if _, ok := broker.(*memoryBroker); ok {
+		log.Printf("[Info][Server] Only matching players on this server.")
+		s.scope = s.id
+	}
 
 	log.Printf("[Info][Redis] Connecting: %v", address)
-	return s, s.pingRedis()
+	if err := s.pingRedis(); err != nil {
+		return s, err
+	}
+
+	return s, s.relayResumes()
 }
 
 // Close closes all resources.
 func (s SimonSays) Close() error {
+	if s.resumes != nil {
+		if err := s.resumes.Unsubscribe(); err != nil {
+			return err
+		}
+	}
+	if err := s.broker.Close(); err != nil {
+		return err
+	}
 	return s.pool.Close()
 }
 
 // Game function is an implementation of the gRPC Game Service.
 // When connected, this is the main functionality of running a
-// Game for the connected player.
+// Game for the connected player. If the game ends early, the player
+// is sent an ERROR, and it ends with the gRPC status for the reason.
 func (s *SimonSays) Game(stream SimonSays_GameServer) error {
+	defer s.track()()
+
+	stream = &gameStream{SimonSays_GameServer: stream, ctx: logger.NewContext(stream.Context())}
+
+	return endStream(stream, s.play(stream))
+}
+
+// play runs the Game for the connected player.
+func (s *SimonSays) play(stream SimonSays_GameServer) (err error) {
 	ctx := stream.Context()
-	defer logger.Clear(ctx)
 
 	lc := "Game"
 	// first let's get the player
@@ -71,63 +188,108 @@ func (s *SimonSays) Game(stream SimonSays_GameServer) error {
 	player := req.GetJoin()
 	if player == nil {
 		logger.Error(ctx, lc, "Player was nil on initial join request. %v", req)
-		return errors.New("Player was nil on initial join request.")
+		return invalidRequestError("Player was nil on initial join request.")
+	}
+	if err := identify(ctx, player); err != nil {
+		logger.Warn(ctx, lc, "Player %v does not match the token.", player.Id)
+		return err
+	}
+	if err := validateJoin(player); err != nil {
+		logger.Warn(ctx, lc, "Invalid join request. %v", err)
+		return err
 	}
 	logger.Set(ctx, "Player", player.Id)
+
+	if player.Resume != "" {
+		return s.resume(stream, player)
+	}
+
 	logger.Info(ctx, lc, "Player %#v is attempting to join.", player)
+	joinsTotal.Inc(joinMode(player))
 
-	// find what game to join
-	con := s.pool.Get()
-	defer con.Close()
-	game, isNew, err := findGame(ctx, con)
+	if s.isDraining() {
+		logger.Info(ctx, lc, "Server is shutting down. Not taking new games.")
+		return ErrShuttingDown
+	}
 
+	// players only get to have one game going at a time.
+	l, err := s.acquireLease(ctx, player.Id)
 	if err != nil {
 		return err
 	}
-	logger.Set(ctx, "Game", game.ID)
-	logger.Info(ctx, lc, "Connecting to game %v. New?: %v", game.ID, isNew)
+	defer l.release(ctx)
 
-	logger.Info(ctx, lc, "Start to receive PubSub messages")
+	if player.Mode == Request_SOLO {
+		return s.solo(stream, player, l.lost)
+	}
 
-	// make sure that you always unjoin, if something happens to go wrong.
-	defer func() {
-		con := s.pool.Get()
-		err := closeOpenGame(ctx, con, game)
-		if err != nil {
-			logger.Error(ctx, lc, "Error attempting to close game. %v", err)
-		}
-		err = con.Close()
-		if err != nil {
-			logger.Error(ctx, lc, "Error closing close open game connection. %v", err)
-		}
-	}()
+	size, err := gameSize(player)
+	if err != nil {
+		logger.Error(ctx, lc, "Invalid game size. %v", err)
+		return err
+	}
 
-	// make sure that at the end, you always unsubscribe.
-	defer func() {
-		con := s.pool.Get()
-		err := redis.PubSubConn{con}.Unsubscribe(game.ID)
-		if err != nil {
-			logger.Error(ctx, lc, "Error unsubscribing from Game Topic %v, %v", game.ID, err)
-		}
-		err = con.Close()
-		if err != nil {
-			logger.Error(ctx, lc, "Error closing unsubscribe connection. %v", err)
-		}
+	// the game carries on through the session, even if the stream drops.
+	sess, err := s.newSession(stream, player)
+	if err != nil {
+		return err
+	}
+	defer func() { s.closeSession(sess, err) }()
 
-	}()
+	ctx = sess.Context()
 
-	msgs, err := subscribe(ctx, s.pool.Get(), game)
+	// find what game to join
+	con := s.pool.Get()
+	defer con.Close()
+	in := &aheadStream{SimonSays_GameServer: sess}
+	game, msgs, sub, err := s.joinGame(ctx, con, player, size, in)
 
 	if err != nil {
 		return err
 	}
 
-	if err := connectGame(ctx, con, game, player, isNew); err != nil {
-		return err
+	// make sure that you always unjoin and unsubscribe, if something happens to go wrong.
+	defer s.leaveGame(ctx, game, player, sub)
+
+	// the player may come back on any server, so it needs to be able to find the session.
+	if sess.grace {
+		if err := s.saveSession(ctx, con, sess, game); err != nil {
+			return err
+		}
+	}
+
+	// let the player know the code to give to their friends.
+	if player.Private {
+		if err := sendResponse(sess, &Response{Room: game.Room}); err != nil {
+			return err
+		}
 	}
 
 	// subscribe to incoming key events, and get back a channel of errors.
-	perrs := recvPress(s.pool.Get(), game, player, stream)
+	perrs := recvPress(s.broker, s.PressLimiter, game, player, in)
+
+	// only check deadlines if there are any.
+	deadlines, stop := s.deadlineTicks()
+	defer stop()
+
+	// play back the sequence a colour at a time, if the player wants it.
+	playback, stopPlayback := playbackTicks(game)
+	defer stopPlayback()
+
+	// give up on a private room if it doesn't fill up in time.
+	expired := s.roomExpired(game)
+
+	// let everyone know we are still waiting, until the game begins.
+	alive := time.NewTicker(livenessInterval)
+	defer alive.Stop()
+
+	// fires if the player doesn't resume in time, after their stream drops.
+	var grace <-chan time.Time
+	forfeited := false
+
+	// games that are still waiting for players end when the server shuts down,
+	// but running games have a while to finish.
+	draining := s.draining
 
 	for {
 		select {
@@ -139,13 +301,15 @@ func (s *SimonSays) Game(stream SimonSays_GameServer) error {
 				return nil
 			}
 
-			logger.Info(ctx, lc, "Handling incoming messsage...")
+			logger.Debug(ctx, lc, "Handling incoming messsage...")
 
-			err := handle(con, game, player, stream, msg)
+			err := handle(s.broker, game, player, sess, msg)
 			if err != nil {
 				// if we are EOF, then simply exit.
 				if err == io.EOF {
 					logger.Info(ctx, lc, "[Game] EOF. Closing connection.")
+					s.rateGame(ctx, con, game, player)
+					s.rankGame(ctx, con, game, player)
 					return nil
 				}
 				return err
@@ -156,10 +320,291 @@ func (s *SimonSays) Game(stream SimonSays_GameServer) error {
 			// remember, a closed channel, will return a nil err.
 			if err != nil {
 				logger.Error(ctx, lc, "There was a press error. %v", err)
+				// the player is out, so let everyone else know, rather than leave them waiting.
+				if reason, ok := pressErrorReason(err); ok && len(game.Players()) > 0 {
+					if err := publishLost(ctx, s.broker, game, player, reason); err != nil {
+						logger.Error(ctx, lc, "Could not let the other players know. %v", err)
+					}
+				}
+				return err
+			}
+
+		// check to see if the player has run out of time.
+		case <-deadlines:
+			if err := handleTurnTimeout(sess, s.broker, game, player); err != nil {
+				return err
+			}
+
+		// play back the next colour, without holding up everything else.
+		case <-playback:
+			if err := playNext(game, player, sess); err != nil {
+				return err
+			}
+
+		// keep our spot in the game, if it hasn't begun.
+		case <-alive.C:
+			if len(game.Players()) == 0 {
+				if err := refreshAlive(ctx, con, game, player); err != nil {
+					return err
+				}
+			}
+
+		// check to see if nobody joined the room in time.
+		case <-expired:
+			if len(game.Players()) == 0 {
+				logger.Error(ctx, lc, "Room %v has expired.", game.Room)
+				return ErrRoomExpired
+			}
+
+		// the server is shutting down, so give up on the game if it hasn't begun.
+		case <-draining:
+			draining = nil
+			waiting, err := isWaiting(ctx, con, game)
+			if err != nil {
+				return err
+			}
+			if waiting {
+				logger.Info(ctx, lc, "Server is shutting down. Closing game.")
+				return ErrShuttingDown
+			}
+
+		// the server can't wait any longer for the game to finish.
+		case <-s.stopping:
+			if err := abandonGame(sess, s.broker, game, player); err != nil {
+				return err
+			}
+			return ErrShuttingDown
+
+		// the stream has dropped, so wait for the player to resume.
+		case <-sess.dropped:
+			// they may have already resumed.
+			if sess.current() != nil {
+				continue
+			}
+			logger.Info(ctx, lc, "Stream dropped. Waiting %v for the player to resume.", s.GracePeriod)
+			grace = time.After(s.GracePeriod)
+			err := publish(ctx, s.broker, game, message{Type: reconnectingMessage, Player: player.Id})
+			if err != nil {
 				return err
 			}
+
+		// the player is back on a new stream.
+		case stream := <-sess.attaching:
+			if err := s.resumeSession(sess, stream, game, player); err != nil {
+				logger.Error(ctx, lc, "Could not resume the game. %v", err)
+				continue
+			}
+			grace = nil
+			forfeited = false
+
+		// the player has joined again from somewhere else.
+		case <-l.lost:
+			if len(game.Players()) > 0 {
+				if err := leaveReplaced(sess, s.broker, game, player); err != nil {
+					return err
+				}
+			}
+			return ErrSessionReplaced
+
+		// the player didn't come back in time.
+		case <-grace:
+			grace = nil
+			if sess.current() == nil {
+				logger.Info(ctx, lc, "Player did not resume in time.")
+				forfeited = true
+			}
+		}
+
+		// the player loses when their turn comes around, if they haven't resumed.
+		if forfeited && game.IsMyTurn() {
+			forfeited = false
+			if err := forfeit(sess, s.broker, game, player); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// joinGame finds a game for the player, subscribes to it, and joins it.
+// If there isn't a game close enough to the player's rating, they start a new one, and
+// join someone else's instead if one comes within their rating window before theirs is taken.
+// If a game from the open games turns out to have gone, tries the next one,
+// until there are none left and the player starts a new game. The player's
+// stream is received from, in the meantime, through in.
+func (s *SimonSays) joinGame(ctx context.Context, con redis.Conn, player *Request_Player, size int, in *aheadStream) (*Game, <-chan *message, Subscription, error) {
+	lc := "JoinGame"
+
+	rating, err := getRating(ctx, con, player.Id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	start := time.Now()
+
+	// the game of someone else's to join, found while hosting our own.
+	var next *Game
+
+	for {
+		game, isNew := next, false
+		if game == nil {
+			game, isNew, err = s.chooseGame(ctx, con, player, size, rating, window(time.Since(start)))
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		next = nil
+
+		logger.Set(ctx, "Game", game.ID)
+		logger.Info(ctx, lc, "Connecting to game %v. New?: %v", game.ID, isNew)
+		game.Host = isNew
+		game.SetTimeouts(s.TurnTimeout, s.PressTimeout)
+		if player.Replay {
+			game.SetReplay(s.pace)
+		}
+
+		logger.Info(ctx, lc, "Start to receive PubSub messages")
+		msgs, sub, err := subscribe(ctx, s.broker, game)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		err = s.connectGame(ctx, con, game, player, isNew)
+
+		// while nobody has taken our open game, keep looking for one to join instead.
+		if err == nil && isNew && game.Room == "" {
+			next, err = s.awaitPlayers(ctx, con, game, player, rating, start, in)
+		}
+
+		if err == nil && next == nil {
+			matchmakingSeconds.ObserveSince(start, joinMode(player))
+			return game, msgs, sub, nil
+		}
+
+		s.leaveGame(ctx, game, player, sub)
+
+		if next != nil {
+			logger.Info(ctx, lc, "Leaving game %v to join game %v.", game.ID, next.ID)
+			continue
+		}
+
+		// only games from the open games list can be swapped for another.
+		if err != errGameGone || isNew || game.Room != "" {
+			return nil, nil, nil, err
+		}
+
+		logger.Info(ctx, lc, "Game %v has gone. Trying the next one.", game.ID)
+	}
+}
+
+// awaitPlayers waits for another player to take the player's new open game, while looking for an open
+// game of someone else's to join instead, within the rating window as it widens. Returns the game to
+// join instead, once it has been taken off the open games and ours withdrawn, or nil once ours is taken.
+// The stream is received from in the meantime, so we know if it ends before the game begins.
+func (s *SimonSays) awaitPlayers(ctx context.Context, con redis.Conn, game *Game, player *Request_Player, rating int, start time.Time, in *aheadStream) (*Game, error) {
+	lc := "AwaitPlayers"
+
+	for {
+		if in.ahead == nil {
+			in.ahead = receiveAhead(in.SimonSays_GameServer)
+		}
+
+		select {
+		case r := <-in.ahead:
+			in.ahead = nil
+			if r.err != nil {
+				logger.Info(ctx, lc, "Stream ended before the game began. %v", r.err)
+				return nil, r.err
+			}
+			logger.Info(ctx, lc, "Ignored press, since the game hasn't begun.")
+			continue
+		case <-time.After(matchInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.draining:
+			return nil, ErrShuttingDown
+		}
+
+		if err := refreshAlive(ctx, con, game, player); err != nil {
+			return nil, err
+		}
+
+		taken, err := gameTaken(ctx, con, game)
+		if err != nil || taken {
+			return nil, err
+		}
+
+		w := window(time.Since(start))
+		games, err := nearGames(ctx, con, game.Scope, game.Size, rating, w, game.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(games) == 0 {
+			continue
+		}
+
+		// nobody can take ours while we try the others, and if someone just has, we stay.
+		withdrawn, err := withdrawGame(ctx, con, game)
+		if err != nil || !withdrawn {
+			return nil, err
+		}
+
+		other, err := matchGame(ctx, con, game.Scope, game.Size, rating, w, game.ID)
+		if err != nil || other != nil {
+			return other, err
+		}
+
+		logger.Info(ctx, lc, "The open games near %v have gone. Reopening game %v.", rating, game.ID)
+		if err := reopenGame(ctx, con, game); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rateGame updates the ratings at the end of the game, if the player lost.
+// The player lost to everyone left in the game. Errors are logged, since
+// the game is over either way.
+func (s *SimonSays) rateGame(ctx context.Context, con redis.Conn, game *Game, player *Request_Player) {
+	if !game.Lost() {
+		return
+	}
+
+	var winners []string
+	for _, p := range game.Players() {
+		if p != player.Id {
+			winners = append(winners, p)
 		}
 	}
+
+	rateGame(ctx, con, player.Id, winners)
+}
+
+// leaveGame unsubscribes from the game, and makes sure it isn't left open.
+// If the game never began, frees up the player's spot in it.
+func (s *SimonSays) leaveGame(ctx context.Context, game *Game, player *Request_Player, sub Subscription) {
+	lc := "LeaveGame"
+
+	err := sub.Unsubscribe()
+	if err != nil {
+		logger.Error(ctx, lc, "Error unsubscribing from Game Topic %v, %v", game.ID, err)
+	}
+
+	con := s.pool.Get()
+	err = closeOpenGame(ctx, con, game)
+	if err != nil {
+		logger.Error(ctx, lc, "Error attempting to close game. %v", err)
+	}
+	// if the game never began, free up our spot in it.
+	if len(game.Players()) == 0 {
+		err = leavePlayers(ctx, con, game, player)
+		if err != nil {
+			logger.Error(ctx, lc, "Error attempting to leave game. %v", err)
+		}
+	}
+	err = con.Close()
+	if err != nil {
+		logger.Error(ctx, lc, "Error closing close open game connection. %v", err)
+	}
 }
 
 // pingRedis pings redis, to check if we are
@@ -167,10 +612,7 @@ func (s *SimonSays) Game(stream SimonSays_GameServer) error {
 func (s *SimonSays) pingRedis() error {
 
 	return backoff.Retry(func() error {
-		con := s.pool.Get()
-		defer con.Close()
-
-		_, err := con.Do("PING")
+		err := s.Ping()
 		if err != nil {
 			log.Printf("[Warn][Redis] Could not connect to Redis. %v", err)
 		} else {
@@ -181,12 +623,22 @@ func (s *SimonSays) pingRedis() error {
 	}, backoff.NewExponentialBackOff())
 }
 
-func newPool(address string) *redis.Pool {
+// Ping pings redis once, to check if it can be reached.
+// Returns an error if there was a problem.
+func (s *SimonSays) Ping() error {
+	con := s.pool.Get()
+	defer con.Close()
+
+	_, err := con.Do("PING")
+	return err
+}
+
+func newPool(address string, options ...redis.DialOption) *redis.Pool {
 	return &redis.Pool{
 		MaxIdle:     3,
 		IdleTimeout: 240 * time.Second,
 		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", address)
+			c, err := redis.Dial("tcp", address, options...)
 			if err != nil {
 				return nil, err
 			}
@@ -199,44 +651,196 @@ func newPool(address string) *redis.Pool {
 	}
 }
 
+// RedisTLS returns an option to connect to Redis over TLS, with the given config.
+// If the config doesn't have a ServerName, the host of the Redis address is used.
+func RedisTLS(config *tls.Config) redis.DialOption {
+	return redis.DialNetDial(func(network, addr string) (net.Conn, error) {
+		return tls.DialWithDialer(&net.Dialer{Timeout: redisDialTimeout}, network, addr, config)
+	})
+}
+
+// gameSize returns how many players the joining player wants
+// in their game. Defaults to two players.
+func gameSize(player *Request_Player) (int, error) {
+	size := int(player.Players)
+	if size == 0 {
+		return minPlayers, nil
+	}
+
+	if size < minPlayers || size > maxPlayers {
+		return 0, invalidRequestError(fmt.Sprintf("Number of players must be between %v and %v. Was %v", minPlayers, maxPlayers, size))
+	}
+
+	return size, nil
+}
+
+// deadlineTicks returns a channel that ticks whenever turn deadlines should be checked,
+// and a function to stop it. If there are no deadlines, the channel never ticks.
+func (s *SimonSays) deadlineTicks() (<-chan time.Time, func()) {
+	if s.TurnTimeout == 0 && s.PressTimeout == 0 {
+		return nil, func() {}
+	}
+
+	t := time.NewTicker(deadlineInterval)
+	return t.C, t.Stop
+}
+
+// playbackTicks returns a channel that ticks whenever the next colour of the sequence should
+// be played back, and a function to stop it. If the player doesn't want the sequence played
+// back, the channel never ticks.
+func playbackTicks(game *Game) (<-chan time.Time, func()) {
+	pace := game.ReplayPace()
+	if pace == 0 {
+		return nil, func() {}
+	}
+
+	t := time.NewTicker(pace)
+	return t.C, t.Stop
+}
+
+// chooseGame finds the game the player has asked to join. Either a private room
+// by its code, a brand new private room, or a game from the public queue.
+// Games from the public queue are matched by rating, within the window.
+// Returns the Game and if it's a new game or not.
+func (s *SimonSays) chooseGame(ctx context.Context, con redis.Conn, player *Request_Player, size, rating, window int) (*Game, bool, error) {
+	switch {
+	case player.Room != "":
+		game, err := findRoom(ctx, con, s.scope, player.Room)
+		return game, false, err
+	case player.Private:
+		game, err := createRoom(ctx, con, s.scope, size, s.RoomExpiry)
+		return game, true, err
+	}
+
+	return findGame(ctx, con, s.scope, size, rating, window)
+}
+
+// roomExpired returns a channel that fires when the game's private room
+// has expired. If the game isn't in a private room, it never fires.
+func (s *SimonSays) roomExpired(game *Game) <-chan time.Time {
+	if game.Room == "" {
+		return nil
+	}
+
+	return time.After(s.RoomExpiry)
+}
+
 // connectGame joins a game if one is in progress,
 // or advertises this one as open if it is not.
-func connectGame(ctx context.Context, con redis.Conn, game *Game, player *Request_Player, isNew bool) error {
-	// if it's new, then add it for discovery.
-	if isNew {
-		err := addOpenGame(ctx, con, game)
-		if err != nil {
-			return err
+// The last player to join the game, BEGINs it.
+func (s *SimonSays) connectGame(ctx context.Context, con redis.Conn, game *Game, player *Request_Player, isNew bool) error {
+	n, err := joinPlayers(ctx, con, game, player)
+	if err != nil {
+		return err
+	}
+
+	if game.Room != "" {
+		// someone beat us to the last spot.
+		if n > game.Size {
+			logger.Info(ctx, "ConnectGame", "Room %v is already full.", game.Room)
+			return ErrRoomFull
 		}
-	} else {
-		// shouldn't be any, so can be empty.
-		b, err := game.EncodePresses()
 
-		if err != nil {
-			return err
+		// private rooms are found by their code, so just wait for more players.
+		if n < game.Size {
+			return nil
 		}
 
-		// make sure we have 2 people subscribed at this point.
-		if err := ensureSubscribers(ctx, con, game, 2); err != nil {
+		if err := closeRoom(ctx, con, game); err != nil {
 			return err
 		}
+	} else {
+		// if it's new, then add it for discovery.
+		if isNew {
+			return addOpenGame(ctx, con, game)
+		}
 
-		msg := message{Player: player.Id, Type: beginMessage, Data: b}
+		// still waiting on more players, so let the next one find it.
+		if n < game.Size {
+			return reopenGame(ctx, con, game)
+		}
+	}
 
-		err = publish(ctx, con, game, msg)
-		if err != nil {
-			return err
+	// make sure we have everyone subscribed at this point.
+	// If not, someone has gone without leaving.
+	if err := ensureSubscribers(ctx, s.broker, game, game.Size); err != nil {
+		if _, ok := err.(timeoutError); ok {
+			return errGameGone
 		}
+		return err
 	}
 
+	players, err := listPlayers(ctx, con, game)
+	if err != nil {
+		return err
+	}
+
+	msg := message{Player: player.Id, Type: beginMessage, Players: players}
+	if err := publish(ctx, s.broker, game, msg); err != nil {
+		return err
+	}
+
+	gamesStartedTotal.Inc(gameMode(game))
 	return nil
 }
 
+// responseStream is a stream that Responses can be sent down.
+type responseStream interface {
+	Send(*Response) error
+	Context() context.Context
+}
+
+// gameStream is a Game stream with a context that
+// the player and their game are logged with.
+type gameStream struct {
+	SimonSays_GameServer
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (g *gameStream) Context() context.Context {
+	return g.ctx
+}
+
+// received is a request received from a stream, or the error receiving it.
+type received struct {
+	req *Request
+	err error
+}
+
+// receiveAhead receives the next request from the stream in the background.
+func receiveAhead(stream SimonSays_GameServer) <-chan received {
+	c := make(chan received, 1)
+	go func() {
+		req, err := stream.Recv()
+		c <- received{req: req, err: err}
+	}()
+	return c
+}
+
+// aheadStream is a stream whose next request may already be being received,
+// while the player waits for their game to begin.
+type aheadStream struct {
+	SimonSays_GameServer
+	ahead <-chan received
+}
+
+// Recv receives the request that was being received ahead, if there is one,
+// and from then on receives from the stream.
+func (a *aheadStream) Recv() (*Request, error) {
+	if a.ahead != nil {
+		r := <-a.ahead
+		a.ahead = nil
+		return r.req, r.err
+	}
+	return a.SimonSays_GameServer.Recv()
+}
+
 // sendResponse Sends a request.
-func sendResponse(stream SimonSays_GameServer, r *Response) error {
+func sendResponse(stream responseStream, r *Response) error {
 	lc := "Response"
 	ctx := stream.Context()
-	logger.Info(ctx, lc, "Sending response: %v", r)
+	logger.Debug(ctx, lc, "Sending response: %v", r)
 	err := stream.Send(r)
 
 	if err != nil {
@@ -257,7 +861,7 @@ func receiveRequest(stream SimonSays_GameServer) (*Request, error) {
 		return nil, err
 	}
 
-	logger.Info(ctx, lc, "Received: %v", req)
+	logger.Debug(ctx, lc, "Received: %v", req)
 
 	return req, nil
 }
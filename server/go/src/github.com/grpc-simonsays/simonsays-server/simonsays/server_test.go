@@ -21,13 +21,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/garyburd/redigo/redis"
 	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
 )
 
 // TestRedisConn tests that we can connect to Redis.
 func TestRedisConn(t *testing.T) {
 	Convey("When we have a Simon Says", t, func() {
-		game, err := NewSimonSays("")
+		game, err := NewSimonSays("", nil)
 		So(err, ShouldBeNil)
 		So(game, ShouldNotBeNil)
 		Convey("We can ping redis successfully", func() {
@@ -38,6 +40,10 @@ func TestRedisConn(t *testing.T) {
 			So(err, ShouldBeNil)
 			So("PONG", ShouldEqual, res)
 		})
+
+		Convey("We can check redis can be reached", func() {
+			So(game.Ping(), ShouldBeNil)
+		})
 	})
 }
 
@@ -49,7 +55,7 @@ func TestSimpleGame(t *testing.T) {
 	playerTwo := newMockStream()
 
 	Convey("Given a SimonSays", t, func() {
-		game, err := NewSimonSays("")
+		game, err := NewSimonSays("", nil)
 		So(err, ShouldBeNil)
 		So(game, ShouldNotBeNil)
 		defer game.Close()
@@ -139,7 +145,7 @@ func TestMoreComplexGame(t *testing.T) {
 	playerTwo := newMockStream()
 
 	Convey("Given a SimonSays", t, func() {
-		game, err := NewSimonSays("")
+		game, err := NewSimonSays("", nil)
 		So(err, ShouldBeNil)
 		So(game, ShouldNotBeNil)
 		defer game.Close()
@@ -230,6 +236,449 @@ func TestMoreComplexGame(t *testing.T) {
 	})
 }
 
+// TestThreePlayerGame tests a game with three players, where
+// players are eliminated until there is only one left.
+func TestThreePlayerGame(t *testing.T) {
+
+	playerOne := newMockStream()
+	playerTwo := newMockStream()
+	playerThree := newMockStream()
+
+	Convey("Given a SimonSays", t, func() {
+		game, err := NewSimonSays("", nil)
+		So(err, ShouldBeNil)
+		So(game, ShouldNotBeNil)
+		defer game.Close()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err = con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		Convey("We should be able to complete a game with three players", func(c C) {
+			wg := sync.WaitGroup{}
+
+			// everyone joins, in order, and wait
+			for i, p := range []*mockStream{playerOne, playerTwo, playerThree} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1), Players: 3}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(p *mockStream) {
+					defer wg.Done()
+					err := game.Game(p)
+					c.So(err, ShouldBeNil)
+				}(p)
+
+				// hacky, but can't think of a better way. Wait Group helps, but only sometimes.
+				time.Sleep(time.Second)
+			}
+
+			So(playerOne, shouldState, Response_BEGIN)
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(playerThree, shouldState, Response_BEGIN)
+
+			// the last player to join stops their turn, so the first player starts.
+			So(playerOne, shouldState, Response_START_TURN)
+			So(playerThree, shouldState, Response_STOP_TURN)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerThree, shouldLightup, Color_GREEN)
+
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			mustPress(playerTwo, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerThree, shouldLightup, Color_GREEN)
+
+			mustPress(playerTwo, Color_BLUE)
+			So(playerOne, shouldLightup, Color_BLUE)
+			So(playerTwo, shouldLightup, Color_BLUE)
+			So(playerThree, shouldLightup, Color_BLUE)
+
+			So(playerTwo, shouldState, Response_STOP_TURN)
+			So(playerThree, shouldState, Response_START_TURN)
+
+			// player three gets it wrong, and is eliminated
+			mustPress(playerThree, Color_RED)
+			So(playerOne, shouldLightup, Color_RED)
+			So(playerTwo, shouldLightup, Color_RED)
+			So(playerThree, shouldLightup, Color_RED)
+
+			So(playerThree, shouldState, Response_LOSE)
+
+			// player one is next, and gets to have a go at the same sequence.
+			So(playerOne, shouldState, Response_START_TURN)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+
+			mustPress(playerOne, Color_BLUE)
+			So(playerOne, shouldLightup, Color_BLUE)
+			So(playerTwo, shouldLightup, Color_BLUE)
+
+			mustPress(playerOne, Color_YELLOW)
+			So(playerOne, shouldLightup, Color_YELLOW)
+			So(playerTwo, shouldLightup, Color_YELLOW)
+
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			// this one is wrong
+			mustPress(playerTwo, Color_YELLOW)
+			So(playerOne, shouldLightup, Color_YELLOW)
+			So(playerTwo, shouldLightup, Color_YELLOW)
+
+			So(playerOne, shouldState, Response_WIN)
+			So(playerTwo, shouldState, Response_LOSE)
+
+			wg.Wait()
+		})
+	})
+}
+
+// TestTurnTimeout tests that a player that runs out of
+// time on their turn loses the game.
+func TestTurnTimeout(t *testing.T) {
+
+	playerOne := newMockStream()
+	playerTwo := newMockStream()
+
+	Convey("Given a SimonSays with a turn timeout", t, func() {
+		game, err := NewSimonSays("", nil)
+		So(err, ShouldBeNil)
+		So(game, ShouldNotBeNil)
+		defer game.Close()
+		game.TurnTimeout = 500 * time.Millisecond
+
+		Convey("A player that doesn't press anything should lose", func(c C) {
+			wg := sync.WaitGroup{}
+
+			for i, p := range []*mockStream{playerOne, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1)}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(p *mockStream) {
+					defer wg.Done()
+					err := game.Game(p)
+					c.So(err, ShouldBeNil)
+				}(p)
+
+				// hacky, but can't think of a better way. Wait Group helps, but only sometimes.
+				time.Sleep(time.Second)
+			}
+
+			So(playerOne, shouldState, Response_BEGIN)
+			So(playerTwo, shouldState, Response_BEGIN)
+
+			// player one should be told when their turn ends.
+			res, err := playerOne.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_START_TURN)
+			So(res.Deadline, ShouldBeGreaterThan, 0)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			// and player one does nothing.
+			So(playerOne, shouldState, Response_LOSE)
+			So(playerTwo, shouldState, Response_WIN)
+
+			wg.Wait()
+		})
+	})
+}
+
+// TestJoinGoneGame tests that a player that is matched with a game
+// whose host has gone, moves on and hosts a new game.
+func TestJoinGoneGame(t *testing.T) {
+
+	playerOne := newMockStream()
+	playerTwo := newMockStream()
+
+	Convey("Given a SimonSays with an open game, whose host has gone", t, func() {
+		game, err := NewSimonSays("", nil)
+		So(err, ShouldBeNil)
+		So(game, ShouldNotBeNil)
+		defer game.Close()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err = con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		// the host still looks like they are waiting, but never subscribed.
+		gone := NewGame("gone game")
+		ctx := context.TODO()
+		_, err = joinPlayers(ctx, con, gone, &Request_Player{Id: "Gone Host"})
+		So(err, ShouldBeNil)
+		So(addOpenGame(ctx, con, gone), ShouldBeNil)
+
+		Convey("Players should still be able to play each other", func(c C) {
+			wg := sync.WaitGroup{}
+
+			for i, p := range []*mockStream{playerOne, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1)}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(p *mockStream) {
+					defer wg.Done()
+					err := game.Game(p)
+					c.So(err, ShouldBeNil)
+				}(p)
+
+				// long enough to find out the gone game has gone.
+				time.Sleep(2 * time.Second)
+			}
+
+			res, err := playerOne.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+			So(res.Game, ShouldNotEqual, gone.ID)
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(playerOne, shouldState, Response_START_TURN)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			mustPress(playerTwo, Color_BLUE)
+			So(playerOne, shouldLightup, Color_BLUE)
+			So(playerTwo, shouldLightup, Color_BLUE)
+			So(playerOne, shouldState, Response_WIN)
+			So(playerTwo, shouldState, Response_LOSE)
+
+			wg.Wait()
+		})
+	})
+}
+
+// TestPlaybackDoesNotBlock tests that a player's game carries on while
+// the sequence is being played back to them.
+func TestPlaybackDoesNotBlock(t *testing.T) {
+
+	Convey("Given a SimonSays that plays back the sequence slowly", t, func() {
+		game, err := NewSimonSays("", nil)
+		So(err, ShouldBeNil)
+		defer game.Close()
+		game.pace = time.Minute
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err = con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		Convey("A player should hear about the others while the sequence is played back to them", func(c C) {
+			playerOne := newMockStream()
+			playerTwo := newMockStream()
+			wg := sync.WaitGroup{}
+
+			for i, p := range []*mockStream{playerOne, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1), Replay: i == 0}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(p *mockStream) {
+					defer wg.Done()
+					game.Game(p)
+				}(p)
+
+				time.Sleep(time.Second)
+			}
+
+			So(playerOne, shouldState, Response_BEGIN)
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(playerOne, shouldState, Response_START_TURN)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			mustPress(playerTwo, Color_GREEN)
+			mustPress(playerTwo, Color_BLUE)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerOne, shouldLightup, Color_BLUE)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_BLUE)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			// player one's sequence is being played back, a minute a colour.
+			playerTwo.Disconnect()
+			So(playerOne, shouldPlayerState, Response_RECONNECTING, "Player 2")
+
+			// and their game still ends when the server shuts down.
+			game.Shutdown(500 * time.Millisecond)
+			wg.Wait()
+		})
+	})
+}
+
+// TestWaitersMatch tests that players too far apart in rating to be matched
+// straight away each host a game, and find each other as their windows widen.
+func TestWaitersMatch(t *testing.T) {
+
+	Convey("Given a SimonSays, and two players far apart in rating", t, func() {
+		game, err := NewSimonSays("", nil)
+		So(err, ShouldBeNil)
+		defer game.Close()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err = con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+		_, err = con.Do("HMSET", ratingsKey, "Player One", initialRating, "Player Two", initialRating+3*ratingWindow)
+		So(err, ShouldBeNil)
+
+		Convey("They should end up playing each other", func(c C) {
+			playerOne := newMockStream()
+			playerTwo := newMockStream()
+			wg := sync.WaitGroup{}
+
+			for i, p := range []*mockStream{playerOne, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: []string{"Player One", "Player Two"}[i]}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(p *mockStream) {
+					defer wg.Done()
+					err := game.Game(p)
+					c.So(err, ShouldBeNil)
+				}(p)
+
+				time.Sleep(time.Second)
+
+				// nobody is close enough yet, so they host a game of their own.
+				n, err := redis.Int(con.Do("ZCARD", openGamesKey("", minPlayers)))
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, i+1)
+			}
+
+			one, err := playerOne.PullSend()
+			So(err, ShouldBeNil)
+			So(one.GetTurn(), ShouldEqual, Response_BEGIN)
+			two, err := playerTwo.PullSend()
+			So(err, ShouldBeNil)
+			So(two.GetTurn(), ShouldEqual, Response_BEGIN)
+			So(one.Game, ShouldEqual, two.Game)
+
+			first, second := playerOne, playerTwo
+			if one.Current == "Player Two" {
+				first, second = playerTwo, playerOne
+			}
+			So(first, shouldState, Response_START_TURN)
+			So(second, shouldState, Response_STOP_TURN)
+
+			mustPress(first, Color_GREEN)
+			So(first, shouldLightup, Color_GREEN)
+			So(second, shouldLightup, Color_GREEN)
+			So(first, shouldState, Response_STOP_TURN)
+			So(second, shouldState, Response_START_TURN)
+
+			mustPress(second, Color_BLUE)
+			So(first, shouldLightup, Color_BLUE)
+			So(second, shouldLightup, Color_BLUE)
+			So(first, shouldState, Response_WIN)
+			So(second, shouldState, Response_LOSE)
+
+			wg.Wait()
+
+			n, err := redis.Int(con.Do("ZCARD", openGamesKey("", minPlayers)))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+	})
+}
+
+// TestMemoryBrokerMatchesLocally tests that with the memory broker, players
+// are only matched with others on the same server, that can reach them.
+func TestMemoryBrokerMatchesLocally(t *testing.T) {
+
+	Convey("Given two SimonSays with memory brokers", t, func() {
+		one, err := NewSimonSays("", NewMemoryBroker())
+		So(err, ShouldBeNil)
+		defer one.Close()
+		two, err := NewSimonSays("", NewMemoryBroker())
+		So(err, ShouldBeNil)
+		defer two.Close()
+
+		con := one.pool.Get()
+		defer con.Close()
+		_, err = con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		Convey("Players on different servers should not be matched", func(c C) {
+			playerOne := newMockStream()
+			playerTwo := newMockStream()
+			playerThree := newMockStream()
+			wg := sync.WaitGroup{}
+
+			for i, p := range []*mockStream{playerOne, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1)}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(server *SimonSays, p *mockStream) {
+					defer wg.Done()
+					server.Game(p)
+				}([]*SimonSays{one, two}[i], p)
+
+				time.Sleep(time.Second)
+			}
+
+			for _, server := range []*SimonSays{one, two} {
+				n, err := redis.Int(con.Do("ZCARD", openGamesKey(server.scope, minPlayers)))
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+			}
+			n, err := redis.Int(con.Do("ZCARD", openGamesKey("", minPlayers)))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			Convey("But a player on the same server should be", func() {
+				err := playerThree.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player 3"}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.So(one.Game(playerThree), ShouldBeNil)
+				}()
+
+				So(playerOne, shouldState, Response_BEGIN)
+				So(playerThree, shouldState, Response_BEGIN)
+				So(playerOne, shouldState, Response_START_TURN)
+				So(playerThree, shouldState, Response_STOP_TURN)
+
+				mustPress(playerOne, Color_GREEN)
+				So(playerOne, shouldLightup, Color_GREEN)
+				So(playerThree, shouldLightup, Color_GREEN)
+				So(playerOne, shouldState, Response_STOP_TURN)
+				So(playerThree, shouldState, Response_START_TURN)
+
+				mustPress(playerThree, Color_BLUE)
+				So(playerOne, shouldLightup, Color_BLUE)
+				So(playerThree, shouldLightup, Color_BLUE)
+				So(playerOne, shouldState, Response_WIN)
+				So(playerThree, shouldState, Response_LOSE)
+
+				playerTwo.Disconnect()
+				wg.Wait()
+			})
+		})
+	})
+}
+
 func shouldLightup(player interface{}, args ...interface{}) string {
 	res, err := player.(*mockStream).PullSend()
 	if err != nil {
@@ -0,0 +1,374 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	uuid "github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// defaultGracePeriod is how long a game waits for a player
+// whose stream has dropped to resume it.
+const defaultGracePeriod = 30 * time.Second
+
+// ErrSessionNotFound is returned when there is no game to resume with a token.
+var ErrSessionNotFound = errors.New("No game to resume with that token")
+
+// session is a player's place in a game, which can outlive the stream
+// it was started on. Once the player has been sent the resume token on BEGIN,
+// a dropped stream can be replaced by a new one presenting the token.
+// It passes everything through to the current stream, so it can be used
+// by the handlers like any other SimonSays_GameServer.
+type session struct {
+	token  string
+	player string
+	// lives as long as the session, rather than the stream.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// if the stream can be resumed when it drops.
+	grace bool
+
+	mu        sync.Mutex
+	stream    SimonSays_GameServer
+	resumable bool
+
+	// fires when the current stream drops.
+	dropped chan struct{}
+	// new streams that want to take over the session.
+	attaching chan SimonSays_GameServer
+	// fires when the stream has been swapped.
+	changed chan struct{}
+	done    chan struct{}
+	// what the game ended with, once done.
+	err error
+}
+
+// sessions are the sessions on this server, by their token.
+type sessions struct {
+	mu sync.Mutex
+	m  map[string]*session
+}
+
+// streamValues is a context with the values of the stream's
+// context, that isn't cancelled when the stream is.
+type streamValues struct {
+	context.Context
+}
+
+// Deadline returns that there is no deadline.
+func (streamValues) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// Done returns nil, as it is never done.
+func (streamValues) Done() <-chan struct{} { return nil }
+
+// Err returns nil, as it is never done.
+func (streamValues) Err() error { return nil }
+
+// newSession starts a session for the player on the stream, and keeps track of it
+// so it can be resumed. If the stream drops before the player has their resume token,
+// the session's context is cancelled.
+func (s *SimonSays) newSession(stream SimonSays_GameServer, player *Request_Player) (*session, error) {
+	u, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(streamValues{stream.Context()})
+	sess := &session{
+		token:     u.String(),
+		player:    player.Id,
+		ctx:       ctx,
+		cancel:    cancel,
+		grace:     s.GracePeriod > 0,
+		stream:    stream,
+		dropped:   make(chan struct{}, 1),
+		attaching: make(chan SimonSays_GameServer),
+		changed:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-stream.Context().Done():
+			if !sess.isResumable() {
+				cancel()
+			}
+		case <-ctx.Done():
+		}
+	}()
+
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	s.sessions.m[sess.token] = sess
+
+	return sess, nil
+}
+
+// closeSession finishes the session with the error the game ended with,
+// so it can no longer be resumed.
+func (s *SimonSays) closeSession(sess *session, err error) {
+	s.sessions.mu.Lock()
+	delete(s.sessions.m, sess.token)
+	s.sessions.mu.Unlock()
+
+	if sess.grace {
+		s.deleteSession(sess.ctx, sess)
+	}
+
+	sess.err = err
+	close(sess.done)
+	sess.cancel()
+}
+
+// findSession finds the player's session that can be resumed with the token.
+func (s *SimonSays) findSession(token string, player *Request_Player) (*session, error) {
+	s.sessions.mu.Lock()
+	sess, ok := s.sessions.m[token]
+	s.sessions.mu.Unlock()
+
+	if !ok || sess.player != player.Id || !sess.isResumable() {
+		return nil, ErrSessionNotFound
+	}
+
+	return sess, nil
+}
+
+// resume hands the stream over to the session the player's token is for. Sessions
+// running on other servers are relayed to, over the direct broker.
+// Blocks until the game is over, returning what it ended with, or the stream is done.
+func (s *SimonSays) resume(stream SimonSays_GameServer, player *Request_Player) error {
+	lc := "Resume"
+	ctx := stream.Context()
+
+	sess, err := s.findSession(player.Resume, player)
+	if err != nil {
+		logger.Info(ctx, lc, "Session is not on this server. %v", err)
+		return s.resumeRelay(stream, player)
+	}
+
+	logger.Info(ctx, lc, "Resuming game.")
+
+	select {
+	case sess.attaching <- stream:
+	case <-sess.done:
+		return ErrSessionNotFound
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-sess.done:
+		return sess.err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// resumeSession catches up the player on the new stream with the game, then
+// swaps it in as the session's stream, and lets the other players know.
+func (s *SimonSays) resumeSession(sess *session, stream SimonSays_GameServer, game *Game, player *Request_Player) error {
+	lc := "ResumeSession"
+	ctx := sess.Context()
+
+	res := turnResponse(game, player, Response_BEGIN)
+	res.Game = game.ID
+	res.Resume = sess.token
+	if err := sendResponse(stream, res); err != nil {
+		return err
+	}
+
+	if game.IsMyTurn() {
+		if err := playSequence(stream, game.ValidPresses(), 0); err != nil {
+			return err
+		}
+		if err := sendResponse(stream, turnResponse(game, player, Response_START_TURN)); err != nil {
+			return err
+		}
+		for _, c := range game.Presses() {
+			if err := sendResponse(stream, &Response{Event: &Response_Lightup{Lightup: c}}); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := playSequence(stream, game.TurnSequence(), 0); err != nil {
+			return err
+		}
+		if err := sendResponse(stream, turnResponse(game, player, Response_STOP_TURN)); err != nil {
+			return err
+		}
+	}
+
+	sess.setStream(stream)
+	logger.Info(ctx, lc, "Player has resumed the game.")
+
+	return publish(ctx, s.broker, game, message{Type: reconnectedMessage, Player: player.Id})
+}
+
+// forfeit lets everyone know the player has lost, as they didn't
+// resume the game in time for their turn.
+func forfeit(stream SimonSays_GameServer, broker Broker, game *Game, player *Request_Player) error {
+	lc := "Forfeit"
+	ctx := stream.Context()
+
+	logger.Info(ctx, lc, "Player did not resume in time for their turn. They have lost.")
+
+	return publishLost(ctx, broker, game, player, Elimination_FORFEIT)
+}
+
+// isResumable returns if the player has been sent the resume token.
+func (s *session) isResumable() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resumable
+}
+
+// current returns the current stream, or nil if it has dropped.
+func (s *session) current() SimonSays_GameServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream
+}
+
+// setStream swaps in a new stream for the session.
+func (s *session) setStream(stream SimonSays_GameServer) {
+	s.mu.Lock()
+	s.stream = stream
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// drop marks the stream as dropped, if it is still the current one.
+func (s *session) drop(stream SimonSays_GameServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream != stream {
+		return
+	}
+	s.stream = nil
+
+	select {
+	case s.dropped <- struct{}{}:
+	default:
+	}
+}
+
+// Send sends the response down the current stream. The resume token is added to BEGIN,
+// and from then on, responses sent while the stream has dropped are discarded.
+func (s *session) Send(res *Response) error {
+	s.mu.Lock()
+	if turn, ok := res.Event.(*Response_Turn); ok && turn.Turn == Response_BEGIN && s.grace {
+		r := *res
+		r.Resume = s.token
+		res = &r
+		s.resumable = true
+	}
+	stream, resumable := s.stream, s.resumable
+	s.mu.Unlock()
+
+	if stream == nil {
+		return nil
+	}
+
+	err := stream.Send(res)
+	if err != nil && resumable {
+		logger.Warn(s.ctx, "Session", "Could not send response. The stream has probably dropped. %v", err)
+		return nil
+	}
+
+	return err
+}
+
+// Recv receives a request from the current stream. If the stream drops once the
+// session is resumable, waits for it to be resumed, and carries on with the new stream.
+// Returns io.EOF if the session finishes in the meantime.
+func (s *session) Recv() (*Request, error) {
+	for {
+		if stream := s.current(); stream != nil {
+			req, err := stream.Recv()
+			if err == nil {
+				return req, nil
+			}
+			if !s.isResumable() {
+				return nil, err
+			}
+
+			logger.Info(s.ctx, "Session", "Stream has dropped. %v", err)
+			s.drop(stream)
+		}
+
+		select {
+		case <-s.changed:
+		case <-s.done:
+			return nil, io.EOF
+		}
+	}
+}
+
+// Context returns the context of the session, which outlives its streams.
+func (s *session) Context() context.Context {
+	return s.ctx
+}
+
+// SetHeader sets the header metadata on the current stream.
+func (s *session) SetHeader(md metadata.MD) error {
+	if stream := s.current(); stream != nil {
+		return stream.SetHeader(md)
+	}
+	return nil
+}
+
+// SendHeader sends the header metadata on the current stream.
+func (s *session) SendHeader(md metadata.MD) error {
+	if stream := s.current(); stream != nil {
+		return stream.SendHeader(md)
+	}
+	return nil
+}
+
+// SetTrailer sets the trailer metadata on the current stream.
+func (s *session) SetTrailer(md metadata.MD) {
+	if stream := s.current(); stream != nil {
+		stream.SetTrailer(md)
+	}
+}
+
+// SendMsg sends a message on the current stream.
+func (s *session) SendMsg(m interface{}) error {
+	if stream := s.current(); stream != nil {
+		return stream.SendMsg(m)
+	}
+	return nil
+}
+
+// RecvMsg receives a message on the current stream.
+func (s *session) RecvMsg(m interface{}) error {
+	if stream := s.current(); stream != nil {
+		return stream.RecvMsg(m)
+	}
+	return io.EOF
+}
@@ -0,0 +1,167 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestResumeGame tests that a player can pick up a game
+// on a new stream, after their stream has dropped.
+func TestResumeGame(t *testing.T) {
+	playerOne := newMockStream()
+	playerTwo := newMockStream()
+	resumed := newMockStream()
+
+	Convey("Given a game between two players", t, func(c C) {
+		game := mustSimonSays()
+		defer game.Close()
+		wg := sync.WaitGroup{}
+
+		tokens := startResumableGame(c, game, &wg, playerOne, playerTwo)
+
+		mustPress(playerOne, Color_GREEN)
+		So(playerOne, shouldLightup, Color_GREEN)
+		So(playerTwo, shouldLightup, Color_GREEN)
+		So(playerOne, shouldState, Response_STOP_TURN)
+		So(playerTwo, shouldState, Response_START_TURN)
+
+		Convey("When player two's stream drops, player one is told they are reconnecting", func() {
+			playerTwo.Disconnect()
+			So(playerOne, shouldPlayerState, Response_RECONNECTING, "Player Two")
+
+			Convey("And player two can resume the game where they left off", func() {
+				err := resumed.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player Two", Resume: tokens[1]}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.So(game.Game(resumed), ShouldBeNil)
+				}()
+
+				res, err := resumed.PullSend()
+				So(err, ShouldBeNil)
+				So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+				So(res.Resume, ShouldEqual, tokens[1])
+
+				res, err = resumed.PullSend()
+				So(err, ShouldBeNil)
+				So(res.GetLightup(), ShouldEqual, Color_GREEN)
+				So(res.Playback, ShouldBeTrue)
+				So(resumed, shouldState, Response_START_TURN)
+
+				So(playerOne, shouldPlayerState, Response_RECONNECTED, "Player Two")
+
+				mustPress(resumed, Color_RED)
+				So(playerOne, shouldLightup, Color_RED)
+				So(resumed, shouldLightup, Color_RED)
+				So(playerOne, shouldState, Response_WIN)
+				So(resumed, shouldState, Response_LOSE)
+
+				wg.Wait()
+			})
+		})
+	})
+}
+
+// TestResumeTooLate tests that a player that doesn't resume
+// in time loses when their turn comes around.
+func TestResumeTooLate(t *testing.T) {
+	playerOne := newMockStream()
+	playerTwo := newMockStream()
+
+	Convey("Given a game between two players, with a short grace period", t, func(c C) {
+		game := mustSimonSays()
+		defer game.Close()
+		game.GracePeriod = 200 * time.Millisecond
+		wg := sync.WaitGroup{}
+
+		startResumableGame(c, game, &wg, playerOne, playerTwo)
+
+		Convey("When player two doesn't come back in time, player one wins on player two's turn", func() {
+			playerTwo.Disconnect()
+			So(playerOne, shouldPlayerState, Response_RECONNECTING, "Player Two")
+			time.Sleep(500 * time.Millisecond)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerOne, shouldState, Response_WIN)
+
+			wg.Wait()
+		})
+	})
+}
+
+// TestResumeUnknownToken tests that a token without a game to resume is rejected.
+func TestResumeUnknownToken(t *testing.T) {
+	stream := newMockStream()
+
+	Convey("When a player tries to resume with a token that isn't for a game", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+
+		err := stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Resume: "not a token"}}})
+		So(err, ShouldBeNil)
+
+		So(grpc.Code(game.Game(stream)), ShouldEqual, codes.NotFound)
+		So(stream, shouldError, Error_NOT_FOUND)
+	})
+}
+
+// startResumableGame starts a game between the two players, and returns
+// their resume tokens, once it is player one's turn.
+func startResumableGame(c C, game *SimonSays, wg *sync.WaitGroup, playerOne, playerTwo *mockStream) []string {
+	con := game.pool.Get()
+	defer con.Close()
+	_, err := con.Do("FLUSHDB")
+	So(err, ShouldBeNil)
+
+	var tokens []string
+	for i, p := range []*mockStream{playerOne, playerTwo} {
+		id := []string{"Player One", "Player Two"}[i]
+		err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: id}}})
+		So(err, ShouldBeNil)
+
+		wg.Add(1)
+		go func(p *mockStream) {
+			defer wg.Done()
+			c.So(game.Game(p), ShouldBeNil)
+		}(p)
+
+		time.Sleep(time.Second)
+	}
+
+	for _, p := range []*mockStream{playerOne, playerTwo} {
+		res, err := p.PullSend()
+		So(err, ShouldBeNil)
+		So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+		So(res.Resume, ShouldNotBeEmpty)
+		tokens = append(tokens, res.Resume)
+	}
+
+	So(playerOne, shouldState, Response_START_TURN)
+	So(playerTwo, shouldState, Response_STOP_TURN)
+
+	return tokens
+}
@@ -0,0 +1,119 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// DefaultDrainTimeout is how long running games have to
+// finish when the server shuts down, by default.
+const DefaultDrainTimeout = 30 * time.Second
+
+// drainInterval is how often to check if every game has finished, when shutting down.
+const drainInterval = 100 * time.Millisecond
+
+// stopTimeout is how long to wait for the games that are ended on shutdown to finish up.
+const stopTimeout = 5 * time.Second
+
+// ErrShuttingDown is returned when a game can't be played, because the server is shutting down.
+var ErrShuttingDown = errors.New("Server is shutting down")
+
+// Shutdown stops the server taking new games, and ends any games that are still waiting
+// for players, taking them out of the open games. Running games have until the timeout
+// to finish, after which the players left are told the server is shutting down.
+// Blocks until every stream has finished. Should only be called once.
+func (s *SimonSays) Shutdown(timeout time.Duration) {
+	log.Printf("[Info][Server] Draining games, for up to %v.", timeout)
+	close(s.draining)
+
+	if s.waitIdle(timeout) {
+		log.Printf("[Info][Server] All games have finished.")
+		return
+	}
+
+	log.Printf("[Info][Server] Ending the %v streams that are left.", atomic.LoadInt64(&s.active))
+	close(s.stopping)
+
+	if !s.waitIdle(stopTimeout) {
+		log.Printf("[Warn][Server] %v streams are still going.", atomic.LoadInt64(&s.active))
+	}
+}
+
+// waitIdle waits for every stream to finish, until the timeout.
+// Returns if they all finished.
+func (s *SimonSays) waitIdle(timeout time.Duration) bool {
+	deadline := time.After(timeout)
+
+	for atomic.LoadInt64(&s.active) > 0 {
+		select {
+		case <-time.After(drainInterval):
+		case <-deadline:
+			return false
+		}
+	}
+
+	return true
+}
+
+// track counts a stream as active until the returned function is called.
+func (s *SimonSays) track() func() {
+	atomic.AddInt64(&s.active, 1)
+	return func() { atomic.AddInt64(&s.active, -1) }
+}
+
+// isDraining returns if the server has stopped taking new games.
+func (s *SimonSays) isDraining() bool {
+	select {
+	case <-s.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// isWaiting returns if the game is still waiting for players, rather than
+// about to begin, or running.
+func isWaiting(ctx context.Context, con redis.Conn, game *Game) (bool, error) {
+	if len(game.Players()) > 0 {
+		return false, nil
+	}
+
+	players, err := listPlayers(ctx, con, game)
+	if err != nil {
+		return false, err
+	}
+
+	return len(players) < game.Size, nil
+}
+
+// abandonGame lets the other players know the player has left the game,
+// as the server is shutting down.
+func abandonGame(stream SimonSays_GameServer, broker Broker, game *Game, player *Request_Player) error {
+	lc := "AbandonGame"
+	ctx := stream.Context()
+
+	logger.Info(ctx, lc, "Server is shutting down. Leaving the game.")
+
+	return publishLost(ctx, broker, game, player, Elimination_SHUTDOWN)
+}
@@ -0,0 +1,146 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestShutdownWaitingGame tests that games waiting for players
+// are closed when the server shuts down.
+func TestShutdownWaitingGame(t *testing.T) {
+	Convey("Given a SimonSays with a player waiting for a game", t, func(c C) {
+		game := mustSimonSays()
+		defer game.Close()
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		p := newMockStream()
+		err = p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Lonely"}}})
+		So(err, ShouldBeNil)
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.So(grpc.Code(game.Game(p)), ShouldEqual, codes.Unavailable)
+		}()
+
+		time.Sleep(time.Second)
+		n, err := redis.Int(con.Do("ZCARD", openGamesKey("", minPlayers)))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 1)
+
+		Convey("When it shuts down, the game is closed, and the player told why", func() {
+			start := time.Now()
+			game.Shutdown(time.Minute)
+			So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+
+			wg.Wait()
+			So(p, shouldError, Error_SHUTDOWN)
+
+			n, err := redis.Int(con.Do("ZCARD", openGamesKey("", minPlayers)))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			Convey("And new players are turned away", func() {
+				p := newMockStream()
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Latecomer"}}})
+				So(err, ShouldBeNil)
+				So(grpc.Code(game.Game(p)), ShouldEqual, codes.Unavailable)
+				So(p, shouldError, Error_SHUTDOWN)
+			})
+		})
+	})
+}
+
+// TestShutdownRunningGame tests that running games can finish
+// when the server shuts down, until they run out of time.
+func TestShutdownRunningGame(t *testing.T) {
+	Convey("Given two players on different servers, in a running game", t, func(c C) {
+		one := mustSimonSays()
+		defer one.Close()
+		two := mustSimonSays()
+		defer two.Close()
+
+		con := one.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		playerOne := newMockStream()
+		playerTwo := newMockStream()
+		wg := sync.WaitGroup{}
+
+		for i, p := range []*mockStream{playerOne, playerTwo} {
+			id := []string{"Player One", "Player Two"}[i]
+			server := []*SimonSays{one, two}[i]
+
+			err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: id}}})
+			So(err, ShouldBeNil)
+
+			wg.Add(1)
+			go func(p *mockStream) {
+				defer wg.Done()
+				err := server.Game(p)
+				if server == one {
+					c.So(grpc.Code(err), ShouldEqual, codes.Unavailable)
+				} else {
+					c.So(err, ShouldBeNil)
+				}
+			}(p)
+
+			time.Sleep(time.Second)
+		}
+
+		So(playerOne, shouldState, Response_BEGIN)
+		So(playerTwo, shouldState, Response_BEGIN)
+		So(playerOne, shouldState, Response_START_TURN)
+		So(playerTwo, shouldState, Response_STOP_TURN)
+
+		Convey("When player one's server shuts down, the game carries on until the drain timeout", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				one.Shutdown(500 * time.Millisecond)
+			}()
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			Convey("Then player one is told the server is shutting down, and player two wins", func() {
+				So(playerOne, shouldError, Error_SHUTDOWN)
+				So(playerTwo, shouldState, Response_WIN)
+
+				wg.Wait()
+				<-done
+			})
+		})
+	})
+}
@@ -13,19 +13,34 @@ See the License for the specific language governing permissions and
 limitations under the License.
 ==============================================================================*/
 
-// Code generated by protoc-gen-go.
+// Code generated by protoc-gen-go. DO NOT EDIT.
 // source: simonsays.proto
-// DO NOT EDIT!
 
 /*
 Package simonsays is a generated protocol buffer package.
 
 It is generated from these files:
+
 	simonsays.proto
 
 It has these top-level messages:
+
 	Request
 	Response
+	Error
+	SpectateRequest
+	RatingRequest
+	Rating
+	GamesRequest
+	GameList
+	GameRequest
+	ReplayRequest
+	LeaderboardRequest
+	Leaderboard
+	GameRecord
+	Elimination
+	Event
+	Relay
 */
 package simonsays
 
@@ -76,6 +91,30 @@ func (x Color) String() string {
 }
 func (Color) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+// The kind of game to play.
+type Request_Mode int32
+
+const (
+	// Play against other players, each adding a colour to the sequence.
+	Request_VERSUS Request_Mode = 0
+	// Play against the server, repeating the sequence it plays.
+	Request_SOLO Request_Mode = 1
+)
+
+var Request_Mode_name = map[int32]string{
+	0: "VERSUS",
+	1: "SOLO",
+}
+var Request_Mode_value = map[string]int32{
+	"VERSUS": 0,
+	"SOLO":   1,
+}
+
+func (x Request_Mode) String() string {
+	return proto.EnumName(Request_Mode_name, int32(x))
+}
+func (Request_Mode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
+
 type Response_State int32
 
 const (
@@ -84,6 +123,10 @@ const (
 	Response_STOP_TURN  Response_State = 2
 	Response_WIN        Response_State = 3
 	Response_LOSE       Response_State = 4
+	// The player's stream has dropped, and the game is waiting for them to resume.
+	Response_RECONNECTING Response_State = 5
+	// The player has resumed the game.
+	Response_RECONNECTED Response_State = 6
 )
 
 var Response_State_name = map[int32]string{
@@ -92,13 +135,17 @@ var Response_State_name = map[int32]string{
 	2: "STOP_TURN",
 	3: "WIN",
 	4: "LOSE",
+	5: "RECONNECTING",
+	6: "RECONNECTED",
 }
 var Response_State_value = map[string]int32{
-	"BEGIN":      0,
-	"START_TURN": 1,
-	"STOP_TURN":  2,
-	"WIN":        3,
-	"LOSE":       4,
+	"BEGIN":        0,
+	"START_TURN":   1,
+	"STOP_TURN":    2,
+	"WIN":          3,
+	"LOSE":         4,
+	"RECONNECTING": 5,
+	"RECONNECTED":  6,
 }
 
 func (x Response_State) String() string {
@@ -106,6 +153,205 @@ func (x Response_State) String() string {
 }
 func (Response_State) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1, 0} }
 
+type Error_Reason int32
+
+const (
+	// Something went wrong on the server. INTERNAL.
+	Error_UNKNOWN Error_Reason = 0
+	// The request broke the protocol, and shouldn't be sent again as it is. INVALID_ARGUMENT.
+	Error_INVALID_REQUEST Error_Reason = 1
+	// The server can't reach what it needs to run games. Try again later. UNAVAILABLE.
+	Error_UNAVAILABLE Error_Reason = 2
+	// Something didn't happen in time, like a private room filling up. DEADLINE_EXCEEDED.
+	Error_TIMEOUT Error_Reason = 3
+	// The game fell through, like the room filling up first. Join again. ABORTED.
+	Error_ABORTED Error_Reason = 4
+	// There is no such room or game, or nothing to resume with the token. NOT_FOUND.
+	Error_NOT_FOUND Error_Reason = 5
+	// The server is shutting down. Join again to play on another one. UNAVAILABLE.
+	Error_SHUTDOWN Error_Reason = 6
+	// The player id is not the one the bearer token was issued to. PERMISSION_DENIED.
+	Error_PERMISSION_DENIED Error_Reason = 7
+	// The player already has a game going, and the server turns away another one. ALREADY_EXISTS.
+	Error_DUPLICATE_SESSION Error_Reason = 8
+	// The player joined again from somewhere else, which ended this game. ABORTED.
+	Error_REPLACED Error_Reason = 9
+	// The player, or their address, joined or pressed too often. RESOURCE_EXHAUSTED.
+	Error_RATE_LIMITED Error_Reason = 10
+)
+
+var Error_Reason_name = map[int32]string{
+	0:  "UNKNOWN",
+	1:  "INVALID_REQUEST",
+	2:  "UNAVAILABLE",
+	3:  "TIMEOUT",
+	4:  "ABORTED",
+	5:  "NOT_FOUND",
+	6:  "SHUTDOWN",
+	7:  "PERMISSION_DENIED",
+	8:  "DUPLICATE_SESSION",
+	9:  "REPLACED",
+	10: "RATE_LIMITED",
+}
+var Error_Reason_value = map[string]int32{
+	"UNKNOWN":           0,
+	"INVALID_REQUEST":   1,
+	"UNAVAILABLE":       2,
+	"TIMEOUT":           3,
+	"ABORTED":           4,
+	"NOT_FOUND":         5,
+	"SHUTDOWN":          6,
+	"PERMISSION_DENIED": 7,
+	"DUPLICATE_SESSION": 8,
+	"REPLACED":          9,
+	"RATE_LIMITED":      10,
+}
+
+func (x Error_Reason) String() string {
+	return proto.EnumName(Error_Reason_name, int32(x))
+}
+func (Error_Reason) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+
+// What players are ranked by.
+type Leaderboard_Board int32
+
+const (
+	// The games they have won.
+	Leaderboard_WINS Leaderboard_Board = 0
+	// The most games they have won in a row.
+	Leaderboard_STREAK Leaderboard_Board = 1
+	// The longest sequence they repeated, when they lost.
+	Leaderboard_SEQUENCE Leaderboard_Board = 2
+)
+
+var Leaderboard_Board_name = map[int32]string{
+	0: "WINS",
+	1: "STREAK",
+	2: "SEQUENCE",
+}
+var Leaderboard_Board_value = map[string]int32{
+	"WINS":     0,
+	"STREAK":   1,
+	"SEQUENCE": 2,
+}
+
+func (x Leaderboard_Board) String() string {
+	return proto.EnumName(Leaderboard_Board_name, int32(x))
+}
+func (Leaderboard_Board) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 0} }
+
+// When the games counted were played.
+type Leaderboard_Window int32
+
+const (
+	Leaderboard_ALL_TIME Leaderboard_Window = 0
+	// Today, in UTC.
+	Leaderboard_DAILY Leaderboard_Window = 1
+	// This week, starting on Monday, in UTC.
+	Leaderboard_WEEKLY Leaderboard_Window = 2
+)
+
+var Leaderboard_Window_name = map[int32]string{
+	0: "ALL_TIME",
+	1: "DAILY",
+	2: "WEEKLY",
+}
+var Leaderboard_Window_value = map[string]int32{
+	"ALL_TIME": 0,
+	"DAILY":    1,
+	"WEEKLY":   2,
+}
+
+func (x Leaderboard_Window) String() string {
+	return proto.EnumName(Leaderboard_Window_name, int32(x))
+}
+func (Leaderboard_Window) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 1} }
+
+// Why the player lost.
+type Elimination_Reason int32
+
+const (
+	Elimination_UNKNOWN Elimination_Reason = 0
+	// They pressed the wrong colour.
+	Elimination_WRONG_COLOR Elimination_Reason = 1
+	// They ran out of time.
+	Elimination_TIMEOUT Elimination_Reason = 2
+	// Their stream dropped, and they didn't resume in time.
+	Elimination_FORFEIT Elimination_Reason = 3
+	// Their server shut down before the game finished.
+	Elimination_SHUTDOWN Elimination_Reason = 4
+	// They sent something that broke the protocol, like a colour that isn't on the board.
+	Elimination_INVALID_REQUEST Elimination_Reason = 5
+	// They pressed faster than the server allows.
+	Elimination_RATE_LIMITED Elimination_Reason = 6
+)
+
+var Elimination_Reason_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "WRONG_COLOR",
+	2: "TIMEOUT",
+	3: "FORFEIT",
+	4: "SHUTDOWN",
+	5: "INVALID_REQUEST",
+	6: "RATE_LIMITED",
+}
+var Elimination_Reason_value = map[string]int32{
+	"UNKNOWN":         0,
+	"WRONG_COLOR":     1,
+	"TIMEOUT":         2,
+	"FORFEIT":         3,
+	"SHUTDOWN":        4,
+	"INVALID_REQUEST": 5,
+	"RATE_LIMITED":    6,
+}
+
+func (x Elimination_Reason) String() string {
+	return proto.EnumName(Elimination_Reason_name, int32(x))
+}
+func (Elimination_Reason) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{13, 0} }
+
+type Event_Type int32
+
+const (
+	Event_UNKNOWN Event_Type = 0
+	// The game has begun.
+	Event_BEGIN Event_Type = 1
+	// The player's turn has finished.
+	Event_STOP_TURN Event_Type = 2
+	// The player pressed a colour.
+	Event_LIGHTUP Event_Type = 3
+	// The player has lost.
+	Event_LOST Event_Type = 4
+	// The player's stream has dropped.
+	Event_RECONNECTING Event_Type = 5
+	// The player has resumed the game.
+	Event_RECONNECTED Event_Type = 6
+)
+
+var Event_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "BEGIN",
+	2: "STOP_TURN",
+	3: "LIGHTUP",
+	4: "LOST",
+	5: "RECONNECTING",
+	6: "RECONNECTED",
+}
+var Event_Type_value = map[string]int32{
+	"UNKNOWN":      0,
+	"BEGIN":        1,
+	"STOP_TURN":    2,
+	"LIGHTUP":      3,
+	"LOST":         4,
+	"RECONNECTING": 5,
+	"RECONNECTED":  6,
+}
+
+func (x Event_Type) String() string {
+	return proto.EnumName(Event_Type_name, int32(x))
+}
+func (Event_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{14, 0} }
+
 type Request struct {
 	// Types that are valid to be assigned to Event:
 	//	*Request_Join
@@ -118,9 +364,7 @@ func (m *Request) String() string            { return proto.CompactTextString(m)
 func (*Request) ProtoMessage()               {}
 func (*Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
-type isRequest_Event interface {
-	isRequest_Event()
-}
+type isRequest_Event interface{ isRequest_Event() }
 
 type Request_Join struct {
 	Join *Request_Player `protobuf:"bytes,1,opt,name=join,oneof"`
@@ -224,7 +468,23 @@ func _Request_OneofSizer(msg proto.Message) (n int) {
 
 // A Player of the Simon says game.
 type Request_Player struct {
+	// Who you are. Can be left out if a bearer token is sent. Up to 64 bytes of
+	// letters, digits, spaces and "-_.@", not starting or ending with a space.
 	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// The number of players in the game to join, between 2 and 8.
+	// Defaults to 2.
+	Players int32 `protobuf:"varint,2,opt,name=players" json:"players,omitempty"`
+	// The kind of game to play. Defaults to VERSUS.
+	Mode Request_Mode `protobuf:"varint,3,opt,name=mode,enum=simonsays.Request_Mode" json:"mode,omitempty"`
+	// Play back the whole sequence at the start of each of your turns.
+	Replay bool `protobuf:"varint,4,opt,name=replay" json:"replay,omitempty"`
+	// Create a private room, that can only be joined with its code.
+	Private bool `protobuf:"varint,5,opt,name=private" json:"private,omitempty"`
+	// The code of the private room to join.
+	Room string `protobuf:"bytes,6,opt,name=room" json:"room,omitempty"`
+	// The resume token from BEGIN, to pick up a game after the stream dropped.
+	// Everything else is ignored when it is set.
+	Resume string `protobuf:"bytes,7,opt,name=resume" json:"resume,omitempty"`
 }
 
 func (m *Request_Player) Reset()                    { *m = Request_Player{} }
@@ -232,11 +492,88 @@ func (m *Request_Player) String() string            { return proto.CompactTextSt
 func (*Request_Player) ProtoMessage()               {}
 func (*Request_Player) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
 
+func (m *Request_Player) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Request_Player) GetPlayers() int32 {
+	if m != nil {
+		return m.Players
+	}
+	return 0
+}
+
+func (m *Request_Player) GetMode() Request_Mode {
+	if m != nil {
+		return m.Mode
+	}
+	return Request_VERSUS
+}
+
+func (m *Request_Player) GetReplay() bool {
+	if m != nil {
+		return m.Replay
+	}
+	return false
+}
+
+func (m *Request_Player) GetPrivate() bool {
+	if m != nil {
+		return m.Private
+	}
+	return false
+}
+
+func (m *Request_Player) GetRoom() string {
+	if m != nil {
+		return m.Room
+	}
+	return ""
+}
+
+func (m *Request_Player) GetResume() string {
+	if m != nil {
+		return m.Resume
+	}
+	return ""
+}
+
 type Response struct {
 	// Types that are valid to be assigned to Event:
 	//	*Response_Turn
 	//	*Response_Lightup
+	//	*Response_Error
 	Event isResponse_Event `protobuf_oneof:"event"`
+	// When the server has turn deadlines, the time the player must press their
+	// next colour by, in milliseconds since the Unix epoch. Set on START_TURN.
+	// On STOP_TURN, it's when the player whose turn it is has to press their first colour by.
+	// That doesn't count the sequence being played back to them, if they joined with replay set,
+	// as their clock only starts once it has finished, so they may have longer.
+	Deadline int64 `protobuf:"varint,3,opt,name=deadline" json:"deadline,omitempty"`
+	// Set on a lightup when it is the server playing back the sequence,
+	// rather than a colour a player has just pressed.
+	Playback bool `protobuf:"varint,4,opt,name=playback" json:"playback,omitempty"`
+	// When spectating, or on RECONNECTING and RECONNECTED,
+	// the id of the player the event is about.
+	Player string `protobuf:"bytes,5,opt,name=player" json:"player,omitempty"`
+	// The id of the game. Set on BEGIN.
+	Game string `protobuf:"bytes,6,opt,name=game" json:"game,omitempty"`
+	// The code of a newly created private room, for friends to join with.
+	// Sent on its own, before BEGIN.
+	Room string `protobuf:"bytes,7,opt,name=room" json:"room,omitempty"`
+	// The token to resume the game with, if the stream drops. Set on BEGIN.
+	Resume string `protobuf:"bytes,8,opt,name=resume" json:"resume,omitempty"`
+	// The round the game is on, counting up from 1 with each turn.
+	Round int32 `protobuf:"varint,10,opt,name=round" json:"round,omitempty"`
+	// How many colours the player whose turn it is has to press.
+	Length int32 `protobuf:"varint,11,opt,name=length" json:"length,omitempty"`
+	// The id of the player whose turn it is.
+	Current string `protobuf:"bytes,12,opt,name=current" json:"current,omitempty"`
+	// The ids of the other players still in the game, in turn order.
+	Opponents []string `protobuf:"bytes,13,rep,name=opponents" json:"opponents,omitempty"`
 }
 
 func (m *Response) Reset()                    { *m = Response{} }
@@ -244,9 +581,7 @@ func (m *Response) String() string            { return proto.CompactTextString(m
 func (*Response) ProtoMessage()               {}
 func (*Response) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
-type isResponse_Event interface {
-	isResponse_Event()
-}
+type isResponse_Event interface{ isResponse_Event() }
 
 type Response_Turn struct {
 	Turn Response_State `protobuf:"varint,1,opt,name=turn,enum=simonsays.Response_State,oneof"`
@@ -254,9 +589,13 @@ type Response_Turn struct {
 type Response_Lightup struct {
 	Lightup Color `protobuf:"varint,2,opt,name=lightup,enum=simonsays.Color,oneof"`
 }
+type Response_Error struct {
+	Error *Error `protobuf:"bytes,9,opt,name=error,oneof"`
+}
 
 func (*Response_Turn) isResponse_Event()    {}
 func (*Response_Lightup) isResponse_Event() {}
+func (*Response_Error) isResponse_Event()   {}
 
 func (m *Response) GetEvent() isResponse_Event {
 	if m != nil {
@@ -279,11 +618,89 @@ func (m *Response) GetLightup() Color {
 	return Color_RED
 }
 
+func (m *Response) GetError() *Error {
+	if x, ok := m.GetEvent().(*Response_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+func (m *Response) GetDeadline() int64 {
+	if m != nil {
+		return m.Deadline
+	}
+	return 0
+}
+
+func (m *Response) GetPlayback() bool {
+	if m != nil {
+		return m.Playback
+	}
+	return false
+}
+
+func (m *Response) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+func (m *Response) GetGame() string {
+	if m != nil {
+		return m.Game
+	}
+	return ""
+}
+
+func (m *Response) GetRoom() string {
+	if m != nil {
+		return m.Room
+	}
+	return ""
+}
+
+func (m *Response) GetResume() string {
+	if m != nil {
+		return m.Resume
+	}
+	return ""
+}
+
+func (m *Response) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *Response) GetLength() int32 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
+func (m *Response) GetCurrent() string {
+	if m != nil {
+		return m.Current
+	}
+	return ""
+}
+
+func (m *Response) GetOpponents() []string {
+	if m != nil {
+		return m.Opponents
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Response) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _Response_OneofMarshaler, _Response_OneofUnmarshaler, _Response_OneofSizer, []interface{}{
 		(*Response_Turn)(nil),
 		(*Response_Lightup)(nil),
+		(*Response_Error)(nil),
 	}
 }
 
@@ -297,6 +714,11 @@ func _Response_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 	case *Response_Lightup:
 		b.EncodeVarint(2<<3 | proto.WireVarint)
 		b.EncodeVarint(uint64(x.Lightup))
+	case *Response_Error:
+		b.EncodeVarint(9<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Error); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Response.Event has unexpected type %T", x)
@@ -321,6 +743,14 @@ func _Response_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffe
 		x, err := b.DecodeVarint()
 		m.Event = &Response_Lightup{Color(x)}
 		return true, err
+	case 9: // event.error
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Error)
+		err := b.DecodeMessage(msg)
+		m.Event = &Response_Error{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -336,6 +766,11 @@ func _Response_OneofSizer(msg proto.Message) (n int) {
 	case *Response_Lightup:
 		n += proto.SizeVarint(2<<3 | proto.WireVarint)
 		n += proto.SizeVarint(uint64(x.Lightup))
+	case *Response_Error:
+		s := proto.Size(x.Error)
+		n += proto.SizeVarint(9<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -343,129 +778,1002 @@ func _Response_OneofSizer(msg proto.Message) (n int) {
 	return n
 }
 
-func init() {
-	proto.RegisterType((*Request)(nil), "simonsays.Request")
-	proto.RegisterType((*Request_Player)(nil), "simonsays.Request.Player")
-	proto.RegisterType((*Response)(nil), "simonsays.Response")
-	proto.RegisterEnum("simonsays.Color", Color_name, Color_value)
-	proto.RegisterEnum("simonsays.Response_State", Response_State_name, Response_State_value)
+// Error says why a game has ended early. The stream then ends with
+// the gRPC status code given for the reason.
+type Error struct {
+	Reason Error_Reason `protobuf:"varint,1,opt,name=reason,enum=simonsays.Error_Reason" json:"reason,omitempty"`
+	// What went wrong, for people rather than code.
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// Client API for SimonSays service
+func (m *Error) Reset()                    { *m = Error{} }
+func (m *Error) String() string            { return proto.CompactTextString(m) }
+func (*Error) ProtoMessage()               {}
+func (*Error) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
-type SimonSaysClient interface {
-	//
-	// Game process is the following:
-	//
-	// A Join Request should be sent to the game. This tells it
-	// to join a game (or start a new one if one isn't already waiting on a game).
-	//
-	// The Response stream will send through a BEGIN Response.State to let you know that
-	// the Game has been started.
-	//
-	// When the player recieves a START_TURN response, the server can take your input for the turn.
-	//
-	// When the player recieves a STOP_TURN response, the serer is no longer taking input for the turn.
-	//
-	// A WIN state says you won the game. A LOSE state means that you got an input wrong, and have lost.
-	//
-	// To send input, send a Request with an event type of Color.
-	//
-	// When you recieve a Response of type Color, then light up that colour.
-	Game(ctx context.Context, opts ...grpc.CallOption) (SimonSays_GameClient, error)
+func (m *Error) GetReason() Error_Reason {
+	if m != nil {
+		return m.Reason
+	}
+	return Error_UNKNOWN
 }
 
-type simonSaysClient struct {
-	cc *grpc.ClientConn
+func (m *Error) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
 }
 
-func NewSimonSaysClient(cc *grpc.ClientConn) SimonSaysClient {
-	return &simonSaysClient{cc}
+type SpectateRequest struct {
+	// The id of the game to watch.
+	Game string `protobuf:"bytes,1,opt,name=game" json:"game,omitempty"`
 }
 
-func (c *simonSaysClient) Game(ctx context.Context, opts ...grpc.CallOption) (SimonSays_GameClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_SimonSays_serviceDesc.Streams[0], c.cc, "/simonsays.SimonSays/Game", opts...)
-	if err != nil {
-		return nil, err
+func (m *SpectateRequest) Reset()                    { *m = SpectateRequest{} }
+func (m *SpectateRequest) String() string            { return proto.CompactTextString(m) }
+func (*SpectateRequest) ProtoMessage()               {}
+func (*SpectateRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *SpectateRequest) GetGame() string {
+	if m != nil {
+		return m.Game
 	}
-	x := &simonSaysGameClient{stream}
-	return x, nil
+	return ""
 }
 
-type SimonSays_GameClient interface {
-	Send(*Request) error
-	Recv() (*Response, error)
-	grpc.ClientStream
+type RatingRequest struct {
+	// The id of the player.
+	Player string `protobuf:"bytes,1,opt,name=player" json:"player,omitempty"`
 }
 
-type simonSaysGameClient struct {
-	grpc.ClientStream
+func (m *RatingRequest) Reset()                    { *m = RatingRequest{} }
+func (m *RatingRequest) String() string            { return proto.CompactTextString(m) }
+func (*RatingRequest) ProtoMessage()               {}
+func (*RatingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *RatingRequest) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
 }
 
-func (x *simonSaysGameClient) Send(m *Request) error {
-	return x.ClientStream.SendMsg(m)
+type Rating struct {
+	// The id of the player.
+	Player string `protobuf:"bytes,1,opt,name=player" json:"player,omitempty"`
+	// The player's Elo rating.
+	Rating int32 `protobuf:"varint,2,opt,name=rating" json:"rating,omitempty"`
 }
 
-func (x *simonSaysGameClient) Recv() (*Response, error) {
-	m := new(Response)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *Rating) Reset()                    { *m = Rating{} }
+func (m *Rating) String() string            { return proto.CompactTextString(m) }
+func (*Rating) ProtoMessage()               {}
+func (*Rating) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *Rating) GetPlayer() string {
+	if m != nil {
+		return m.Player
 	}
-	return m, nil
+	return ""
 }
 
-// Server API for SimonSays service
+func (m *Rating) GetRating() int32 {
+	if m != nil {
+		return m.Rating
+	}
+	return 0
+}
 
-type SimonSaysServer interface {
-	//
-	// Game process is the following:
-	//
-	// A Join Request should be sent to the game. This tells it
-	// to join a game (or start a new one if one isn't already waiting on a game).
-	//
-	// The Response stream will send through a BEGIN Response.State to let you know that
-	// the Game has been started.
-	//
-	// When the player recieves a START_TURN response, the server can take your input for the turn.
-	//
-	// When the player recieves a STOP_TURN response, the serer is no longer taking input for the turn.
-	//
-	// A WIN state says you won the game. A LOSE state means that you got an input wrong, and have lost.
-	//
-	// To send input, send a Request with an event type of Color.
-	//
-	// When you recieve a Response of type Color, then light up that colour.
-	Game(SimonSays_GameServer) error
+type GamesRequest struct {
+	// The id of the player.
+	Player string `protobuf:"bytes,1,opt,name=player" json:"player,omitempty"`
+	// How many games to list. Defaults to 10, and is at most 100.
+	Limit int32 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
 }
 
-func RegisterSimonSaysServer(s *grpc.Server, srv SimonSaysServer) {
-	s.RegisterService(&_SimonSays_serviceDesc, srv)
+func (m *GamesRequest) Reset()                    { *m = GamesRequest{} }
+func (m *GamesRequest) String() string            { return proto.CompactTextString(m) }
+func (*GamesRequest) ProtoMessage()               {}
+func (*GamesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *GamesRequest) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
 }
 
-func _SimonSays_Game_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(SimonSaysServer).Game(&simonSaysGameServer{stream})
+func (m *GamesRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
 }
 
-type SimonSays_GameServer interface {
-	Send(*Response) error
-	Recv() (*Request, error)
-	grpc.ServerStream
+type GameList struct {
+	Games []*GameRecord `protobuf:"bytes,1,rep,name=games" json:"games,omitempty"`
 }
 
-type simonSaysGameServer struct {
-	grpc.ServerStream
+func (m *GameList) Reset()                    { *m = GameList{} }
+func (m *GameList) String() string            { return proto.CompactTextString(m) }
+func (*GameList) ProtoMessage()               {}
+func (*GameList) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *GameList) GetGames() []*GameRecord {
+	if m != nil {
+		return m.Games
+	}
+	return nil
 }
 
-func (x *simonSaysGameServer) Send(m *Response) error {
-	return x.ServerStream.SendMsg(m)
+type GameRequest struct {
+	// The id of the game.
+	Game string `protobuf:"bytes,1,opt,name=game" json:"game,omitempty"`
+}
+
+func (m *GameRequest) Reset()                    { *m = GameRequest{} }
+func (m *GameRequest) String() string            { return proto.CompactTextString(m) }
+func (*GameRequest) ProtoMessage()               {}
+func (*GameRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *GameRequest) GetGame() string {
+	if m != nil {
+		return m.Game
+	}
+	return ""
+}
+
+type ReplayRequest struct {
+	// The id of the game.
+	Game string `protobuf:"bytes,1,opt,name=game" json:"game,omitempty"`
+	// How many times faster than it happened to play the game back.
+	// Defaults to 1, the pace it was played at.
+	Speed float32 `protobuf:"fixed32,2,opt,name=speed" json:"speed,omitempty"`
+}
+
+func (m *ReplayRequest) Reset()                    { *m = ReplayRequest{} }
+func (m *ReplayRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReplayRequest) ProtoMessage()               {}
+func (*ReplayRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ReplayRequest) GetGame() string {
+	if m != nil {
+		return m.Game
+	}
+	return ""
+}
+
+func (m *ReplayRequest) GetSpeed() float32 {
+	if m != nil {
+		return m.Speed
+	}
+	return 0
+}
+
+type LeaderboardRequest struct {
+	Board  Leaderboard_Board  `protobuf:"varint,1,opt,name=board,enum=simonsays.Leaderboard_Board" json:"board,omitempty"`
+	Window Leaderboard_Window `protobuf:"varint,2,opt,name=window,enum=simonsays.Leaderboard_Window" json:"window,omitempty"`
+	// How many of the top players to get. Defaults to 10, and is at most 100.
+	Limit int32 `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+	// The id of a player to get the place of, as well as the top players.
+	Player string `protobuf:"bytes,4,opt,name=player" json:"player,omitempty"`
+}
+
+func (m *LeaderboardRequest) Reset()                    { *m = LeaderboardRequest{} }
+func (m *LeaderboardRequest) String() string            { return proto.CompactTextString(m) }
+func (*LeaderboardRequest) ProtoMessage()               {}
+func (*LeaderboardRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *LeaderboardRequest) GetBoard() Leaderboard_Board {
+	if m != nil {
+		return m.Board
+	}
+	return Leaderboard_WINS
+}
+
+func (m *LeaderboardRequest) GetWindow() Leaderboard_Window {
+	if m != nil {
+		return m.Window
+	}
+	return Leaderboard_ALL_TIME
+}
+
+func (m *LeaderboardRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *LeaderboardRequest) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+type Leaderboard struct {
+	Board  Leaderboard_Board  `protobuf:"varint,1,opt,name=board,enum=simonsays.Leaderboard_Board" json:"board,omitempty"`
+	Window Leaderboard_Window `protobuf:"varint,2,opt,name=window,enum=simonsays.Leaderboard_Window" json:"window,omitempty"`
+	// The top players, best first.
+	Entries []*Leaderboard_Entry `protobuf:"bytes,3,rep,name=entries" json:"entries,omitempty"`
+	// The requested player's place. Not set if they aren't on the leaderboard.
+	Player *Leaderboard_Entry `protobuf:"bytes,4,opt,name=player" json:"player,omitempty"`
+}
+
+func (m *Leaderboard) Reset()                    { *m = Leaderboard{} }
+func (m *Leaderboard) String() string            { return proto.CompactTextString(m) }
+func (*Leaderboard) ProtoMessage()               {}
+func (*Leaderboard) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *Leaderboard) GetBoard() Leaderboard_Board {
+	if m != nil {
+		return m.Board
+	}
+	return Leaderboard_WINS
+}
+
+func (m *Leaderboard) GetWindow() Leaderboard_Window {
+	if m != nil {
+		return m.Window
+	}
+	return Leaderboard_ALL_TIME
+}
+
+func (m *Leaderboard) GetEntries() []*Leaderboard_Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *Leaderboard) GetPlayer() *Leaderboard_Entry {
+	if m != nil {
+		return m.Player
+	}
+	return nil
+}
+
+// A player's place on the leaderboard.
+type Leaderboard_Entry struct {
+	// Counts up from 1 for the top player.
+	Rank   int32  `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	Player string `protobuf:"bytes,2,opt,name=player" json:"player,omitempty"`
+	Score  int64  `protobuf:"varint,3,opt,name=score" json:"score,omitempty"`
+}
+
+func (m *Leaderboard_Entry) Reset()                    { *m = Leaderboard_Entry{} }
+func (m *Leaderboard_Entry) String() string            { return proto.CompactTextString(m) }
+func (*Leaderboard_Entry) ProtoMessage()               {}
+func (*Leaderboard_Entry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 0} }
+
+func (m *Leaderboard_Entry) GetRank() int32 {
+	if m != nil {
+		return m.Rank
+	}
+	return 0
+}
+
+func (m *Leaderboard_Entry) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+func (m *Leaderboard_Entry) GetScore() int64 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+// GameRecord is what happened in a game. Times are
+// in milliseconds since the Unix epoch.
+type GameRecord struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// The players in turn order. A SOLO game has the one player.
+	Players []string `protobuf:"bytes,2,rep,name=players" json:"players,omitempty"`
+	Start   int64    `protobuf:"varint,3,opt,name=start" json:"start,omitempty"`
+	// Not set if the game hasn't finished.
+	End int64 `protobuf:"varint,4,opt,name=end" json:"end,omitempty"`
+	// The longest sequence a player matched and added to.
+	Sequence []Color `protobuf:"varint,5,rep,packed,name=sequence,enum=simonsays.Color" json:"sequence,omitempty"`
+	// The last player standing. Not set for SOLO games.
+	Winner string `protobuf:"bytes,6,opt,name=winner" json:"winner,omitempty"`
+	// The players that lost, in the order they went out.
+	Eliminations []*Elimination `protobuf:"bytes,7,rep,name=eliminations" json:"eliminations,omitempty"`
+	// Every turn, in order. Left out when listing games.
+	Turns []*GameRecord_Turn `protobuf:"bytes,8,rep,name=turns" json:"turns,omitempty"`
+}
+
+func (m *GameRecord) Reset()                    { *m = GameRecord{} }
+func (m *GameRecord) String() string            { return proto.CompactTextString(m) }
+func (*GameRecord) ProtoMessage()               {}
+func (*GameRecord) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GameRecord) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *GameRecord) GetPlayers() []string {
+	if m != nil {
+		return m.Players
+	}
+	return nil
+}
+
+func (m *GameRecord) GetStart() int64 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *GameRecord) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+func (m *GameRecord) GetSequence() []Color {
+	if m != nil {
+		return m.Sequence
+	}
+	return nil
+}
+
+func (m *GameRecord) GetWinner() string {
+	if m != nil {
+		return m.Winner
+	}
+	return ""
+}
+
+func (m *GameRecord) GetEliminations() []*Elimination {
+	if m != nil {
+		return m.Eliminations
+	}
+	return nil
+}
+
+func (m *GameRecord) GetTurns() []*GameRecord_Turn {
+	if m != nil {
+		return m.Turns
+	}
+	return nil
+}
+
+// A player's go at the sequence.
+type GameRecord_Turn struct {
+	// Counts up from 1 with each turn.
+	Round int32 `protobuf:"varint,1,opt,name=round" json:"round,omitempty"`
+	// The id of the player whose turn it was.
+	Player string `protobuf:"bytes,2,opt,name=player" json:"player,omitempty"`
+	// The colours the player pressed, in order.
+	Presses []Color `protobuf:"varint,3,rep,packed,name=presses,enum=simonsays.Color" json:"presses,omitempty"`
+	Start   int64   `protobuf:"varint,4,opt,name=start" json:"start,omitempty"`
+	// Not set if the game ended before the turn did.
+	End int64 `protobuf:"varint,5,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *GameRecord_Turn) Reset()                    { *m = GameRecord_Turn{} }
+func (m *GameRecord_Turn) String() string            { return proto.CompactTextString(m) }
+func (*GameRecord_Turn) ProtoMessage()               {}
+func (*GameRecord_Turn) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12, 0} }
+
+func (m *GameRecord_Turn) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *GameRecord_Turn) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+func (m *GameRecord_Turn) GetPresses() []Color {
+	if m != nil {
+		return m.Presses
+	}
+	return nil
+}
+
+func (m *GameRecord_Turn) GetStart() int64 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *GameRecord_Turn) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+// Elimination is a player going out of a game.
+type Elimination struct {
+	Player string             `protobuf:"bytes,1,opt,name=player" json:"player,omitempty"`
+	Reason Elimination_Reason `protobuf:"varint,2,opt,name=reason,enum=simonsays.Elimination_Reason" json:"reason,omitempty"`
+	// The round they went out on.
+	Round int32 `protobuf:"varint,3,opt,name=round" json:"round,omitempty"`
+	Time  int64 `protobuf:"varint,4,opt,name=time" json:"time,omitempty"`
+}
+
+func (m *Elimination) Reset()                    { *m = Elimination{} }
+func (m *Elimination) String() string            { return proto.CompactTextString(m) }
+func (*Elimination) ProtoMessage()               {}
+func (*Elimination) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *Elimination) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+func (m *Elimination) GetReason() Elimination_Reason {
+	if m != nil {
+		return m.Reason
+	}
+	return Elimination_UNKNOWN
+}
+
+func (m *Elimination) GetRound() int32 {
+	if m != nil {
+		return m.Round
+	}
+	return 0
+}
+
+func (m *Elimination) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+// Event is what the servers send each other over a game's topic, to keep
+// every player in the game up to date. It isn't part of the gRPC API, but
+// anything that can read protobufs can follow a game with it.
+type Event struct {
+	// The version of this schema the event was written with.
+	Version int32      `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Type    Event_Type `protobuf:"varint,2,opt,name=type,enum=simonsays.Event_Type" json:"type,omitempty"`
+	// The player the event is about.
+	Player string `protobuf:"bytes,3,opt,name=player" json:"player,omitempty"`
+	// The sequence of colours so far on STOP_TURN and LOST,
+	// or the colour that was pressed on LIGHTUP.
+	Colors []Color `protobuf:"varint,4,rep,packed,name=colors,enum=simonsays.Color" json:"colors,omitempty"`
+	// The players in turn order. Only set on BEGIN.
+	Players []string `protobuf:"bytes,5,rep,name=players" json:"players,omitempty"`
+	// Counts up with each event published to the game's topic,
+	// so gaps and reordering can be spotted.
+	Sequence int64 `protobuf:"varint,6,opt,name=sequence" json:"sequence,omitempty"`
+	// When the event was published, in milliseconds since the Unix epoch.
+	Time int64 `protobuf:"varint,7,opt,name=time" json:"time,omitempty"`
+	// Why the player lost. Only set on LOST.
+	Reason Elimination_Reason `protobuf:"varint,8,opt,name=reason,enum=simonsays.Elimination_Reason" json:"reason,omitempty"`
+}
+
+func (m *Event) Reset()                    { *m = Event{} }
+func (m *Event) String() string            { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()               {}
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *Event) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Event) GetType() Event_Type {
+	if m != nil {
+		return m.Type
+	}
+	return Event_UNKNOWN
+}
+
+func (m *Event) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+func (m *Event) GetColors() []Color {
+	if m != nil {
+		return m.Colors
+	}
+	return nil
+}
+
+func (m *Event) GetPlayers() []string {
+	if m != nil {
+		return m.Players
+	}
+	return nil
+}
+
+func (m *Event) GetSequence() int64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *Event) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *Event) GetReason() Elimination_Reason {
+	if m != nil {
+		return m.Reason
+	}
+	return Elimination_UNKNOWN
+}
+
+// Relay is what the servers send each other to carry a Game stream that was
+// resumed on a different server to the one running the game. The server the
+// player reconnected to attaches to the session on the other server's "Resume:<server>"
+// topic, then the requests go over "RelayIn:<attach>", and the responses come back
+// over "RelayOut:<attach>". It isn't part of the gRPC API.
+type Relay struct {
+	// The resume token of the session, and the player resuming it. Set when attaching.
+	Token  string `protobuf:"bytes,1,opt,name=token" json:"token,omitempty"`
+	Player string `protobuf:"bytes,2,opt,name=player" json:"player,omitempty"`
+	// The id of the relay. Set when attaching.
+	Attach   string    `protobuf:"bytes,3,opt,name=attach" json:"attach,omitempty"`
+	Request  *Request  `protobuf:"bytes,4,opt,name=request" json:"request,omitempty"`
+	Response *Response `protobuf:"bytes,5,opt,name=response" json:"response,omitempty"`
+	// The resumed stream has dropped, or the game is over, or the session
+	// couldn't be attached to, with why, if it was an error.
+	Done  bool   `protobuf:"varint,6,opt,name=done" json:"done,omitempty"`
+	Error *Error `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *Relay) Reset()                    { *m = Relay{} }
+func (m *Relay) String() string            { return proto.CompactTextString(m) }
+func (*Relay) ProtoMessage()               {}
+func (*Relay) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *Relay) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *Relay) GetPlayer() string {
+	if m != nil {
+		return m.Player
+	}
+	return ""
+}
+
+func (m *Relay) GetAttach() string {
+	if m != nil {
+		return m.Attach
+	}
+	return ""
+}
+
+func (m *Relay) GetRequest() *Request {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *Relay) GetResponse() *Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (m *Relay) GetDone() bool {
+	if m != nil {
+		return m.Done
+	}
+	return false
+}
+
+func (m *Relay) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Request)(nil), "simonsays.Request")
+	proto.RegisterType((*Request_Player)(nil), "simonsays.Request.Player")
+	proto.RegisterType((*Response)(nil), "simonsays.Response")
+	proto.RegisterType((*Error)(nil), "simonsays.Error")
+	proto.RegisterType((*SpectateRequest)(nil), "simonsays.SpectateRequest")
+	proto.RegisterType((*RatingRequest)(nil), "simonsays.RatingRequest")
+	proto.RegisterType((*Rating)(nil), "simonsays.Rating")
+	proto.RegisterType((*GamesRequest)(nil), "simonsays.GamesRequest")
+	proto.RegisterType((*GameList)(nil), "simonsays.GameList")
+	proto.RegisterType((*GameRequest)(nil), "simonsays.GameRequest")
+	proto.RegisterType((*ReplayRequest)(nil), "simonsays.ReplayRequest")
+	proto.RegisterType((*LeaderboardRequest)(nil), "simonsays.LeaderboardRequest")
+	proto.RegisterType((*Leaderboard)(nil), "simonsays.Leaderboard")
+	proto.RegisterType((*Leaderboard_Entry)(nil), "simonsays.Leaderboard.Entry")
+	proto.RegisterType((*GameRecord)(nil), "simonsays.GameRecord")
+	proto.RegisterType((*GameRecord_Turn)(nil), "simonsays.GameRecord.Turn")
+	proto.RegisterType((*Elimination)(nil), "simonsays.Elimination")
+	proto.RegisterType((*Event)(nil), "simonsays.Event")
+	proto.RegisterType((*Relay)(nil), "simonsays.Relay")
+	proto.RegisterEnum("simonsays.Color", Color_name, Color_value)
+	proto.RegisterEnum("simonsays.Request_Mode", Request_Mode_name, Request_Mode_value)
+	proto.RegisterEnum("simonsays.Response_State", Response_State_name, Response_State_value)
+	proto.RegisterEnum("simonsays.Error_Reason", Error_Reason_name, Error_Reason_value)
+	proto.RegisterEnum("simonsays.Leaderboard_Board", Leaderboard_Board_name, Leaderboard_Board_value)
+	proto.RegisterEnum("simonsays.Leaderboard_Window", Leaderboard_Window_name, Leaderboard_Window_value)
+	proto.RegisterEnum("simonsays.Elimination_Reason", Elimination_Reason_name, Elimination_Reason_value)
+	proto.RegisterEnum("simonsays.Event_Type", Event_Type_name, Event_Type_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for SimonSays service
+
+type SimonSaysClient interface {
+	//
+	// Game process is the following:
+	//
+	// A Join Request should be sent to the game. This tells it
+	// to join a game (or start a new one if one isn't already waiting on a game).
+	// Players take their turns in the order they joined the game.
+	// Players are matched with others close to their rating, and the longer they wait,
+	// the further apart the ratings they will be matched with. Ratings are updated at
+	// the end of each game.
+	//
+	// To play with friends, join with private set. This creates a private room, and the
+	// Response stream sends through a Response with the room's code. Friends join by setting
+	// room to that code, rather than being matched from the public queue. A room expires if
+	// it hasn't filled up in time.
+	//
+	// The Response stream will send through a BEGIN Response.State to let you know that
+	// the Game has been started.
+	//
+	// When the player recieves a START_TURN response, the server can take your input for the turn.
+	// If the START_TURN has a deadline, and you don't press a colour in time, you LOSE.
+	// If you joined with replay set, the whole sequence is played back to you as playback
+	// Color Responses before each START_TURN.
+	//
+	// When the player recieves a STOP_TURN response, the serer is no longer taking input for the turn.
+	//
+	// A WIN state says you won the game. A LOSE state means that you got an input wrong, and have lost.
+	// In games with more than two players, a player that loses is eliminated, and the
+	// next player has a go at the same sequence. The last player standing wins.
+	//
+	// In a SOLO game, the server plays the sequence as Color Responses, then sends START_TURN.
+	// Repeat the sequence back, and the server will add a colour to it for the next round,
+	// until you get one wrong and LOSE.
+	//
+	// To send input, send a Request with an event type of Color.
+	//
+	// When you recieve a Response of type Color, then light up that colour.
+	//
+	// If the game ends early because something went wrong, an Error Response is sent with
+	// the reason, and the stream ends with the matching gRPC status code.
+	//
+	// BEGIN comes with a resume token. If the stream drops, the game is kept going for a
+	// grace period, and the other players get a RECONNECTING response. Join on a new stream,
+	// to any server, with resume set to the token to pick up where you left off: you get
+	// BEGIN again, the sequence so far as playback Color Responses, and then START_TURN
+	// or STOP_TURN, depending on whose turn it is. If it's your turn, the colours you have
+	// already pressed this turn follow the START_TURN. The other players get a RECONNECTED
+	// response. If you don't resume in time, you LOSE when your turn comes around.
+	//
+	// If the server authenticates players, send a bearer token in the "authorization"
+	// metadata. The id of the Player can then be left out, and defaults to the one the
+	// token was issued to. Any other id is rejected with PERMISSION_DENIED.
+	//
+	// A player can only have one game going at a time. Depending on the server, joining
+	// again is either rejected with DUPLICATE_SESSION, or ends the older game with REPLACED.
+	Game(ctx context.Context, opts ...grpc.CallOption) (SimonSays_GameClient, error)
+	//
+	// Watch a game that is being played, without taking part in it.
+	//
+	// The game id is sent to players on BEGIN. The Response stream sends
+	// the same events the players see, each with the id of the player it is about,
+	// until the last player standing WINs.
+	Spectate(ctx context.Context, in *SpectateRequest, opts ...grpc.CallOption) (SimonSays_SpectateClient, error)
+	//
+	// Get the Elo rating of a player. Players that have yet to
+	// finish a game have the starting rating.
+	GetRating(ctx context.Context, in *RatingRequest, opts ...grpc.CallOption) (*Rating, error)
+	//
+	// List the most recent games a player has played, newest first.
+	// The records leave out the turns, which can be fetched with GetGame.
+	ListGames(ctx context.Context, in *GamesRequest, opts ...grpc.CallOption) (*GameList, error)
+	//
+	// Get the full record of a game, turn by turn. Games are kept for 30 days.
+	GetGame(ctx context.Context, in *GameRequest, opts ...grpc.CallOption) (*GameRecord, error)
+	//
+	// Replay a recorded game, with the same events a spectator would have seen,
+	// at the pace they happened. Set speed to play it back faster, or slower.
+	// SOLO games are replayed without the server's playback of the sequence.
+	Replay(ctx context.Context, in *ReplayRequest, opts ...grpc.CallOption) (SimonSays_ReplayClient, error)
+	//
+	// Get the top players on a leaderboard, and where a player is on it.
+	// Leaderboards are updated as each game with other players finishes,
+	// and are kept for the day and the week (in UTC), as well as for all time.
+	GetLeaderboard(ctx context.Context, in *LeaderboardRequest, opts ...grpc.CallOption) (*Leaderboard, error)
+}
+
+type simonSaysClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSimonSaysClient(cc *grpc.ClientConn) SimonSaysClient {
+	return &simonSaysClient{cc}
+}
+
+func (c *simonSaysClient) Game(ctx context.Context, opts ...grpc.CallOption) (SimonSays_GameClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_SimonSays_serviceDesc.Streams[0], c.cc, "/simonsays.SimonSays/Game", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &simonSaysGameClient{stream}
+	return x, nil
+}
+
+type SimonSays_GameClient interface {
+	Send(*Request) error
+	Recv() (*Response, error)
+	grpc.ClientStream
+}
+
+type simonSaysGameClient struct {
+	grpc.ClientStream
+}
+
+func (x *simonSaysGameClient) Send(m *Request) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *simonSaysGameClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *simonSaysClient) Spectate(ctx context.Context, in *SpectateRequest, opts ...grpc.CallOption) (SimonSays_SpectateClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_SimonSays_serviceDesc.Streams[1], c.cc, "/simonsays.SimonSays/Spectate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &simonSaysSpectateClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SimonSays_SpectateClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
+}
+
+type simonSaysSpectateClient struct {
+	grpc.ClientStream
+}
+
+func (x *simonSaysSpectateClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *simonSaysClient) GetRating(ctx context.Context, in *RatingRequest, opts ...grpc.CallOption) (*Rating, error) {
+	out := new(Rating)
+	err := grpc.Invoke(ctx, "/simonsays.SimonSays/GetRating", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simonSaysClient) ListGames(ctx context.Context, in *GamesRequest, opts ...grpc.CallOption) (*GameList, error) {
+	out := new(GameList)
+	err := grpc.Invoke(ctx, "/simonsays.SimonSays/ListGames", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simonSaysClient) GetGame(ctx context.Context, in *GameRequest, opts ...grpc.CallOption) (*GameRecord, error) {
+	out := new(GameRecord)
+	err := grpc.Invoke(ctx, "/simonsays.SimonSays/GetGame", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simonSaysClient) Replay(ctx context.Context, in *ReplayRequest, opts ...grpc.CallOption) (SimonSays_ReplayClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_SimonSays_serviceDesc.Streams[2], c.cc, "/simonsays.SimonSays/Replay", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &simonSaysReplayClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SimonSays_ReplayClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
+}
+
+type simonSaysReplayClient struct {
+	grpc.ClientStream
+}
+
+func (x *simonSaysReplayClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *simonSaysClient) GetLeaderboard(ctx context.Context, in *LeaderboardRequest, opts ...grpc.CallOption) (*Leaderboard, error) {
+	out := new(Leaderboard)
+	err := grpc.Invoke(ctx, "/simonsays.SimonSays/GetLeaderboard", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for SimonSays service
+
+type SimonSaysServer interface {
+	//
+	// Game process is the following:
+	//
+	// A Join Request should be sent to the game. This tells it
+	// to join a game (or start a new one if one isn't already waiting on a game).
+	// Players take their turns in the order they joined the game.
+	// Players are matched with others close to their rating, and the longer they wait,
+	// the further apart the ratings they will be matched with. Ratings are updated at
+	// the end of each game.
+	//
+	// To play with friends, join with private set. This creates a private room, and the
+	// Response stream sends through a Response with the room's code. Friends join by setting
+	// room to that code, rather than being matched from the public queue. A room expires if
+	// it hasn't filled up in time.
+	//
+	// The Response stream will send through a BEGIN Response.State to let you know that
+	// the Game has been started.
+	//
+	// When the player recieves a START_TURN response, the server can take your input for the turn.
+	// If the START_TURN has a deadline, and you don't press a colour in time, you LOSE.
+	// If you joined with replay set, the whole sequence is played back to you as playback
+	// Color Responses before each START_TURN.
+	//
+	// When the player recieves a STOP_TURN response, the serer is no longer taking input for the turn.
+	//
+	// A WIN state says you won the game. A LOSE state means that you got an input wrong, and have lost.
+	// In games with more than two players, a player that loses is eliminated, and the
+	// next player has a go at the same sequence. The last player standing wins.
+	//
+	// In a SOLO game, the server plays the sequence as Color Responses, then sends START_TURN.
+	// Repeat the sequence back, and the server will add a colour to it for the next round,
+	// until you get one wrong and LOSE.
+	//
+	// To send input, send a Request with an event type of Color.
+	//
+	// When you recieve a Response of type Color, then light up that colour.
+	//
+	// If the game ends early because something went wrong, an Error Response is sent with
+	// the reason, and the stream ends with the matching gRPC status code.
+	//
+	// BEGIN comes with a resume token. If the stream drops, the game is kept going for a
+	// grace period, and the other players get a RECONNECTING response. Join on a new stream,
+	// to any server, with resume set to the token to pick up where you left off: you get
+	// BEGIN again, the sequence so far as playback Color Responses, and then START_TURN
+	// or STOP_TURN, depending on whose turn it is. If it's your turn, the colours you have
+	// already pressed this turn follow the START_TURN. The other players get a RECONNECTED
+	// response. If you don't resume in time, you LOSE when your turn comes around.
+	//
+	// If the server authenticates players, send a bearer token in the "authorization"
+	// metadata. The id of the Player can then be left out, and defaults to the one the
+	// token was issued to. Any other id is rejected with PERMISSION_DENIED.
+	//
+	// A player can only have one game going at a time. Depending on the server, joining
+	// again is either rejected with DUPLICATE_SESSION, or ends the older game with REPLACED.
+	Game(SimonSays_GameServer) error
+	//
+	// Watch a game that is being played, without taking part in it.
+	//
+	// The game id is sent to players on BEGIN. The Response stream sends
+	// the same events the players see, each with the id of the player it is about,
+	// until the last player standing WINs.
+	Spectate(*SpectateRequest, SimonSays_SpectateServer) error
+	//
+	// Get the Elo rating of a player. Players that have yet to
+	// finish a game have the starting rating.
+	GetRating(context.Context, *RatingRequest) (*Rating, error)
+	//
+	// List the most recent games a player has played, newest first.
+	// The records leave out the turns, which can be fetched with GetGame.
+	ListGames(context.Context, *GamesRequest) (*GameList, error)
+	//
+	// Get the full record of a game, turn by turn. Games are kept for 30 days.
+	GetGame(context.Context, *GameRequest) (*GameRecord, error)
+	//
+	// Replay a recorded game, with the same events a spectator would have seen,
+	// at the pace they happened. Set speed to play it back faster, or slower.
+	// SOLO games are replayed without the server's playback of the sequence.
+	Replay(*ReplayRequest, SimonSays_ReplayServer) error
+	//
+	// Get the top players on a leaderboard, and where a player is on it.
+	// Leaderboards are updated as each game with other players finishes,
+	// and are kept for the day and the week (in UTC), as well as for all time.
+	GetLeaderboard(context.Context, *LeaderboardRequest) (*Leaderboard, error)
+}
+
+func RegisterSimonSaysServer(s *grpc.Server, srv SimonSaysServer) {
+	s.RegisterService(&_SimonSays_serviceDesc, srv)
+}
+
+func _SimonSays_Game_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SimonSaysServer).Game(&simonSaysGameServer{stream})
+}
+
+type SimonSays_GameServer interface {
+	Send(*Response) error
+	Recv() (*Request, error)
+	grpc.ServerStream
+}
+
+type simonSaysGameServer struct {
+	grpc.ServerStream
+}
+
+func (x *simonSaysGameServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
 }
 
 func (x *simonSaysGameServer) Recv() (*Request, error) {
@@ -476,10 +1784,141 @@ func (x *simonSaysGameServer) Recv() (*Request, error) {
 	return m, nil
 }
 
+func _SimonSays_Spectate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SpectateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimonSaysServer).Spectate(m, &simonSaysSpectateServer{stream})
+}
+
+type SimonSays_SpectateServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type simonSaysSpectateServer struct {
+	grpc.ServerStream
+}
+
+func (x *simonSaysSpectateServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SimonSays_GetRating_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RatingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimonSaysServer).GetRating(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simonsays.SimonSays/GetRating",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimonSaysServer).GetRating(ctx, req.(*RatingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimonSays_ListGames_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GamesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimonSaysServer).ListGames(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simonsays.SimonSays/ListGames",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimonSaysServer).ListGames(ctx, req.(*GamesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimonSays_GetGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimonSaysServer).GetGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simonsays.SimonSays/GetGame",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimonSaysServer).GetGame(ctx, req.(*GameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimonSays_Replay_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReplayRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimonSaysServer).Replay(m, &simonSaysReplayServer{stream})
+}
+
+type SimonSays_ReplayServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type simonSaysReplayServer struct {
+	grpc.ServerStream
+}
+
+func (x *simonSaysReplayServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SimonSays_GetLeaderboard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaderboardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimonSaysServer).GetLeaderboard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simonsays.SimonSays/GetLeaderboard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimonSaysServer).GetLeaderboard(ctx, req.(*LeaderboardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SimonSays_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "simonsays.SimonSays",
 	HandlerType: (*SimonSaysServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRating",
+			Handler:    _SimonSays_GetRating_Handler,
+		},
+		{
+			MethodName: "ListGames",
+			Handler:    _SimonSays_ListGames_Handler,
+		},
+		{
+			MethodName: "GetGame",
+			Handler:    _SimonSays_GetGame_Handler,
+		},
+		{
+			MethodName: "GetLeaderboard",
+			Handler:    _SimonSays_GetLeaderboard_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "Game",
@@ -487,6 +1926,16 @@ var _SimonSays_serviceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Spectate",
+			Handler:       _SimonSays_Spectate_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Replay",
+			Handler:       _SimonSays_Replay_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "simonsays.proto",
 }
@@ -494,27 +1943,105 @@ var _SimonSays_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("simonsays.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 350 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x7c, 0x91, 0xd1, 0x4e, 0xab, 0x30,
-	0x18, 0xc7, 0x29, 0x83, 0x31, 0xbe, 0x93, 0xc3, 0x69, 0x7a, 0x6e, 0x76, 0x76, 0xb5, 0x70, 0xc5,
-	0x31, 0x06, 0x75, 0xc6, 0x07, 0x10, 0x47, 0xe6, 0x12, 0xb2, 0x2d, 0x85, 0x65, 0xf1, 0xca, 0xa0,
-	0x6b, 0x26, 0x86, 0x51, 0xa4, 0x9d, 0x71, 0x4f, 0xe0, 0x2b, 0xf9, 0x78, 0xa6, 0xa0, 0x9b, 0x51,
-	0xe3, 0x65, 0x9b, 0xdf, 0xff, 0xff, 0xfd, 0xda, 0x0f, 0xfe, 0x88, 0x6c, 0xcd, 0x0b, 0x91, 0x6e,
-	0x85, 0x5f, 0x56, 0x5c, 0x72, 0x62, 0xef, 0x2e, 0xdc, 0x67, 0x04, 0x16, 0x65, 0x0f, 0x1b, 0x26,
-	0x24, 0x39, 0x02, 0xe3, 0x9e, 0x67, 0x45, 0x17, 0xf5, 0x91, 0xf7, 0x6b, 0xf0, 0xcf, 0xdf, 0xc7,
-	0xde, 0x08, 0x7f, 0x96, 0xa7, 0x5b, 0x56, 0x5d, 0x6a, 0xb4, 0x06, 0x89, 0x07, 0x66, 0x59, 0x31,
-	0x21, 0xba, 0x7a, 0x1f, 0x79, 0xce, 0x00, 0x7f, 0x48, 0x5c, 0xf0, 0x9c, 0x2b, 0xb0, 0x01, 0x7a,
-	0x5d, 0x68, 0x37, 0x59, 0xe2, 0x80, 0x9e, 0x2d, 0xeb, 0x11, 0x36, 0xd5, 0xb3, 0x65, 0x60, 0x81,
-	0xc9, 0x1e, 0x59, 0x21, 0xdd, 0x17, 0x04, 0x1d, 0xca, 0x44, 0xc9, 0x0b, 0xc1, 0x94, 0x8a, 0xdc,
-	0x54, 0x8d, 0x8a, 0xf3, 0x49, 0xa5, 0x41, 0xfc, 0x58, 0xa6, 0x92, 0x29, 0x15, 0x05, 0x92, 0x43,
-	0xb0, 0xf2, 0x6c, 0x75, 0x27, 0x37, 0xe5, 0x0f, 0x32, 0xef, 0x88, 0x3b, 0x04, 0xb3, 0x8e, 0x13,
-	0x1b, 0xcc, 0x20, 0x1c, 0x8d, 0x27, 0x58, 0x23, 0x0e, 0x40, 0x9c, 0x9c, 0xd3, 0xe4, 0x3a, 0x99,
-	0xd3, 0x09, 0x46, 0xe4, 0x37, 0xd8, 0x71, 0x32, 0x9d, 0x35, 0x47, 0x9d, 0x58, 0xd0, 0x5a, 0x8c,
-	0x27, 0xb8, 0x45, 0x3a, 0x60, 0x44, 0xd3, 0x38, 0xc4, 0xc6, 0x4e, 0xfd, 0xe0, 0x04, 0xcc, 0x7a,
-	0x84, 0x82, 0x68, 0x38, 0xc4, 0x9a, 0xea, 0x1d, 0xd1, 0x30, 0x54, 0x3d, 0x00, 0xed, 0xab, 0x30,
-	0x8a, 0xa6, 0x0b, 0xac, 0xab, 0x6c, 0x10, 0xcd, 0x43, 0xdc, 0x1a, 0x04, 0x60, 0xc7, 0xca, 0x2f,
-	0x4e, 0xb7, 0x82, 0x9c, 0x81, 0x31, 0x4a, 0xd7, 0x8c, 0x90, 0xaf, 0x5f, 0xde, 0xfb, 0xfb, 0xcd,
-	0xdb, 0x5d, 0xcd, 0x43, 0xc7, 0x28, 0xf8, 0x0f, 0xbd, 0x8c, 0xfb, 0xab, 0xaa, 0xbc, 0xf5, 0xd9,
-	0x53, 0xba, 0x2e, 0x73, 0x26, 0xf6, 0x70, 0xb0, 0xef, 0x9f, 0xa1, 0x9b, 0x76, 0xbd, 0xf8, 0xd3,
-	0xd7, 0x00, 0x00, 0x00, 0xff, 0xff, 0xbb, 0xe1, 0xba, 0x9a, 0x0b, 0x02, 0x00, 0x00,
+	// 1590 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0xcd, 0x8e, 0xdb, 0x46,
+	0x12, 0x16, 0xc5, 0x1f, 0x49, 0xa5, 0xf9, 0xa1, 0xdb, 0xf6, 0x98, 0x2b, 0x78, 0x81, 0x59, 0x02,
+	0xbb, 0x2b, 0xff, 0xac, 0xec, 0x9d, 0x5d, 0xef, 0x26, 0x4e, 0x80, 0x40, 0x1a, 0xd1, 0x32, 0x61,
+	0x0e, 0x39, 0x69, 0x52, 0x1e, 0xf8, 0x34, 0xa0, 0xa5, 0x86, 0xcc, 0x78, 0x44, 0x2a, 0x24, 0x65,
+	0x67, 0xde, 0x20, 0x97, 0x1c, 0x73, 0xcc, 0x23, 0xe4, 0x11, 0x02, 0xf8, 0x9e, 0x17, 0xc9, 0x23,
+	0x04, 0x08, 0x02, 0x04, 0xd5, 0x24, 0x25, 0xce, 0x0c, 0x65, 0x27, 0x97, 0xdc, 0x58, 0xdd, 0x5f,
+	0x75, 0x57, 0x7d, 0x55, 0x5d, 0x55, 0x84, 0xdd, 0x24, 0x98, 0x47, 0x61, 0xe2, 0x9f, 0x27, 0xbd,
+	0x45, 0x1c, 0xa5, 0x11, 0x69, 0xad, 0x16, 0xf4, 0x1f, 0xeb, 0xd0, 0xa0, 0xec, 0xcb, 0x25, 0x4b,
+	0x52, 0xf2, 0x00, 0xa4, 0x2f, 0xa2, 0x20, 0xd4, 0x84, 0x7d, 0xa1, 0xdb, 0x3e, 0xf8, 0x4b, 0x6f,
+	0xad, 0x96, 0x23, 0x7a, 0xc7, 0x67, 0xfe, 0x39, 0x8b, 0x9f, 0xd6, 0x28, 0x07, 0x92, 0x2e, 0xc8,
+	0x8b, 0x98, 0x25, 0x89, 0x56, 0xdf, 0x17, 0xba, 0x3b, 0x07, 0x6a, 0x49, 0xe3, 0x30, 0x3a, 0x8b,
+	0x10, 0x98, 0x01, 0x3a, 0x3f, 0x08, 0xa0, 0x64, 0xca, 0x64, 0x07, 0xea, 0xc1, 0x94, 0xdf, 0xd1,
+	0xa2, 0xf5, 0x60, 0x4a, 0x34, 0x68, 0x2c, 0xf8, 0x4e, 0x76, 0x8c, 0x4c, 0x0b, 0x91, 0xdc, 0x03,
+	0x69, 0x1e, 0x4d, 0x99, 0x26, 0xf2, 0xd3, 0x6f, 0x55, 0xd8, 0x73, 0x14, 0x4d, 0x19, 0xe5, 0x20,
+	0xb2, 0x07, 0x4a, 0xcc, 0x50, 0x53, 0x93, 0xf6, 0x85, 0x6e, 0x93, 0xe6, 0x12, 0x3f, 0x3e, 0x0e,
+	0xde, 0xf8, 0x29, 0xd3, 0x64, 0xbe, 0x51, 0x88, 0x84, 0x80, 0x14, 0x47, 0xd1, 0x5c, 0x53, 0xb8,
+	0x29, 0xfc, 0x3b, 0x3b, 0x25, 0x59, 0xce, 0x99, 0xd6, 0xe0, 0xab, 0xb9, 0xa4, 0xdf, 0x06, 0x09,
+	0xef, 0x22, 0x00, 0xca, 0x73, 0x83, 0xba, 0x63, 0x57, 0xad, 0x91, 0x26, 0x48, 0xae, 0x63, 0x39,
+	0xaa, 0x30, 0x68, 0x80, 0xcc, 0xde, 0xb0, 0x30, 0xd5, 0x7f, 0x11, 0xa1, 0x49, 0x59, 0xb2, 0x88,
+	0xc2, 0x84, 0x21, 0x9d, 0xe9, 0x32, 0xce, 0xe8, 0xdc, 0xb9, 0x44, 0x67, 0x06, 0xe9, 0xb9, 0xa9,
+	0x9f, 0x32, 0xa4, 0x13, 0x81, 0xe4, 0x3e, 0x34, 0xce, 0x82, 0xd9, 0xab, 0x74, 0xb9, 0x78, 0x0f,
+	0xa1, 0x05, 0x04, 0xc9, 0x67, 0x71, 0x1c, 0xc5, 0x5a, 0x8b, 0x87, 0xab, 0x8c, 0x35, 0xe2, 0x38,
+	0x23, 0x9f, 0x03, 0x48, 0x07, 0x9a, 0x53, 0xe6, 0x4f, 0xcf, 0x82, 0x30, 0xe3, 0x52, 0xa4, 0x2b,
+	0x19, 0xf7, 0x90, 0xa6, 0x97, 0xfe, 0xe4, 0x75, 0x4e, 0xdc, 0x4a, 0x46, 0x32, 0xb2, 0x50, 0x70,
+	0xe6, 0x5a, 0x34, 0x97, 0x90, 0xb8, 0x99, 0x3f, 0x67, 0x05, 0x71, 0xf8, 0xbd, 0x22, 0xb3, 0x51,
+	0x49, 0x66, 0xb3, 0x4c, 0x26, 0xb9, 0x01, 0x72, 0x1c, 0x2d, 0xc3, 0xa9, 0x06, 0x3c, 0xde, 0x99,
+	0x80, 0xe8, 0x33, 0x16, 0xce, 0xd2, 0x57, 0x5a, 0x9b, 0x2f, 0xe7, 0x12, 0x06, 0x70, 0xb2, 0x8c,
+	0x63, 0x16, 0xa6, 0xda, 0x16, 0x3f, 0xa6, 0x10, 0xc9, 0x6d, 0x68, 0x45, 0x8b, 0x45, 0x14, 0xb2,
+	0x30, 0x4d, 0xb4, 0xed, 0x7d, 0xb1, 0xdb, 0xa2, 0xeb, 0x05, 0x7d, 0x06, 0x32, 0xa7, 0x97, 0xb4,
+	0x40, 0x1e, 0x18, 0x23, 0xd3, 0x56, 0x6b, 0x64, 0x07, 0xc0, 0xf5, 0xfa, 0xd4, 0x3b, 0xf5, 0xc6,
+	0xd4, 0x56, 0x05, 0xb2, 0x0d, 0x2d, 0xd7, 0x73, 0x8e, 0x33, 0xb1, 0x4e, 0x1a, 0x20, 0x9e, 0x98,
+	0xb6, 0x2a, 0x62, 0x68, 0x2d, 0xc7, 0x35, 0x54, 0x89, 0xa8, 0xb0, 0x45, 0x8d, 0x43, 0xc7, 0xb6,
+	0x8d, 0x43, 0xcf, 0xb4, 0x47, 0xaa, 0x4c, 0x76, 0xa1, 0xbd, 0x5a, 0x31, 0x86, 0xaa, 0xb2, 0x8e,
+	0xfe, 0xb7, 0x75, 0x90, 0x39, 0xf5, 0xe4, 0x01, 0x7a, 0xee, 0x27, 0x51, 0x11, 0xfc, 0x5b, 0x97,
+	0x83, 0xd3, 0xa3, 0x7c, 0x9b, 0xe6, 0x30, 0x74, 0x72, 0xce, 0x92, 0xc4, 0x9f, 0x31, 0x1e, 0xfa,
+	0x16, 0x2d, 0x44, 0xfd, 0x9d, 0x00, 0x4a, 0x06, 0x26, 0x6d, 0x68, 0x8c, 0xed, 0x67, 0xb6, 0x73,
+	0x82, 0xae, 0x5c, 0x87, 0x5d, 0xd3, 0x7e, 0xde, 0xb7, 0xcc, 0xe1, 0x29, 0x35, 0x3e, 0x1f, 0x1b,
+	0xae, 0xa7, 0x0a, 0x68, 0xdb, 0xd8, 0xee, 0x3f, 0xef, 0x9b, 0x56, 0x7f, 0x60, 0x19, 0x6a, 0x1d,
+	0x55, 0x3c, 0xf3, 0xc8, 0x70, 0xc6, 0x9e, 0x2a, 0xa2, 0xd0, 0x1f, 0x38, 0x14, 0xad, 0x96, 0xd0,
+	0x75, 0xdb, 0xf1, 0x4e, 0x9f, 0x38, 0x63, 0x7b, 0xa8, 0xca, 0x64, 0x0b, 0x9a, 0xee, 0xd3, 0xb1,
+	0x37, 0xc4, 0xc3, 0x15, 0x72, 0x13, 0xae, 0x1d, 0x1b, 0xf4, 0xc8, 0x74, 0x5d, 0xd3, 0xb1, 0x4f,
+	0x87, 0x86, 0x6d, 0x1a, 0x43, 0xb5, 0x81, 0xcb, 0xc3, 0xf1, 0xb1, 0x65, 0x1e, 0xf6, 0x3d, 0xe3,
+	0xd4, 0x35, 0xf8, 0xae, 0xda, 0x44, 0x5d, 0x6a, 0x1c, 0x5b, 0xfd, 0x43, 0x63, 0xa8, 0xb6, 0x38,
+	0x63, 0xb8, 0x6f, 0x99, 0x47, 0x26, 0x5e, 0x05, 0xfa, 0xdf, 0x61, 0xd7, 0x5d, 0xb0, 0x09, 0x06,
+	0xa3, 0x28, 0x35, 0x45, 0x0a, 0x09, 0xeb, 0x14, 0xd2, 0xff, 0x09, 0xdb, 0xd4, 0x4f, 0x83, 0x70,
+	0x56, 0x80, 0xd6, 0xf9, 0x27, 0x94, 0xf3, 0x4f, 0xff, 0x08, 0x94, 0x0c, 0xb8, 0x09, 0x81, 0xeb,
+	0x31, 0x47, 0xe4, 0x25, 0x25, 0x97, 0xf4, 0x4f, 0x61, 0x6b, 0xe4, 0xcf, 0x59, 0xf2, 0x81, 0x1b,
+	0x30, 0x43, 0xcf, 0x82, 0x79, 0x90, 0xe6, 0xea, 0x99, 0xa0, 0xff, 0x1f, 0x9a, 0xa8, 0x6d, 0x05,
+	0x49, 0x4a, 0xee, 0x81, 0x8c, 0x46, 0x27, 0x9a, 0xb0, 0x2f, 0x76, 0xdb, 0x07, 0x37, 0x4b, 0x01,
+	0x46, 0x0c, 0x65, 0x93, 0x28, 0x9e, 0xd2, 0x0c, 0xa3, 0xff, 0x0d, 0xda, 0xd9, 0xe2, 0x66, 0xe7,
+	0x3f, 0x86, 0x6d, 0xca, 0x0b, 0xd6, 0x7b, 0x40, 0x68, 0x56, 0xb2, 0x60, 0x6c, 0xca, 0xcd, 0xaa,
+	0xd3, 0x4c, 0xd0, 0xbf, 0x17, 0x80, 0x58, 0xcc, 0x9f, 0xb2, 0xf8, 0x65, 0xe4, 0xc7, 0xd3, 0xe2,
+	0x80, 0x03, 0x90, 0xb9, 0x9c, 0xa7, 0xe0, 0xed, 0x92, 0x85, 0x25, 0x74, 0x6f, 0xc0, 0x75, 0x32,
+	0x28, 0x79, 0x04, 0xca, 0xdb, 0x20, 0x9c, 0x46, 0x6f, 0xf3, 0x02, 0xf4, 0xd7, 0x0d, 0x4a, 0x27,
+	0x1c, 0x44, 0x73, 0xf0, 0x9a, 0x2e, 0xb1, 0x44, 0x57, 0x89, 0x5c, 0xe9, 0x42, 0xf8, 0xbe, 0x11,
+	0xa1, 0x5d, 0x3a, 0xec, 0xcf, 0x34, 0xf4, 0x7f, 0xd0, 0x60, 0x61, 0x1a, 0x07, 0x2c, 0xd1, 0x44,
+	0x1e, 0xb7, 0x4d, 0x97, 0x19, 0x61, 0x1a, 0x9f, 0xd3, 0x02, 0x4c, 0xfe, 0x7b, 0xc1, 0x95, 0x0f,
+	0xa9, 0xe5, 0xd8, 0x8e, 0x09, 0x32, 0x5f, 0xe0, 0xc5, 0xd1, 0x0f, 0x5f, 0x73, 0x07, 0x65, 0xca,
+	0xbf, 0x4b, 0xec, 0xd4, 0x2f, 0xa7, 0x5e, 0x32, 0x89, 0xe2, 0xa2, 0x52, 0x67, 0x82, 0x7e, 0x0f,
+	0x64, 0xee, 0x3f, 0x56, 0xa6, 0x13, 0xd3, 0xc6, 0xf6, 0x03, 0xa0, 0xb8, 0x1e, 0x35, 0xfa, 0xcf,
+	0x54, 0x81, 0xbf, 0x5e, 0x2c, 0x02, 0xf6, 0xa1, 0xa1, 0xd6, 0xf5, 0x7f, 0x81, 0x92, 0xf9, 0x8d,
+	0xeb, 0x7d, 0xcb, 0x3a, 0xc5, 0x12, 0xa0, 0xd6, 0xb0, 0x10, 0x0e, 0xfb, 0xa6, 0xf5, 0x42, 0x15,
+	0x50, 0xf9, 0xc4, 0x30, 0x9e, 0x59, 0x2f, 0xd4, 0xba, 0xfe, 0x9d, 0x08, 0xb0, 0xce, 0xd9, 0xf7,
+	0xf7, 0x67, 0xac, 0xb1, 0x85, 0xc8, 0x4d, 0x4d, 0xfd, 0x38, 0x5d, 0x99, 0x8a, 0x02, 0x51, 0x41,
+	0x64, 0xe1, 0x94, 0x13, 0x25, 0x52, 0xfc, 0x24, 0xf7, 0xa1, 0x99, 0x60, 0x52, 0x86, 0x13, 0xec,
+	0xc1, 0x62, 0x55, 0x63, 0xa3, 0x2b, 0x04, 0x12, 0xf3, 0x36, 0x08, 0x43, 0x16, 0xe7, 0xfd, 0x25,
+	0x97, 0xc8, 0x63, 0xd8, 0x62, 0x98, 0x58, 0xa1, 0x9f, 0x06, 0x51, 0x98, 0x68, 0x0d, 0x1e, 0xc0,
+	0xbd, 0x72, 0x65, 0x5d, 0x6f, 0xd3, 0x0b, 0x58, 0xf2, 0x10, 0x64, 0xec, 0xb0, 0x89, 0xd6, 0xe4,
+	0x4a, 0x9d, 0xca, 0xd7, 0xda, 0xf3, 0x96, 0x71, 0x48, 0x33, 0x60, 0xe7, 0x6b, 0x01, 0x24, 0x94,
+	0xd7, 0xcd, 0x4a, 0xb8, 0xd4, 0xac, 0x2a, 0xa3, 0x77, 0x17, 0xa7, 0x0d, 0x96, 0x24, 0x79, 0x82,
+	0x55, 0x79, 0x5a, 0x00, 0xd6, 0xf4, 0x49, 0x15, 0xf4, 0xc9, 0x2b, 0xfa, 0xf4, 0x5f, 0x05, 0x68,
+	0x97, 0x5c, 0xdb, 0x58, 0xb4, 0x1e, 0xad, 0x9a, 0xce, 0xd5, 0x37, 0x51, 0xd2, 0xbf, 0xdc, 0x7a,
+	0x56, 0x0e, 0x8a, 0x65, 0x07, 0x09, 0x48, 0x69, 0x30, 0x67, 0xb9, 0x6d, 0xfc, 0x5b, 0x5f, 0x56,
+	0x77, 0xa2, 0x5d, 0x68, 0x9f, 0x50, 0xc7, 0x1e, 0x9d, 0x1e, 0x3a, 0x96, 0x43, 0x55, 0xa1, 0xdc,
+	0x74, 0x78, 0x07, 0x7a, 0xe2, 0xd0, 0x27, 0x86, 0x89, 0x1d, 0xa8, 0xdc, 0x65, 0xa4, 0xaa, 0x16,
+	0x26, 0x5f, 0x69, 0x1f, 0x8a, 0xfe, 0x33, 0xb6, 0x55, 0x6c, 0xb0, 0x98, 0x8a, 0x6f, 0x58, 0x9c,
+	0x04, 0x79, 0x5f, 0x95, 0x69, 0x21, 0x92, 0x3b, 0x20, 0xa5, 0xe7, 0x0b, 0x96, 0x7b, 0x5e, 0xae,
+	0xc6, 0x5c, 0xb3, 0xe7, 0x9d, 0x2f, 0x18, 0xe5, 0x90, 0x12, 0x7d, 0xe2, 0x05, 0xfa, 0xba, 0xa0,
+	0x4c, 0x30, 0x40, 0x89, 0x26, 0x6d, 0x88, 0x5c, 0xbe, 0x5f, 0x7e, 0x11, 0xf2, 0xc5, 0x17, 0xd1,
+	0x29, 0x65, 0xba, 0x92, 0x4d, 0x5a, 0x85, 0xbc, 0x62, 0xb4, 0xb1, 0x66, 0xb4, 0x14, 0xb2, 0xe6,
+	0x1f, 0x08, 0x99, 0x3e, 0x03, 0x09, 0x1d, 0xba, 0x18, 0x86, 0xd5, 0x98, 0x73, 0x65, 0xac, 0x69,
+	0x43, 0xc3, 0x32, 0x47, 0x4f, 0xbd, 0xf1, 0xf1, 0x6a, 0xb4, 0xf1, 0x7e, 0xd7, 0x68, 0xa3, 0xff,
+	0x24, 0x80, 0x4c, 0x19, 0x8e, 0xd1, 0x37, 0x40, 0x4e, 0xa3, 0xd7, 0x2c, 0xcc, 0x73, 0x2e, 0x13,
+	0x36, 0x3e, 0x83, 0x3d, 0x50, 0xfc, 0x34, 0xf5, 0x27, 0xaf, 0x0a, 0x8e, 0x33, 0x09, 0x27, 0xdc,
+	0x38, 0x6b, 0x4f, 0x79, 0x21, 0x25, 0x57, 0x87, 0x7a, 0x5a, 0x40, 0xc8, 0x03, 0x68, 0xc6, 0xf9,
+	0xa4, 0xcc, 0xdf, 0x43, 0xfb, 0xe0, 0x7a, 0xc5, 0x10, 0x4d, 0x57, 0x20, 0xa4, 0x78, 0x1a, 0x85,
+	0x19, 0xf5, 0x4d, 0xca, 0xbf, 0xc9, 0x3f, 0x8a, 0x31, 0xb9, 0x51, 0x3d, 0x26, 0xe7, 0x43, 0xf2,
+	0xdd, 0x7f, 0x83, 0xcc, 0xa3, 0x8c, 0x43, 0x20, 0x35, 0x86, 0x19, 0xa1, 0x23, 0x6a, 0x18, 0x76,
+	0x56, 0x2e, 0x5f, 0x18, 0x96, 0xe5, 0x9c, 0xa8, 0x75, 0x24, 0x70, 0x60, 0x8d, 0x0d, 0x55, 0x3c,
+	0x78, 0x27, 0x42, 0xcb, 0xc5, 0xd3, 0x5c, 0xff, 0x3c, 0x21, 0x8f, 0x40, 0x1a, 0xf1, 0x49, 0xf8,
+	0xaa, 0x4b, 0x9d, 0x2a, 0xbb, 0xf5, 0x5a, 0x57, 0x78, 0x28, 0x90, 0xcf, 0xa0, 0x59, 0x0c, 0x47,
+	0xa4, 0x5c, 0x97, 0x2e, 0x4d, 0x4c, 0x1b, 0x8e, 0x78, 0x28, 0x90, 0xc7, 0xd0, 0x1a, 0xb1, 0x34,
+	0x1f, 0x88, 0xb4, 0x32, 0xaa, 0x3c, 0x4c, 0x75, 0xae, 0x5d, 0xd9, 0xd1, 0x6b, 0xe4, 0x13, 0x68,
+	0xe1, 0x34, 0x83, 0x76, 0x27, 0xe4, 0xd6, 0xa5, 0xaa, 0x98, 0x54, 0x5d, 0x5d, 0x0c, 0x40, 0x7a,
+	0x8d, 0x3c, 0x86, 0xc6, 0x88, 0x71, 0x5d, 0xb2, 0x77, 0xa5, 0xa0, 0x66, 0x9a, 0xd5, 0x63, 0x11,
+	0xbf, 0x58, 0xa1, 0xf9, 0xff, 0xd9, 0x05, 0xbf, 0x4a, 0x13, 0xd0, 0x66, 0x8f, 0x4d, 0xd8, 0x19,
+	0xb1, 0xb4, 0x3c, 0x42, 0x6c, 0x68, 0xff, 0xc5, 0x49, 0x7b, 0xd5, 0xdb, 0x7a, 0x6d, 0x70, 0x07,
+	0x3a, 0x41, 0xd4, 0x9b, 0xc5, 0x8b, 0x49, 0x8f, 0x7d, 0xe5, 0xcf, 0x17, 0x67, 0x2c, 0x59, 0x63,
+	0x07, 0xeb, 0xe8, 0x1e, 0x0b, 0x2f, 0x15, 0xfe, 0xef, 0xfc, 0x9f, 0xdf, 0x06, 0x00, 0x3d, 0x2b,
+	0xe8, 0xde, 0x4e, 0x0f, 0x00, 0x00,
 }
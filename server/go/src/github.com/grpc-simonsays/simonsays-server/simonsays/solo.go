@@ -0,0 +1,203 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	uuid "github.com/nu7hatch/gouuid"
+)
+
+// defaultPace is how long the server waits between each colour it plays.
+const defaultPace = 500 * time.Millisecond
+
+// soloPress is a colour pressed during the player's turn.
+type soloPress struct {
+	color Color
+	// was this the press that ended the turn?
+	last bool
+}
+
+// solo runs a single player game, where the server plays Simon.
+// Each round the server adds a colour to the sequence and plays it back,
+// and the player has to repeat it, until they get it wrong. The game ends
+// early if replaced is closed, as the player has joined again.
+func (s *SimonSays) solo(stream SimonSays_GameServer, player *Request_Player, replaced <-chan struct{}) error {
+	lc := "Solo"
+	ctx := stream.Context()
+
+	u, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	game := NewGame(u.String())
+	game.SetTimeouts(s.TurnTimeout, s.PressTimeout)
+	logger.Set(ctx, "Game", game.ID)
+	logger.Info(ctx, lc, "Starting solo game.")
+
+	done := make(chan struct{})
+	defer close(done)
+	presses, perrs := recvSoloPress(game, player, s.PressLimiter, stream, done)
+
+	err = sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_BEGIN}, Game: game.ID, Round: 1, Length: 1, Current: player.Id})
+	if err != nil {
+		return err
+	}
+	gamesStartedTotal.Inc(soloMode)
+	s.record(ctx, game, message{Type: beginMessage, Player: player.Id, Players: []string{player.Id}})
+
+	// only check deadlines if there are any.
+	deadlines, stop := s.deadlineTicks()
+	defer stop()
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var seq []Color
+
+	for {
+		seq = append(seq, Color(r.Intn(len(Color_name))))
+		logger.Info(ctx, lc, "Playing sequence: %v", seq)
+
+		if err := playSequence(stream, seq, s.pace); err != nil {
+			return err
+		}
+
+		game.NextTurn(player.Id, seq)
+		game.StartEchoTurn(seq)
+		// the turn before has been matched, so the sequence it ended with goes in the history.
+		s.record(ctx, game, message{Type: stopTurnMessage, Player: player.Id, Colors: seq[:len(seq)-1]})
+		err := sendResponse(stream, turnResponse(game, player, Response_START_TURN))
+		if err != nil {
+			return err
+		}
+
+		// light up each press, until the turn is over.
+		for last := false; !last; {
+			select {
+			case p := <-presses:
+				err := sendResponse(stream, &Response{Event: &Response_Lightup{Lightup: p.color}})
+				if err != nil {
+					return err
+				}
+				s.record(ctx, game, message{Type: lightUpMessage, Player: player.Id, Colors: []Color{p.color}})
+				last = p.last
+			case err := <-perrs:
+				logger.Error(ctx, lc, "There was a press error. %v", err)
+				if reason, ok := pressErrorReason(err); ok {
+					s.record(ctx, game, message{Type: lostMessage, Player: player.Id, Colors: seq, Reason: reason})
+				}
+				return err
+			case <-deadlines:
+				if game.ExpireTurn(time.Now()) {
+					logger.Info(ctx, lc, "Player ran out of time. Lost after %v rounds.", len(seq)-1)
+					gamesFinishedTotal.Inc(soloMode)
+					s.record(ctx, game, message{Type: lostMessage, Player: player.Id, Colors: seq, Reason: Elimination_TIMEOUT})
+					return sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_LOSE}})
+				}
+			case <-s.stopping:
+				s.record(ctx, game, message{Type: lostMessage, Player: player.Id, Colors: seq, Reason: Elimination_SHUTDOWN})
+				return ErrShuttingDown
+			case <-replaced:
+				s.record(ctx, game, message{Type: lostMessage, Player: player.Id, Colors: seq, Reason: Elimination_FORFEIT})
+				return ErrSessionReplaced
+			}
+		}
+
+		if !game.Match() {
+			logger.Info(ctx, lc, "Sequence did not match. Lost after %v rounds.", len(seq)-1)
+			gamesFinishedTotal.Inc(soloMode)
+			s.record(ctx, game, message{Type: lostMessage, Player: player.Id, Colors: seq, Reason: Elimination_WRONG_COLOR})
+			return sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_LOSE}})
+		}
+
+		err = sendResponse(stream, &Response{Event: &Response_Turn{Turn: Response_STOP_TURN}})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// playSequence sends each colour in the sequence to the player as a playback lightup,
+// waiting the given pace in between each one. Stops early if the stream's context is done.
+func playSequence(stream SimonSays_GameServer, seq []Color, pace time.Duration) error {
+	ctx := stream.Context()
+	t := time.NewTimer(pace)
+	defer t.Stop()
+
+	for _, c := range seq {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		err := sendResponse(stream, &Response{Event: &Response_Lightup{Lightup: c}, Playback: true})
+		if err != nil {
+			return err
+		}
+		t.Reset(pace)
+	}
+
+	return nil
+}
+
+// recvSoloPress receives Press Events through a go-routine, and presses them
+// in the game. Presses that were made in turn are sent down the returned channel,
+// and presses out of turn are ignored. Stops when done is closed,
+// or there is an error, which is sent down the channel of errors. Presses
+// faster than the limiter allows end with ErrRateLimited.
+func recvSoloPress(game *Game, player *Request_Player, limiter Limiter, stream SimonSays_GameServer, done <-chan struct{}) (<-chan soloPress, <-chan error) {
+	lc := "RecvSoloPress"
+	ctx := stream.Context()
+	c := make(chan soloPress)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			press, err := receivePressRequest(stream)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if err := checkLimit(ctx, limiter, player.Id); err != nil {
+				errs <- err
+				return
+			}
+
+			// lock, so we know if this press is the one that ended the turn.
+			game.mu.Lock()
+			err = game.pressColor(press.Press)
+			p := soloPress{color: press.Press, last: !game.isMyTurn()}
+			game.mu.Unlock()
+
+			if err == ErrColorPressedOutOfTurn {
+				logger.Info(ctx, lc, "Colour pressed out of turn. Ignored.")
+				continue
+			}
+			pressesTotal.Inc(soloMode)
+
+			select {
+			case c <- p:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return c, errs
+}
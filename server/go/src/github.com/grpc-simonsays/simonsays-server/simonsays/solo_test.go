@@ -0,0 +1,101 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+// TestSoloGame tests out a single player game against the server.
+func TestSoloGame(t *testing.T) {
+	player := newMockStream()
+
+	Convey("Given a SimonSays", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+		game.pace = 10 * time.Millisecond
+
+		Convey("We should be able to play a solo game", func(c C) {
+			done := make(chan bool)
+
+			err := player.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Mode: Request_SOLO}}})
+			So(err, ShouldBeNil)
+
+			go func() {
+				defer close(done)
+				err := game.Game(player)
+				c.So(err, ShouldBeNil)
+			}()
+
+			res, err := player.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetTurn(), ShouldEqual, Response_BEGIN)
+			So(res.Game, ShouldNotBeEmpty)
+
+			seq := []Color{}
+			for round := 1; round <= 3; round++ {
+				// the server plays the sequence so far, plus one more
+				next := make([]Color, round)
+				for i := range next {
+					res, err := player.PullSend()
+					So(err, ShouldBeNil)
+					So(res.Playback, ShouldBeTrue)
+					next[i] = res.GetLightup()
+				}
+				So(next[:len(seq)], ShouldResemble, seq)
+				seq = next
+
+				So(player, shouldState, Response_START_TURN)
+
+				// get the last one wrong on the final round
+				echo := append([]Color(nil), seq...)
+				if round == 3 {
+					echo[2] = (echo[2] + 1) % Color(len(Color_name))
+				}
+
+				for _, col := range echo {
+					mustPress(player, col)
+					So(player, shouldLightup, col)
+				}
+
+				if round < 3 {
+					So(player, shouldState, Response_STOP_TURN)
+				}
+			}
+
+			So(player, shouldState, Response_LOSE)
+			<-done
+
+			Convey("And the game is recorded", func() {
+				rec, err := game.GetGame(context.TODO(), &GameRequest{Game: res.Game})
+				So(err, ShouldBeNil)
+				So(rec.Players, ShouldResemble, []string{"Player One"})
+				So(rec.Winner, ShouldBeEmpty)
+				So(rec.End, ShouldBeGreaterThan, 0)
+				So(rec.Sequence, ShouldResemble, seq[:2])
+				So(rec.Turns, ShouldHaveLength, 3)
+				So(rec.Turns[2].Presses, ShouldHaveLength, 3)
+				So(rec.Eliminations, ShouldHaveLength, 1)
+				So(rec.Eliminations[0].Reason, ShouldEqual, Elimination_WRONG_COLOR)
+				So(rec.Eliminations[0].Round, ShouldEqual, 3)
+			})
+		})
+	})
+}
@@ -0,0 +1,230 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"errors"
+	"io"
+
+	"github.com/grpc-simonsays/simonsays-server/simonsays/logger"
+	"golang.org/x/net/context"
+)
+
+// ErrGameNotFound is returned when there is no game with the requested id.
+var ErrGameNotFound = errors.New("Game not found")
+
+// spectator keeps track of the state of a game that is being watched.
+type spectator struct {
+	game *Game
+	// the player whose turn it is, if we know.
+	current string
+	stream  responseStream
+}
+
+// Spectate function is an implementation of the gRPC Spectate Service.
+// Streams the events of a game to someone watching it, without them
+// being a player in the game. If it ends early, the spectator is sent
+// an ERROR, and it ends with the gRPC status for the reason.
+func (s *SimonSays) Spectate(req *SpectateRequest, stream SimonSays_SpectateServer) error {
+	defer s.track()()
+
+	stream = &spectateStream{SimonSays_SpectateServer: stream, ctx: logger.NewContext(stream.Context())}
+
+	return endStream(stream, s.spectate(req, stream))
+}
+
+// spectate streams the events of the game to the spectator.
+func (s *SimonSays) spectate(req *SpectateRequest, stream SimonSays_SpectateServer) error {
+	ctx := stream.Context()
+
+	lc := "Spectate"
+	if err := validateGameID(req.Game); err != nil {
+		logger.Warn(ctx, lc, "Invalid game to spectate. %v", err)
+		return err
+	}
+
+	game := NewGame(req.Game)
+	logger.Set(ctx, "Game", game.ID)
+	logger.Info(ctx, lc, "Spectator is attempting to watch.")
+
+	// watch before looking up the players, so we don't miss anything that happens in between.
+	msgs, sub, err := watch(ctx, s.broker, game)
+	if err != nil {
+		return err
+	}
+
+	// make sure that at the end, you always unsubscribe.
+	defer func() {
+		err := sub.Unsubscribe()
+		if err != nil {
+			logger.Error(ctx, lc, "Error unsubscribing from Game Topic %v, %v", game.ID, err)
+		}
+	}()
+
+	con := s.pool.Get()
+	defer con.Close()
+
+	// pick up anyone that has joined so far.
+	players, err := listPlayers(ctx, con, game)
+	if err != nil {
+		return err
+	}
+	if len(players) == 0 {
+		logger.Error(ctx, lc, "No players in game.")
+		return ErrGameNotFound
+	}
+
+	// the players are kept a while after the game, so check it hasn't already finished.
+	over, err := s.gameOver(ctx, game)
+	if err != nil {
+		return err
+	}
+	if over {
+		logger.Info(ctx, lc, "Game is already over.")
+		return ErrGameNotFound
+	}
+
+	game.SetPlayers(players)
+
+	sp := &spectator{game: game, stream: stream}
+
+	for {
+		select {
+		case msg := <-msgs:
+			if msg == nil {
+				logger.Error(ctx, lc, "Message Channel has closed. Exiting.")
+				return nil
+			}
+
+			err := sp.handle(msg)
+			if err == io.EOF {
+				logger.Info(ctx, lc, "[Spectate] EOF. Closing connection.")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+		// the server can't wait any longer for the game to finish.
+		case <-s.stopping:
+			return ErrShuttingDown
+		}
+	}
+}
+
+// gameOver returns if the history of the game shows it has finished.
+// A game that hasn't begun yet has no history.
+func (s *SimonSays) gameOver(ctx context.Context, game *Game) (bool, error) {
+	events, err := s.history.Events(ctx, game.ID)
+	if err == ErrGameNotFound {
+		return false, nil
+	}
+	if err != nil {
+		logger.Error(ctx, "GameOver", "Error reading the history of the game. %v", err)
+		return false, err
+	}
+
+	return buildRecord(game.ID, events).End != 0, nil
+}
+
+// handle turns a pub/sub message into the events a spectator sees.
+// Returns io.EOF once the game is over, when the last player standing
+// wins, or everyone has lost. SOLO games are never published, so only
+// their Replay ends with nobody left.
+func (sp *spectator) handle(msg *message) error {
+	lc := "SpectatorHandler"
+	logger.Info(sp.stream.Context(), lc, "Handling Message: %#v", msg)
+
+	switch msg.Type {
+	case beginMessage:
+		sp.game.SetPlayers(msg.Players)
+		return sp.send(&Response{Event: &Response_Turn{Turn: Response_BEGIN}, Game: sp.game.ID})
+
+	case stopTurnMessage:
+		if err := sp.sendTurn(Response_STOP_TURN, msg.Player); err != nil {
+			return err
+		}
+		sp.current = sp.game.NextPlayer(msg.Player)
+		return sp.sendTurn(Response_START_TURN, sp.current)
+
+	case lightUpMessage:
+		c, err := lightUpColor(msg)
+		if err != nil {
+			logger.Error(sp.stream.Context(), lc, "Could not convert colour. %#v. %v", msg, err)
+			return err
+		}
+		return sp.send(&Response{Event: &Response_Lightup{Lightup: c}, Player: sp.current})
+
+	case lostMessage:
+		if err := sp.sendTurn(Response_LOSE, msg.Player); err != nil {
+			return err
+		}
+
+		next := sp.game.NextPlayer(msg.Player)
+		sp.game.RemovePlayer(msg.Player)
+
+		// the last player standing wins, and the game is over.
+		// A replayed SOLO game is over once its only player has lost.
+		switch players := sp.game.Players(); len(players) {
+		case 0:
+			return io.EOF
+		case 1:
+			if err := sp.sendTurn(Response_WIN, players[0]); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+
+		// a player can leave when it isn't their turn, in which case the turn carries on.
+		if sp.current != "" && msg.Player != sp.current {
+			return nil
+		}
+
+		sp.current = next
+		return sp.sendTurn(Response_START_TURN, sp.current)
+
+	case reconnectingMessage:
+		return sp.sendTurn(Response_RECONNECTING, msg.Player)
+
+	case reconnectedMessage:
+		return sp.sendTurn(Response_RECONNECTED, msg.Player)
+	}
+
+	logger.Error(sp.stream.Context(), lc, "Could not find a handler for this event. %#v", msg)
+	return handlerNotFoundError(msg.Type.String())
+}
+
+// sendTurn sends a turn event about the given player.
+func (sp *spectator) sendTurn(turn Response_State, player string) error {
+	return sp.send(&Response{Event: &Response_Turn{Turn: turn}, Player: player})
+}
+
+// send sends a response to the spectator.
+func (sp *spectator) send(r *Response) error {
+	return sendResponse(sp.stream, r)
+}
+
+// spectateStream is a Spectate stream with a context
+// that the game being watched is logged with.
+type spectateStream struct {
+	SimonSays_SpectateServer
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (s *spectateStream) Context() context.Context {
+	return s.ctx
+}
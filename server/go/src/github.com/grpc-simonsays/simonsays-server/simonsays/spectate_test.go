@@ -0,0 +1,137 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestSpectate watches a simple game from the sidelines.
+func TestSpectate(t *testing.T) {
+
+	playerOne := newMockStream()
+	playerTwo := newMockStream()
+	spectator := newMockStream()
+
+	Convey("Given a SimonSays", t, func() {
+		game, err := NewSimonSays("", NewMemoryBroker())
+		So(err, ShouldBeNil)
+		So(game, ShouldNotBeNil)
+		defer game.Close()
+
+		Convey("Spectating a game that doesn't exist should fail", func() {
+			err := game.Spectate(&SpectateRequest{Game: "Not A Game"}, spectator)
+			So(grpc.Code(err), ShouldEqual, codes.NotFound)
+			So(spectator, shouldError, Error_NOT_FOUND)
+		})
+
+		Convey("Spectating a game with an invalid id should be turned away", func() {
+			err := game.Spectate(&SpectateRequest{Game: strings.Repeat("a", maxCodeLength+1)}, spectator)
+			So(grpc.Code(err), ShouldEqual, codes.InvalidArgument)
+			So(spectator, shouldError, Error_INVALID_REQUEST)
+		})
+
+		Convey("We should be able to watch a simple game", func(c C) {
+			wg := sync.WaitGroup{}
+
+			for i, p := range []*mockStream{playerOne, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1)}}})
+				So(err, ShouldBeNil)
+
+				wg.Add(1)
+				go func(p *mockStream) {
+					defer wg.Done()
+					err := game.Game(p)
+					c.So(err, ShouldBeNil)
+				}(p)
+
+				// hacky, but makes sure the players join in order.
+				time.Sleep(time.Second)
+			}
+
+			res, err := playerOne.PullSend()
+			So(err, ShouldBeNil)
+			So(res.Game, ShouldNotBeEmpty)
+			id := res.Game
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(playerOne, shouldState, Response_START_TURN)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := game.Spectate(&SpectateRequest{Game: id}, spectator)
+				c.So(err, ShouldBeNil)
+			}()
+
+			// give the spectator time to start watching.
+			time.Sleep(500 * time.Millisecond)
+
+			mustPress(playerOne, Color_GREEN)
+			So(playerOne, shouldLightup, Color_GREEN)
+			So(playerTwo, shouldLightup, Color_GREEN)
+			So(playerOne, shouldState, Response_STOP_TURN)
+			So(playerTwo, shouldState, Response_START_TURN)
+
+			mustPress(playerTwo, Color_BLUE)
+			So(playerOne, shouldLightup, Color_BLUE)
+			So(playerTwo, shouldLightup, Color_BLUE)
+			So(playerOne, shouldState, Response_WIN)
+			So(playerTwo, shouldState, Response_LOSE)
+
+			So(spectator, shouldLightup, Color_GREEN)
+			So(spectator, shouldPlayerState, Response_STOP_TURN, "Player 1")
+			So(spectator, shouldPlayerState, Response_START_TURN, "Player 2")
+
+			res, err = spectator.PullSend()
+			So(err, ShouldBeNil)
+			So(res.GetLightup(), ShouldEqual, Color_BLUE)
+			So(res.Player, ShouldEqual, "Player 2")
+
+			So(spectator, shouldPlayerState, Response_LOSE, "Player 2")
+			So(spectator, shouldPlayerState, Response_WIN, "Player 1")
+
+			wg.Wait()
+
+			Convey("But not once it is over", func() {
+				err := game.Spectate(&SpectateRequest{Game: id}, spectator)
+				So(grpc.Code(err), ShouldEqual, codes.NotFound)
+				So(spectator, shouldError, Error_NOT_FOUND)
+			})
+		})
+	})
+}
+
+func shouldPlayerState(player interface{}, args ...interface{}) string {
+	res, err := player.(*mockStream).PullSend()
+	if err != nil {
+		return fmt.Sprintf("Error should be nil. %v", err)
+	}
+
+	if res.GetTurn() != args[0].(Response_State) || res.Player != args[1].(string) {
+		return fmt.Sprintf("Response %v != Turn %v for Player %v", res, args[0], args[1])
+	}
+
+	return ""
+}
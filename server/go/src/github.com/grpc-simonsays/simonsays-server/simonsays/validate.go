@@ -0,0 +1,109 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxPlayerIDLength is the longest a player id can be, in bytes.
+	maxPlayerIDLength = 64
+	// maxCodeLength is the longest a room code, resume token or game id can be, in bytes.
+	maxCodeLength = 64
+)
+
+// playerIDSymbols are the characters, other than letters, digits and spaces,
+// that can be in a player id.
+const playerIDSymbols = "-_.@"
+
+// validatePlayerID checks the id is something we can use as a player id: between 1
+// and maxPlayerIDLength bytes of letters, digits, spaces and playerIDSymbols,
+// without spaces at the start or end.
+func validatePlayerID(id string) error {
+	if id == "" {
+		return invalidRequestError("Player id is missing.")
+	}
+	if len(id) > maxPlayerIDLength {
+		return invalidRequestError(fmt.Sprintf("Player id must be at most %v bytes long. Was %v", maxPlayerIDLength, len(id)))
+	}
+	if !utf8.ValidString(id) {
+		return invalidRequestError("Player id must be UTF-8.")
+	}
+	if strings.TrimSpace(id) != id {
+		return invalidRequestError("Player id can't start or end with a space.")
+	}
+
+	for _, r := range id {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && !strings.ContainsRune(playerIDSymbols, r) {
+			return invalidRequestError(fmt.Sprintf("Player id can only have letters, digits, spaces and %q. Had %q", playerIDSymbols, r))
+		}
+	}
+
+	return nil
+}
+
+// validateJoin checks everything in the join is something we can use,
+// so a game doesn't start with something it can't handle.
+func validateJoin(player *Request_Player) error {
+	if err := validatePlayerID(player.Id); err != nil {
+		return err
+	}
+	if _, ok := Request_Mode_name[int32(player.Mode)]; !ok {
+		return invalidRequestError(fmt.Sprintf("Unknown mode %v", player.Mode))
+	}
+	if len(player.Room) > maxCodeLength {
+		return invalidRequestError(fmt.Sprintf("Room code must be at most %v bytes long.", maxCodeLength))
+	}
+	if len(player.Resume) > maxCodeLength {
+		return invalidRequestError(fmt.Sprintf("Resume token must be at most %v bytes long.", maxCodeLength))
+	}
+
+	return nil
+}
+
+// validateGameID checks the id could be a game's: between 1 and
+// maxCodeLength bytes of printable UTF-8.
+func validateGameID(id string) error {
+	if id == "" {
+		return invalidRequestError("Game id is missing.")
+	}
+	if len(id) > maxCodeLength {
+		return invalidRequestError(fmt.Sprintf("Game id must be at most %v bytes long. Was %v", maxCodeLength, len(id)))
+	}
+	if !utf8.ValidString(id) {
+		return invalidRequestError("Game id must be UTF-8.")
+	}
+
+	for _, r := range id {
+		if !unicode.IsPrint(r) {
+			return invalidRequestError(fmt.Sprintf("Game id can only have printable characters. Had %q", r))
+		}
+	}
+
+	return nil
+}
+
+// validateColor checks the colour is one of the colours on the board.
+func validateColor(c Color) error {
+	if _, ok := Color_name[int32(c)]; !ok {
+		return invalidRequestError(fmt.Sprintf("Unknown colour %v", c))
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+/* Copyright 2015 Google Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+==============================================================================*/
+
+package simonsays
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestValidatePlayerID tests which player ids are let through.
+func TestValidatePlayerID(t *testing.T) {
+	Convey("Player ids should be checked", t, func() {
+		for _, id := range []string{"Player One", "a", "player_1", "first.last@example.com", "Ünïcödé-名前", strings.Repeat("a", maxPlayerIDLength)} {
+			So(validatePlayerID(id), ShouldBeNil)
+		}
+
+		for _, id := range []string{"", " Player", "Player ", "tab\there", "new\nline", "Player:One", "<script>", "\xff\xfe", strings.Repeat("a", maxPlayerIDLength+1)} {
+			So(validatePlayerID(id), ShouldHaveSameTypeAs, invalidRequestError(""))
+		}
+	})
+}
+
+// TestValidateJoin tests which joins are let through.
+func TestValidateJoin(t *testing.T) {
+	Convey("Joins should be checked", t, func() {
+		So(validateJoin(&Request_Player{Id: "Player One"}), ShouldBeNil)
+		So(validateJoin(&Request_Player{Id: "Player One", Mode: Request_SOLO}), ShouldBeNil)
+		So(validateJoin(&Request_Player{Id: "Player One", Room: "ABCDE"}), ShouldBeNil)
+
+		So(validateJoin(&Request_Player{}), ShouldHaveSameTypeAs, invalidRequestError(""))
+		So(validateJoin(&Request_Player{Id: "Player One", Mode: Request_Mode(7)}), ShouldHaveSameTypeAs, invalidRequestError(""))
+		So(validateJoin(&Request_Player{Id: "Player One", Room: strings.Repeat("A", maxCodeLength+1)}), ShouldHaveSameTypeAs, invalidRequestError(""))
+		So(validateJoin(&Request_Player{Id: "Player One", Resume: strings.Repeat("A", maxCodeLength+1)}), ShouldHaveSameTypeAs, invalidRequestError(""))
+	})
+}
+
+// TestValidateGameID tests which game ids can be asked for.
+func TestValidateGameID(t *testing.T) {
+	Convey("Game ids should be checked", t, func() {
+		for _, id := range []string{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", "Game One", strings.Repeat("a", maxCodeLength)} {
+			So(validateGameID(id), ShouldBeNil)
+		}
+
+		for _, id := range []string{"", "new\nline", "\xff\xfe", strings.Repeat("a", maxCodeLength+1)} {
+			So(validateGameID(id), ShouldHaveSameTypeAs, invalidRequestError(""))
+		}
+	})
+}
+
+// TestValidateColor tests which colours can be pressed.
+func TestValidateColor(t *testing.T) {
+	Convey("Colours should be checked", t, func() {
+		for c := range Color_name {
+			So(validateColor(Color(c)), ShouldBeNil)
+		}
+		So(validateColor(Color(len(Color_name))), ShouldHaveSameTypeAs, invalidRequestError(""))
+		So(validateColor(Color(-1)), ShouldHaveSameTypeAs, invalidRequestError(""))
+	})
+}
+
+// TestInvalidRequests tests games that are sent something they can't use.
+func TestInvalidRequests(t *testing.T) {
+	Convey("Given a SimonSays", t, func() {
+		game := mustSimonSays()
+		defer game.Close()
+		game.pace = 10 * time.Millisecond
+
+		con := game.pool.Get()
+		defer con.Close()
+		_, err := con.Do("FLUSHDB")
+		So(err, ShouldBeNil)
+
+		stream := newMockStream()
+
+		Convey("A join with an invalid player id should be turned away", func() {
+			err := stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "<script>"}}})
+			So(err, ShouldBeNil)
+
+			So(grpc.Code(game.Game(stream)), ShouldEqual, codes.InvalidArgument)
+			So(stream, shouldError, Error_INVALID_REQUEST)
+		})
+
+		Convey("A press of an unknown colour should end the game", func() {
+			err := stream.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Mode: Request_SOLO}}})
+			So(err, ShouldBeNil)
+
+			done := make(chan error, 1)
+			go func() {
+				done <- game.Game(stream)
+			}()
+
+			So(stream, shouldState, Response_BEGIN)
+			_, err = stream.PullSend()
+			So(err, ShouldBeNil)
+			So(stream, shouldState, Response_START_TURN)
+
+			mustPress(stream, Color(42))
+			So(stream, shouldError, Error_INVALID_REQUEST)
+			So(grpc.Code(<-done), ShouldEqual, codes.InvalidArgument)
+		})
+
+		Convey("A press of an unknown colour should lose a game against someone else", func() {
+			playerTwo := newMockStream()
+			done := make(chan error, 2)
+
+			for i, p := range []*mockStream{stream, playerTwo} {
+				err := p.PushRecv(&Request{Event: &Request_Join{Join: &Request_Player{Id: fmt.Sprintf("Player %v", i+1)}}})
+				So(err, ShouldBeNil)
+
+				go func(p *mockStream) {
+					done <- game.Game(p)
+				}(p)
+
+				// hacky, but makes sure the players join in order.
+				time.Sleep(time.Second)
+			}
+
+			So(stream, shouldState, Response_BEGIN)
+			So(playerTwo, shouldState, Response_BEGIN)
+			So(stream, shouldState, Response_START_TURN)
+			So(playerTwo, shouldState, Response_STOP_TURN)
+
+			mustPress(stream, Color(42))
+			So(stream, shouldError, Error_INVALID_REQUEST)
+			So(playerTwo, shouldState, Response_WIN)
+
+			ended := []codes.Code{grpc.Code(<-done), grpc.Code(<-done)}
+			So(ended, ShouldContain, codes.InvalidArgument)
+			So(ended, ShouldContain, codes.OK)
+		})
+	})
+}
+
+// requestSeeds are encoded requests to start fuzzing from, good and bad.
+func requestSeeds() [][]byte {
+	reqs := []*Request{
+		{Event: &Request_Join{Join: &Request_Player{Id: "Player One"}}},
+		{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Mode: Request_SOLO}}},
+		{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Players: 3, Private: true}}},
+		{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Room: "ABCDE"}}},
+		{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Resume: "not a token"}}},
+		{Event: &Request_Join{Join: &Request_Player{Id: "Player One", Players: -1, Mode: Request_Mode(9)}}},
+		{Event: &Request_Join{Join: &Request_Player{Id: "\xff\x00", Mode: Request_SOLO}}},
+		{Event: &Request_Join{Join: &Request_Player{Mode: Request_SOLO}}},
+		{Event: &Request_Join{Join: &Request_Player{}}},
+		{Event: &Request_Press{Press: Color_GREEN}},
+		{Event: &Request_Press{Press: Color(99)}},
+		{Event: &Request_Press{Press: Color(-5)}},
+		{},
+	}
+
+	var seeds [][]byte
+	for _, r := range reqs {
+		data, err := proto.Marshal(r)
+		if err != nil {
+			panic(err)
+		}
+		seeds = append(seeds, data)
+	}
+
+	// not even protobufs.
+	return append(seeds, nil, []byte{0x0a}, []byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f}, []byte{0x10, 0x80})
+}
+
+// FuzzRequest tests that the player ids of joins that are let through can be
+// used in Redis keys, without being mistaken for more than one part of the key.
+func FuzzRequest(f *testing.F) {
+	for _, seed := range requestSeeds() {
+		f.Add(seed)
+	}
+
+	limiter := &redisLimiter{name: "join"}
+	game := NewGame("game")
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req := new(Request)
+		if err := proto.Unmarshal(data, req); err != nil {
+			return
+		}
+
+		join := req.GetJoin()
+		if join == nil || validateJoin(join) != nil {
+			return
+		}
+
+		id := join.Id
+		keys := map[string][]string{
+			playerGamesKey(id): {"Games", id},
+			activeKey(id):      {"Active", id},
+			aliveKey(game, id): {game.ID, "Alive", id},
+		}
+		for _, key := range limitKeys(context.TODO(), id) {
+			keys[limiter.bucketKey(key)] = []string{"RateLimit", limiter.name, "player", id}
+		}
+
+		for key, parts := range keys {
+			if got := strings.Split(key, ":"); !reflect.DeepEqual(got, parts) {
+				t.Fatalf("Key %q for player id %q splits into %q, not %q", key, id, got, parts)
+			}
+		}
+	})
+}
+
+// FuzzGame tests that a game that is sent requests that could be anything,
+// then has its stream closed, always ends, rather than crashing or hanging.
+func FuzzGame(f *testing.F) {
+	game := mustSimonSays()
+	defer game.Close()
+	game.pace = 0
+
+	seeds := requestSeeds()
+	for _, join := range seeds[:9] {
+		for _, press := range seeds[9:] {
+			f.Add(join, press)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, join, press []byte) {
+		stream := newMockStream()
+
+		for _, data := range [][]byte{join, press} {
+			req := new(Request)
+			if err := proto.Unmarshal(data, req); err != nil {
+				return
+			}
+			if err := stream.PushRecv(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the client goes away.
+		if err := stream.PushRecv(nil); err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			game.Game(stream)
+		}()
+
+		for {
+			select {
+			case <-stream.sendChan:
+			case <-done:
+				return
+			case <-time.After(2 * timeOut):
+				t.Fatalf("Game with requests %x and %x did not end", join, press)
+			}
+		}
+	})
+}